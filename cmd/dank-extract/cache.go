@@ -0,0 +1,190 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AgentDank/dank-extract/sources"
+	flag "github.com/spf13/pflag"
+)
+
+// runCache implements "dank-extract cache <head|tail> <dataset>", printing the first or
+// last records of a dataset's cache file, one compact JSON record per line,
+// and "dank-extract cache verify [dataset...]", checking that caches still decode.
+// Returns the exit code.
+func runCache(args []string) int {
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	rootDir := flags.String("root", ".", "Root directory for .dank data")
+	count := flags.IntP("number", "n", 5, "Number of records to print, for head and tail")
+	strict := flags.Bool("strict", false, "Also fail records with fields their struct does not define, for verify")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dank-extract cache <head|tail> [options] <dataset>")
+		fmt.Fprintln(os.Stderr, "       dank-extract cache verify [options] [dataset...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Datasets: %s\n\n", strings.Join(datasetNames(), ", "))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || *count < 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	sources.SetDankRoot(*rootDir)
+
+	action := flags.Arg(0)
+	if action == "verify" {
+		return runCacheVerify(flags.Args()[1:], *strict)
+	}
+	if (action != "head" && action != "tail") || flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	name := flags.Arg(1)
+
+	var cacheFilename string
+	for _, d := range datasetRegistry {
+		if d.Name() == name {
+			cacheFilename = d.CacheFilename()
+		}
+	}
+	if cacheFilename == "" {
+		log.Printf("unknown dataset %q, expected one of: %s", name, strings.Join(datasetNames(), ", "))
+		return exitUsage
+	}
+
+	var records []json.RawMessage
+	var err error
+	if action == "head" {
+		records, err = cacheHead(cacheFilename, *count)
+	} else {
+		records, err = cacheTail(cacheFilename, *count)
+	}
+	if err != nil {
+		log.Printf("failed to read %s cache: %v", name, err)
+		return exitCodeFor(err)
+	}
+
+	for _, record := range records {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, record); err != nil {
+			log.Printf("failed to read %s cache: %v", name, err)
+			return exitError
+		}
+		fmt.Println(compact.String())
+	}
+	return exitOK
+}
+
+// cacheHead returns the first n records of a cache file
+func cacheHead(cacheFilename string, n int) ([]json.RawMessage, error) {
+	var records []json.RawMessage
+	if n == 0 {
+		return records, nil
+	}
+	err := sources.EachCacheRecord(cacheFilename, func(record json.RawMessage) bool {
+		records = append(records, record)
+		return len(records) < n
+	})
+	return records, err
+}
+
+// cacheTail returns the last n records of a cache file.  Records are read in order,
+// keeping only the last n, so the whole cache is never held in memory.
+func cacheTail(cacheFilename string, n int) ([]json.RawMessage, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	ring := make([]json.RawMessage, 0, n)
+	total := 0
+	err := sources.EachCacheRecord(cacheFilename, func(record json.RawMessage) bool {
+		if len(ring) < n {
+			ring = append(ring, record)
+		} else {
+			ring[total%n] = record
+		}
+		total++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if total <= n {
+		return ring, nil
+	}
+	start := total % n
+	return append(ring[start:], ring[:start]...), nil
+}
+
+// Statuses of a dataset's cache, for "cache verify"
+const (
+	cacheStatusOK      = "ok"      // Every record decodes
+	cacheStatusInvalid = "invalid" // A record does not decode, or the cache is of another version
+	cacheStatusMissing = "missing" // There is no cache file
+)
+
+// CacheCheckRow is the result of checking one dataset's cache, for "cache verify"
+type CacheCheckRow struct {
+	Dataset string
+	Cache   string // Cache filename
+	Records int    // Number of records that decoded
+	Status  string // "ok", "invalid", or "missing"
+	Detail  string // Why the cache is invalid, if it is
+}
+
+// runCacheVerify checks that the named datasets' caches, or every dataset's, decode into their
+// current record structs, writing a table of the results to stdout.
+// Returns exitCache if any cache is invalid; missing caches are reported but are not failures.
+func runCacheVerify(names []string, strict bool) int {
+	for _, name := range names {
+		if !isDatasetName(name) {
+			log.Printf("unknown dataset %q, expected one of: %s", name, strings.Join(datasetNames(), ", "))
+			return exitUsage
+		}
+	}
+
+	rows := make([]CacheCheckRow, 0, len(datasetRegistry))
+	exitCode := exitOK
+	for _, d := range datasetRegistry {
+		if len(names) > 0 && !slices.Contains(names, d.Name()) {
+			continue
+		}
+		row := CacheCheckRow{Dataset: d.Name(), Cache: d.CacheFilename(), Status: cacheStatusOK}
+		var err error
+		row.Records, err = d.VerifyCache(strict)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			row.Status = cacheStatusMissing
+		case err != nil:
+			row.Status, row.Detail = cacheStatusInvalid, err.Error()
+			exitCode = exitCache
+		}
+		rows = append(rows, row)
+	}
+
+	if err := writeCacheChecks(os.Stdout, rows); err != nil {
+		log.Printf("failed to write cache report: %v", err)
+		return exitError
+	}
+	return exitCode
+}
+
+// writeCacheChecks writes the cache check results as an aligned table
+func writeCacheChecks(w io.Writer, rows []CacheCheckRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATASET\tCACHE\tSTATUS\tRECORDS\tDETAIL")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", row.Dataset, row.Cache, row.Status, row.Records, row.Detail)
+	}
+	return tw.Flush()
+}
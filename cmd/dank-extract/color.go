@@ -0,0 +1,127 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ANSI escape sequences of the log colors
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBold   = "\x1b[1m"
+	ansiNormal = "\x1b[22m" // Ends bold, leaving the color as it is
+	ansiReset  = "\x1b[0m"
+)
+
+// Log messages starting with these are errors, shown in red, and warnings, shown in yellow
+var (
+	errorLogPrefixes   = []string{"Error", "Failed", "failed", "Cannot", "Invalid", "unknown", "--"}
+	warningLogPrefixes = []string{"Found", "Flagged", "Repaired", "CT "}
+)
+
+// useColor returns true if log output to stderr should be colorized: when stderr is a
+// terminal, and neither --no-color nor the NO_COLOR environment variable (https://no-color.org)
+// is set, and TERM is not "dumb"
+func useColor(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(os.Stderr)
+}
+
+// isTerminal returns true if f is a terminal, or another character device
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorLogWriter colorizes the lines log writes to it by their message: errors in red,
+// warnings in yellow, and dataset names in bold.  The log package writes each line with
+// a single Write, so lines are colorized whole.
+type colorLogWriter struct {
+	w        io.Writer
+	flags    int            // The log flags, to find the message after the line's date and time
+	datasets *regexp.Regexp // Matches dataset names as whole words
+}
+
+// newColorLogWriter returns a colorLogWriter writing to w, for a logger with the given flags
+func newColorLogWriter(w io.Writer, flags int, datasets []string) *colorLogWriter {
+	quoted := make([]string, len(datasets))
+	for i, name := range datasets {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return &colorLogWriter{
+		w:        w,
+		flags:    flags,
+		datasets: regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// Write colorizes and writes a log line, returning len(p) if it was written
+func (cw *colorLogWriter) Write(p []byte) (int, error) {
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	header, message := splitLogHeader(line, cw.flags)
+
+	color := ""
+	switch {
+	case hasAnyPrefix(message, errorLogPrefixes):
+		color = ansiRed
+	case hasAnyPrefix(message, warningLogPrefixes):
+		color = ansiYellow
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(p) + 32)
+	buf.Write(header)
+	buf.WriteString(color)
+	buf.Write(cw.datasets.ReplaceAll(message, []byte(ansiBold+"$1"+ansiNormal)))
+	if color != "" {
+		buf.WriteString(ansiReset)
+	}
+	buf.WriteByte('\n')
+	if _, err := cw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// splitLogHeader splits a log line into the date and time the log flags put before
+// the message, and the message itself
+func splitLogHeader(line []byte, flags int) ([]byte, []byte) {
+	fields := 0
+	if flags&log.Ldate != 0 {
+		fields++
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		fields++
+	}
+	end := 0
+	for range fields {
+		i := bytes.IndexByte(line[end:], ' ')
+		if i < 0 {
+			return nil, line
+		}
+		end += i + 1
+	}
+	return line[:end], line[end:]
+}
+
+// hasAnyPrefix returns true if s starts with any of the prefixes
+func hasAnyPrefix(s []byte, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(s, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
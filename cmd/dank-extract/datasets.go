@@ -0,0 +1,1042 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AgentDank/dank-extract/internal/db"
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// datasetProcessor runs the fetch, clean, export, and load pipeline for one dataset
+type datasetProcessor interface {
+	// Name returns the dataset name used on the command line, e.g. "sales"
+	Name() string
+	// OptIn returns true if the dataset is not processed by default
+	OptIn() bool
+	// Source returns the source whose app token fetches the dataset, e.g. "ct"
+	Source() string
+	// CacheFilename returns the name of the dataset's cache file
+	CacheFilename() string
+	// CheckCache checks that the dataset's cache, or its --lite cache if lite, is present and
+	// no older than maxAge, for --cache-only.  Returns a *sources.CacheError if not.
+	CheckCache(lite bool, maxAge time.Duration) error
+	// Cadence returns how often the dataset is expected to update upstream
+	Cadence() time.Duration
+	// DBTable returns the DuckDB table the dataset is loaded into
+	DBTable() string
+	// Process runs the pipeline, returning the list of output files created
+	Process(opts processOpts) ([]string, error)
+	// VerifyCache checks that every record of the dataset's cache decodes, for "cache verify".
+	// Returns the number of records that decoded, and an error, if any.
+	VerifyCache(strict bool) (int, error)
+	// Sample fetches (or loads from cache) the dataset and writes its first n records to w, for --sample
+	Sample(opts processOpts, n int, w io.Writer) error
+	// Dictionary describes each of the dataset's columns, for --data-dictionary
+	Dictionary() ([]sources.DictionaryEntry, error)
+}
+
+// datasetRecord is the constraint of a dataset's records, which are exported to CSV and may be read back from it
+type datasetRecord[T any] interface {
+	sources.CSVExportable
+	sources.CSVImportable[T]
+}
+
+// dataset describes the steps of the pipeline for a dataset of records of type T
+type dataset[T datasetRecord[T]] struct {
+	name          string // Dataset name used on the command line and as export key
+	label         string // Description used in log and error messages, e.g. "weekly sales"
+	source        string // Source whose app token fetches the dataset, e.g. "ct"
+	cacheFilename string
+	csvFilename   string
+	jsonFilename  string
+	optIn         bool          // Only processed when explicitly selected with --dataset
+	cadence       time.Duration // Expected upstream update cadence, for --freshness
+
+	fetch    func(appToken string, maxCacheAge time.Duration) ([]T, error)
+	socrata  *sources.SocrataConfig // Configuration the fetches use, whose page stats are logged with --verbose
+	dbInsert func(conn *sql.DB, items []T) error
+	dbTable  string // DuckDB table that dbInsert loads, for --db-bulk-load and --tables
+
+	// stream optionally fetches the records a page at a time, for --stream.
+	// When streaming, clean is applied to each page of records in turn.
+	stream func(appToken string, maxCacheAge time.Duration, batches chan<- []T) error
+
+	// fetchIncremental optionally fetches only the records since the dataset's watermark, for --incremental
+	fetchIncremental func(appToken string) ([]T, error)
+	// dbUpsert inserts records replacing those with the same key, for --incremental; required with fetchIncremental
+	dbUpsert func(conn *sql.DB, items []T) error
+
+	// contentKey returns a record's natural key, which orders the records for the manifest's content hash
+	contentKey func(item T) string
+
+	// clean optionally repairs or removes records before export, returning any actions taken.
+	// Any dataset may set it; Process logs how many records it removed, and exports the
+	// actions to cleanReportFilename.
+	clean func(items []T, opts processOpts) ([]T, []sources.CleanReport)
+	// cleanReportFilename is where clean reports are written, if there are any
+	cleanReportFilename string
+	// exportExtra optionally writes additional exports derived from the records
+	exportExtra func(items []T, opts processOpts) ([]string, error)
+	// lite optionally is the dataset's commonly-wanted columns, fetched and exported with --lite
+	lite *litePreset
+}
+
+// litePreset is the columns of a dataset's --lite fetch and export
+type litePreset struct {
+	fields  []string // Socrata fields fetched with $select
+	columns []string // CSV columns exported, which are derived from the fields
+}
+
+// datasetRegistry holds every supported dataset, in processing order
+var datasetRegistry = []datasetProcessor{
+	&dataset[ct.Brand]{
+		name:                "brands",
+		cadence:             24 * time.Hour,
+		label:               "brands",
+		source:              "ct",
+		cacheFilename:       ct.BrandJSONFilename,
+		csvFilename:         ct.BrandCSVFilename,
+		jsonFilename:        ct.BrandJSONFilename,
+		fetch:               ct.FetchBrands,
+		socrata:             &ct.BrandConfig,
+		stream:              ct.StreamBrands,
+		dbInsert:            ct.DBInsertBrands,
+		dbTable:             "ct_brands",
+		clean:               cleanBrands,
+		cleanReportFilename: ct.BrandCleanReportFilename,
+		exportExtra:         exportBrandExtras,
+		contentKey:          func(b ct.Brand) string { return b.Key() },
+		lite: &litePreset{
+			fields: []string{"brand_name", "dosage_form", "registration_number",
+				"tetrahydrocannabinol_thc", "tetrahydrocannabinol_acid_thca", "cannabidiols_cbd", "cannabidiol_acid_cbda"},
+			columns: []string{"brand_name", "dosage_form", "registration_number",
+				"tetrahydrocannabinol_thc", "tetrahydrocannabinol_acid_thca", "cannabidiols_cbd", "cannabidiol_acid_cbda"},
+		},
+	},
+	&dataset[ct.Credential]{
+		name:                "credentials",
+		cadence:             7 * 24 * time.Hour,
+		label:               "credentials",
+		source:              "ct",
+		cacheFilename:       ct.CredentialJSONFilename,
+		csvFilename:         ct.CredentialCSVFilename,
+		jsonFilename:        ct.CredentialJSONFilename,
+		fetch:               ct.FetchCredentials,
+		socrata:             &ct.CredentialConfig,
+		dbInsert:            ct.DBInsertCredentials,
+		dbTable:             "ct_credentials",
+		clean:               cleanCredentials,
+		cleanReportFilename: ct.CredentialCleanReportFilename,
+		exportExtra:         exportCredentialSummaries,
+		contentKey:          func(c ct.Credential) string { return c.CredentialType + "/" + c.Status },
+	},
+	&dataset[ct.Application]{
+		name:          "applications",
+		cadence:       7 * 24 * time.Hour,
+		label:         "applications",
+		source:        "ct",
+		cacheFilename: ct.ApplicationJSONFilename,
+		csvFilename:   ct.ApplicationCSVFilename,
+		jsonFilename:  ct.ApplicationJSONFilename,
+		fetch:         ct.FetchApplications,
+		socrata:       &ct.ApplicationConfig,
+		dbInsert:      ct.DBInsertApplications,
+		dbTable:       "ct_applications",
+		contentKey:    func(a ct.Application) string { return ct.NormalizeLicenseNumber(a.ApplicationLicenseNumber) },
+		lite: &litePreset{
+			fields:  []string{"application_license_number", "application_credential_status", "name"},
+			columns: []string{"application_license_number", "application_credential_status", "name"},
+		},
+	},
+	&dataset[ct.WeeklySales]{
+		name:                "sales",
+		cadence:             7 * 24 * time.Hour,
+		label:               "weekly sales",
+		source:              "ct",
+		cacheFilename:       ct.WeeklySalesJSONFilename,
+		csvFilename:         ct.WeeklySalesCSVFilename,
+		jsonFilename:        ct.WeeklySalesJSONFilename,
+		fetch:               ct.FetchWeeklySales,
+		socrata:             &ct.WeeklySalesConfig,
+		dbInsert:            ct.DBInsertWeeklySales,
+		dbTable:             "ct_weekly_sales",
+		fetchIncremental:    ct.FetchWeeklySalesIncremental,
+		dbUpsert:            ct.DBUpsertWeeklySales,
+		clean:               cleanSales,
+		cleanReportFilename: ct.WeeklySalesCleanReportFilename,
+		contentKey:          func(s ct.WeeklySales) string { return s.WeekEnding },
+		lite: &litePreset{
+			fields:  []string{"unnamed_column", "adult_use", "medical", "total"},
+			columns: []string{"week_ending", "adult_use", "medical", "total"},
+		},
+	},
+	&dataset[ct.Tax]{
+		name:             "tax",
+		cadence:          31 * 24 * time.Hour,
+		label:            "tax records",
+		source:           "ct",
+		cacheFilename:    ct.TaxJSONFilename,
+		csvFilename:      ct.TaxCSVFilename,
+		jsonFilename:     ct.TaxJSONFilename,
+		fetch:            ct.FetchTax,
+		socrata:          &ct.TaxConfig,
+		dbInsert:         ct.DBInsertTax,
+		dbTable:          "ct_tax",
+		fetchIncremental: ct.FetchTaxIncremental,
+		dbUpsert:         ct.DBUpsertTax,
+		contentKey:       func(t ct.Tax) string { return t.PeriodEndDate },
+		lite: &litePreset{
+			fields:  []string{"period_end_date", "total_tax"},
+			columns: []string{"period_end_date", "total_tax"},
+		},
+	},
+	&dataset[ct.DisciplinaryAction]{
+		name:          "discipline",
+		cadence:       31 * 24 * time.Hour,
+		label:         "disciplinary actions",
+		source:        "ct",
+		cacheFilename: ct.DisciplinaryActionJSONFilename,
+		csvFilename:   ct.DisciplinaryActionCSVFilename,
+		jsonFilename:  ct.DisciplinaryActionJSONFilename,
+		optIn:         true, // requires --discipline-view
+		fetch:         ct.FetchDisciplinaryActions,
+		socrata:       &ct.DisciplinaryActionConfig,
+		dbInsert:      ct.DBInsertDisciplinaryActions,
+		dbTable:       "ct_disciplinary_actions",
+		contentKey: func(a ct.DisciplinaryAction) string {
+			return ct.NormalizeLicenseNumber(a.LicenseNumber) + "/" + a.ActionDate
+		},
+		lite: &litePreset{
+			fields:  []string{"license_number", "name", "action_type", "action_date"},
+			columns: []string{"license_number", "name", "action_type", "action_date"},
+		},
+	},
+}
+
+// datasetNames returns the names of all registered datasets, in processing order
+func datasetNames() []string {
+	names := make([]string, len(datasetRegistry))
+	for i, d := range datasetRegistry {
+		names[i] = d.Name()
+	}
+	return names
+}
+
+// isDatasetName returns true if name is a registered dataset
+func isDatasetName(name string) bool {
+	for _, d := range datasetRegistry {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reserved --dataset names, selecting every dataset or none
+const (
+	datasetsAll  = "all"
+	datasetsNone = "none"
+)
+
+// selectDatasets returns the set of datasets selected by --dataset names, expanding "all" to
+// every registered dataset and "none" to no datasets.  Names are case-insensitive.
+// Returns an error if "all" or "none" is given with any other name.
+func selectDatasets(names []string) (map[string]bool, error) {
+	selected := make(map[string]bool)
+	for _, name := range names {
+		selected[strings.ToLower(name)] = true
+	}
+	for _, reserved := range []string{datasetsAll, datasetsNone} {
+		if selected[reserved] && len(selected) > 1 {
+			return nil, fmt.Errorf("%q cannot be combined with other datasets", reserved)
+		}
+	}
+	switch {
+	case selected[datasetsAll]:
+		selected = make(map[string]bool)
+		for _, name := range datasetNames() {
+			selected[name] = true
+		}
+	case selected[datasetsNone]:
+		selected = make(map[string]bool)
+	}
+	return selected, nil
+}
+
+// defaultDatasetNames returns the names of the datasets processed when --dataset is not given
+func defaultDatasetNames() []string {
+	var names []string
+	for _, d := range datasetRegistry {
+		if !d.OptIn() {
+			names = append(names, d.Name())
+		}
+	}
+	return names
+}
+
+// datasetSources returns the sources of the registered datasets, each once, in order
+func datasetSources() []string {
+	var names []string
+	for _, d := range datasetRegistry {
+		if !slices.Contains(names, d.Source()) {
+			names = append(names, d.Source())
+		}
+	}
+	return names
+}
+
+// Name returns the dataset name used on the command line
+func (d *dataset[T]) Name() string {
+	return d.name
+}
+
+// OptIn returns true if the dataset is not processed by default
+func (d *dataset[T]) OptIn() bool {
+	return d.optIn
+}
+
+// CacheFilename returns the name of the dataset's cache file
+func (d *dataset[T]) CacheFilename() string {
+	return d.cacheFilename
+}
+
+// CheckCache checks that the dataset's cache, or its --lite cache if lite, is present and
+// no older than maxAge, for --cache-only.  Returns a *sources.CacheError if not.
+func (d *dataset[T]) CheckCache(lite bool, maxAge time.Duration) error {
+	if lite && d.lite != nil {
+		return sources.CacheStatus(d.liteCacheName(), maxAge)
+	}
+	return sources.CacheStatus(d.cacheFilename, maxAge)
+}
+
+// Cadence returns how often the dataset is expected to update upstream
+func (d *dataset[T]) Cadence() time.Duration {
+	return d.cadence
+}
+
+// DBTable returns the DuckDB table the dataset is loaded into
+func (d *dataset[T]) DBTable() string {
+	return d.dbTable
+}
+
+// Source returns the source whose app token fetches the dataset, e.g. "ct"
+func (d *dataset[T]) Source() string {
+	return d.source
+}
+
+// VerifyCache checks that every record of the dataset's cache decodes, for "cache verify".
+// Returns the number of records that decoded, and an error, if any.
+func (d *dataset[T]) VerifyCache(strict bool) (int, error) {
+	return sources.VerifyCacheFile[T](d.cacheFilename, strict)
+}
+
+// Sample fetches (or loads from cache) the dataset and writes its first n records to w, for --sample
+func (d *dataset[T]) Sample(opts processOpts, n int, w io.Writer) error {
+	badRecords := d.collectBadRecords(opts)
+	defer d.trackProvenance(opts)()
+	defer d.collectCacheEvents(opts)()
+	items, err := d.fetchOrLoad(d.fetch, opts)
+	if bad := badRecords(); len(bad) > 0 {
+		log.Printf("Skipped %d %s that did not decode", len(bad), d.label)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", d.label, err)
+	}
+	return writeSample(w, d.name, items, n)
+}
+
+// Dictionary describes each of the dataset's columns, for --data-dictionary
+func (d *dataset[T]) Dictionary() ([]sources.DictionaryEntry, error) {
+	var ddl string
+	for _, table := range ct.DuckDBTables {
+		if table.Name == d.dbTable {
+			ddl = table.DDL
+		}
+	}
+	return sources.Dictionary[T](d.name, ddl)
+}
+
+// Process fetches (or loads from cache), cleans, exports, and inserts the dataset into DuckDB.
+// Returns the list of output files created.
+func (d *dataset[T]) Process(opts processOpts) ([]string, error) {
+	if opts.verbose {
+		log.Printf("Fetching CT %s data...", d.name)
+	}
+
+	// With --source-file, the dataset is read from that file rather than fetched or cached
+	sourceFile := opts.sourceFiles[d.name]
+	if sourceFile != "" && opts.verbose {
+		log.Printf("Reading CT %s from %s", d.label, sourceFile)
+	}
+
+	// With --incremental, datasets that support it fetch only what's new since their watermark
+	incremental := opts.incremental && d.fetchIncremental != nil && sourceFile == ""
+	fetch := d.fetch
+	if incremental {
+		fetch = func(appToken string, _ time.Duration) ([]T, error) {
+			return d.fetchIncremental(appToken)
+		}
+	} else if opts.incremental && opts.verbose {
+		log.Printf("CT %s do not support incremental fetches, fetching in full", d.label)
+	}
+
+	// With --lite, datasets with a preset fetch only its fields, into a cache of their own
+	if opts.lite && d.lite != nil {
+		defer d.useLite()()
+	}
+
+	// With --stream, datasets that support it are cleaned and written to JSON as they are fetched
+	if opts.stream && d.stream != nil && !opts.noFetch && sourceFile == "" {
+		return d.processStreaming(opts)
+	}
+
+	stop := opts.timer.Start(d.name, phaseFetch)
+	logPages := d.collectPageStats(opts)
+	logCacheEvents := d.collectCacheEvents(opts)
+	badRecords := d.collectBadRecords(opts)
+	recordProvenance := d.trackProvenance(opts)
+	items, err := d.fetchOrLoad(fetch, opts)
+	bad := badRecords()
+	logPages()
+	logCacheEvents()
+	recordProvenance()
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", d.label, err)
+	}
+	if opts.verbose {
+		log.Printf("Loaded %d %s", len(items), d.label)
+	}
+
+	var cleanReports []sources.CleanReport
+	if d.clean != nil {
+		stop = opts.timer.Start(d.name, phaseClean)
+		loaded := len(items)
+		items, cleanReports = d.clean(items, opts)
+		stop()
+		d.logCleaned(loaded, len(items), opts)
+	}
+
+	// Measures only carry their precision for export, so DuckDB still gets full precision
+	items = withMeasurePrecision(items, opts.measurePrec)
+
+	// Export files
+	files, err := exportFiles(d.name, items, d.csvFilename, d.jsonFilename, d.exportOpts(opts))
+	if err != nil {
+		return nil, err
+	}
+	badFiles, err := d.exportBadRecords(bad, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.finishProcess(items, cleanReports, append(files, badFiles...), incremental, opts)
+}
+
+// fetchOrLoad reads the dataset's records from its --source-file, if it has one,
+// and otherwise fetches them with fetch or loads them from the cache
+func (d *dataset[T]) fetchOrLoad(fetch func(string, time.Duration) ([]T, error), opts processOpts) ([]T, error) {
+	var bad *sources.BadRecords
+	if d.socrata != nil {
+		bad = d.socrata.BadRecords
+	}
+	if sourceFile := opts.sourceFiles[d.name]; sourceFile != "" {
+		return sources.ReadJSONFile[T](sourceFile, bad)
+	}
+	return fetchOrLoadCache(d.source, d.cacheFilename, fetch, bad, opts)
+}
+
+// processStreaming is Process for --stream: the records are fetched a page at a time with d.stream,
+// and each page is cleaned and written to the JSON export as it arrives, before the other exports.
+func (d *dataset[T]) processStreaming(opts processOpts) ([]string, error) {
+	jsonFilename, err := renderName(d.jsonFilename, opts)
+	if err != nil {
+		return nil, err
+	}
+	jw := sources.NewChunkedJSONWriter[T](filepath.Join(opts.outputDir, jsonFilename), opts.jsonChunks)
+
+	batches := make(chan []T)
+	fetchErr := make(chan error, 1)
+	go func() {
+		fetchErr <- d.stream(opts.appTokens.TokenForSource(d.source), opts.maxCacheAge, batches)
+	}()
+
+	// The fetch phase includes cleaning and writing each page
+	stop := opts.timer.Start(d.name, phaseFetch)
+	logPages := d.collectPageStats(opts)
+	logCacheEvents := d.collectCacheEvents(opts)
+	badRecords := d.collectBadRecords(opts)
+	recordProvenance := d.trackProvenance(opts)
+	var items []T
+	var cleanReports []sources.CleanReport
+	var writeErr error
+	fetched := 0
+	for batch := range batches {
+		if writeErr != nil {
+			continue // drain the stream, so the fetch can finish
+		}
+		fetched += len(batch)
+		if d.clean != nil {
+			var reports []sources.CleanReport
+			batch, reports = d.clean(batch, opts)
+			cleanReports = append(cleanReports, reports...)
+		}
+		batch = withMeasurePrecision(batch, opts.measurePrec)
+		writeErr = jw.Write(batch)
+		items = append(items, batch...)
+	}
+	chunks, closeErr := jw.Close()
+	stop()
+	err = <-fetchErr
+	bad := badRecords()
+	logPages()
+	logCacheEvents()
+	recordProvenance()
+	if err != nil {
+		for _, chunk := range chunks {
+			os.Remove(chunk.Filename)
+		}
+		return nil, fmt.Errorf("failed to fetch %s: %w", d.label, err)
+	}
+	if err := cmp.Or(writeErr, closeErr); err != nil {
+		return nil, fmt.Errorf("failed to write JSON: %w", failedJSONExport(d.name, chunks, err, opts))
+	}
+	if opts.verbose {
+		log.Printf("Streamed %d %s", fetched, d.label)
+	}
+	if d.clean != nil {
+		d.logCleaned(fetched, len(items), opts)
+	}
+
+	// The CSV export and any Google Sheet are written once every record is fetched
+	files, err := exportFiles(d.name, items, d.csvFilename, "", d.exportOpts(opts))
+	if err != nil {
+		return nil, err
+	}
+	jsonFiles, err := finishJSONExport(d.name, chunks, opts)
+	if err != nil {
+		return nil, err
+	}
+	badFiles, err := d.exportBadRecords(bad, opts)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, jsonFiles...)
+	return d.finishProcess(items, cleanReports, append(files, badFiles...), false, opts)
+}
+
+// finishProcess is the rest of Process once the dataset's own files are exported:
+// the combined JSON and clean report, any extra exports, and the DuckDB insert.
+// Records fetched incrementally are upserted.  Returns files plus any other output files created.
+func (d *dataset[T]) finishProcess(items []T, cleanReports []sources.CleanReport, files []string, incremental bool, opts processOpts) ([]string, error) {
+	stop := opts.timer.Start(d.name, phaseExport)
+	reportFiles, err := d.exportCombinedAndReport(items, cleanReports, opts)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, reportFiles...)
+
+	statsFiles, err := d.exportStats(items, opts)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, statsFiles...)
+
+	if d.exportExtra != nil {
+		extraFiles, err := d.exportExtra(items, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, extraFiles...)
+	}
+
+	parquetFiles, err := d.exportParquet(len(items), opts)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, parquetFiles...)
+
+	contentHash := d.contentHash(items, opts)
+	stop = opts.timer.Start(d.name, phaseDBInsert)
+	_, err = d.loadDB(items, contentHash, incremental, opts)
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert %s: %w", d.label, err)
+	}
+
+	opts.manifest.RecordContentHash(d.name, len(items), contentHash)
+
+	// Keep the records if a cross-dataset report asked for them
+	if _, ok := opts.processed[d.name]; ok {
+		opts.processed[d.name] = items
+	}
+
+	if opts.verbose {
+		log.Printf("Processed %d %s", len(items), d.label)
+	}
+
+	return files, nil
+}
+
+// contentHash returns the content hash of the records, which is that of their full-precision
+// measures whatever their --measure-precision, as the precision is only how they are exported
+func (d *dataset[T]) contentHash(items []T, opts processOpts) string {
+	if opts.measurePrec != ct.DefaultMeasurePrecision {
+		items = slices.Clone(items)
+		for i := range items {
+			if p, ok := any(items[i]).(measurePrecisioner[T]); ok {
+				items[i] = p.WithMeasurePrecision(ct.DefaultMeasurePrecision)
+			}
+		}
+	}
+	return sources.ContentHash(items, d.contentKey)
+}
+
+// loadDB inserts the records into DuckDB, unless its table was left out by --tables or the
+// records' contentHash is that of their last load, upserting incremental fetches so no prior
+// rows are dropped, or else bulk loading the CSV export if it has full precision.
+// Returns true if the records were loaded, which records their contentHash.
+func (d *dataset[T]) loadDB(items []T, contentHash string, incremental bool, opts processOpts) (bool, error) {
+	if !opts.dbTables[d.dbTable] {
+		if opts.verbose {
+			log.Printf("Skipped loading %s, as %s is not one of the --tables", d.label, d.dbTable)
+		}
+		return false, nil
+	}
+	if loadedHash, err := db.LoadedContentHash(opts.conn, d.name); err == nil && loadedHash == contentHash {
+		if opts.verbose {
+			log.Printf("Skipped loading %s into %s, unchanged since the last load", d.label, d.dbTable)
+		}
+		return false, nil
+	}
+
+	var err error
+	if incremental {
+		err = d.dbUpsert(opts.conn, items)
+	} else if opts.bulkLoad && len(items) > 0 && opts.measurePrec == ct.DefaultMeasurePrecision && len(d.exportOpts(opts).csv.Columns) == 0 {
+		err = d.bulkLoad(opts)
+	} else {
+		err = d.dbInsert(opts.conn, items)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, db.RecordLoad(opts.conn, d.name, len(items), contentHash)
+}
+
+// logCleaned logs, if verbose, how many records clean removed of those it was given
+func (d *dataset[T]) logCleaned(before int, after int, opts processOpts) {
+	if opts.verbose {
+		log.Printf("Cleaned %s: %d -> %d (removed %d erroneous records)", d.label, before, after, before-after)
+	}
+}
+
+// collectPageStats has the dataset's fetches collect the stats of each page they request, if verbose,
+// returning the function that logs them once the fetch is done.  Loading from the cache requests no pages.
+func (d *dataset[T]) collectPageStats(opts processOpts) func() {
+	if !opts.verbose || d.socrata == nil {
+		return func() {}
+	}
+	stats := &sources.FetchStats{}
+	d.socrata.Stats = stats
+	return func() {
+		d.socrata.Stats = nil
+		for _, page := range stats.Pages() {
+			log.Printf("Page dataset=%s offset=%d rows=%d bytes=%d duration=%s status=%d retries=%d",
+				d.name, page.Offset, page.Rows, page.Bytes, page.Duration.Round(time.Millisecond), page.StatusCode, page.Retries)
+		}
+	}
+}
+
+// collectCacheEvents has the dataset's fetches collect what they did with its cache, returning
+// the function that logs them once the fetch is done: discarded caches always, and others if verbose
+func (d *dataset[T]) collectCacheEvents(opts processOpts) func() {
+	if d.socrata == nil {
+		return func() {}
+	}
+	events := &sources.CacheEvents{}
+	d.socrata.CacheEvents = events
+	return func() {
+		d.socrata.CacheEvents = nil
+		for _, event := range events.Events() {
+			switch event.Outcome {
+			case sources.CacheKept:
+				if opts.verbose {
+					log.Printf("Cache %s unchanged, not rewritten", event.Filename)
+				}
+			case sources.CacheRevalidated:
+				if opts.verbose {
+					log.Printf("Cache %s unchanged upstream, not fetched again", event.Filename)
+				}
+			case sources.CacheDiscarded:
+				log.Printf("Warning: discarding unreadable cache %s: %v", event.Filename, event.Err)
+			}
+		}
+	}
+}
+
+// collectBadRecords has the dataset's fetches and cache loads skip records that do not decode,
+// with --skip-bad-records, returning the function that stops them and returns the records skipped
+func (d *dataset[T]) collectBadRecords(opts processOpts) func() []sources.BadRecord {
+	if !opts.skipBadRecords || d.socrata == nil {
+		return func() []sources.BadRecord { return nil }
+	}
+	bad := &sources.BadRecords{}
+	d.socrata.BadRecords = bad
+	return func() []sources.BadRecord {
+		d.socrata.BadRecords = nil
+		return bad.Records()
+	}
+}
+
+// trackProvenance pins the dataset's fetches to its --revision, if any, and has them record
+// the revision they read, returning the function that stops them and adds it to the manifest.
+// Nothing is added if no page was fetched, e.g. if the records were loaded from the cache.
+func (d *dataset[T]) trackProvenance(opts processOpts) func() {
+	if d.socrata == nil {
+		return func() {}
+	}
+	provenance := &sources.Provenance{}
+	d.socrata.Revision = opts.revisions[d.name]
+	d.socrata.Provenance = provenance
+	return func() {
+		d.socrata.Revision = ""
+		d.socrata.Provenance = nil
+		if source, ok := provenance.Source(); ok && opts.manifest != nil {
+			opts.manifest.RecordSource(d.name, source)
+			if opts.verbose {
+				log.Printf("Fetched %s revision etag=%s last_modified=%s", d.label, source.ETag, source.LastModified)
+			}
+		}
+	}
+}
+
+// exportBadRecords writes the records skipped with --skip-bad-records to a report,
+// e.g. us_ct_brands_bad_records.csv, if there are any.  Returns the report's file.
+func (d *dataset[T]) exportBadRecords(bad []sources.BadRecord, opts processOpts) ([]string, error) {
+	if len(bad) == 0 {
+		return nil, nil
+	}
+	log.Printf("Skipped %d %s that did not decode", len(bad), d.label)
+	name := d.name + "_bad_records"
+	filename, err := renderName("us_ct_"+name+".csv", opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sources.WriteCSVWith(filepath.Join(opts.outputDir, filename), bad, opts.csv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write bad records report: %w", failedExport(name, filename, len(bad), rows, err, opts))
+	}
+	file, err := finishExport(name, filename, len(bad), rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []string{file}, nil
+}
+
+// exportStats writes the profile of the records, per sources.DatasetStats, to a sidecar
+// of the JSON export, e.g. us_ct_brands.stats.json, if requested with --emit-stats.
+// Returns the sidecar's file.
+func (d *dataset[T]) exportStats(items []T, opts processOpts) ([]string, error) {
+	if !opts.emitStats {
+		return nil, nil
+	}
+	filename, err := renderName(strings.TrimSuffix(d.jsonFilename, ".json")+".stats.json", opts)
+	if err != nil {
+		return nil, err
+	}
+	stats := sources.DatasetStats(items)
+	if err := sources.WriteStats(filepath.Join(opts.outputDir, filename), stats); err != nil {
+		return nil, err
+	}
+	file, err := finishExport(d.name+"_stats", filename, len(stats.Columns), len(stats.Columns), opts)
+	if err != nil {
+		return nil, err
+	}
+	return []string{file}, nil
+}
+
+// exportParquet converts the dataset's CSV export of records records to Parquet, if requested with
+// --format parquet, typed as its DuckDB table is, e.g. "us_ct_brands.parquet".  Parquet files are
+// compressed within, so are left as they are by --compress.  Datasets whose tables were left out
+// by --tables are skipped, as their types are not in the database.
+func (d *dataset[T]) exportParquet(records int, opts processOpts) ([]string, error) {
+	if !opts.formats[formatParquet] {
+		return nil, nil
+	}
+	if !opts.dbTables[d.dbTable] {
+		if opts.verbose {
+			log.Printf("Skipped Parquet export of %s, as %s is not one of the --tables", d.label, d.dbTable)
+		}
+		return nil, nil
+	}
+	csvName, err := renderName(d.csvFilename, opts)
+	if err != nil {
+		return nil, err
+	}
+	filename, err := renderName(strings.TrimSuffix(d.csvFilename, ".csv")+".parquet", opts)
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(opts.outputDir, filename)
+	stop := opts.timer.Start(d.name, phaseExport)
+	rows, err := db.WriteParquet(opts.conn, d.dbTable, filepath.Join(opts.outputDir, outputName(csvName, opts)), file)
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export %s to Parquet: %w", d.label, err)
+	}
+	if err := opts.manifest.RecordFile(d.name, filename, records, int(rows)); err != nil {
+		return nil, err
+	}
+	if opts.verbose {
+		log.Printf("Wrote %d %s rows to %s", rows, d.label, filename)
+	}
+	return []string{file}, nil
+}
+
+// useLite has the dataset's fetches request only the fields of its lite preset, with
+// a cache of their own so the full cache is kept, returning the function that restores them
+func (d *dataset[T]) useLite() func() {
+	cacheFilename, selected := d.cacheFilename, d.socrata.Select
+	d.socrata.Select = d.lite.fields
+	d.cacheFilename = d.socrata.CacheName()
+	return func() {
+		d.cacheFilename, d.socrata.Select = cacheFilename, selected
+	}
+}
+
+// exportOpts returns the options of the dataset's own CSV export, which with --lite
+// has only the columns of its lite preset
+func (d *dataset[T]) exportOpts(opts processOpts) processOpts {
+	if opts.lite && d.lite != nil {
+		opts.csv.Columns = d.lite.columns
+	}
+	return opts
+}
+
+// liteCacheName returns the name of the dataset's --lite cache, that of a fetch of its lite preset's
+// fields, e.g. "us_ct_brands_select_1a2b3c4d.json"
+func (d *dataset[T]) liteCacheName() string {
+	lite := *d.socrata
+	lite.Select = d.lite.fields
+	return lite.CacheName()
+}
+
+// bulkLoad loads the dataset's CSV export into its DuckDB table with db.DBLoadFromFile
+func (d *dataset[T]) bulkLoad(opts processOpts) error {
+	csvName, err := renderName(d.csvFilename, opts)
+	if err != nil {
+		return err
+	}
+	return db.DBLoadFromFile(opts.conn, d.dbTable, filepath.Join(opts.outputDir, outputName(csvName, opts)))
+}
+
+// exportCombinedAndReport writes the records to the combined JSON, if configured,
+// and writes the clean report, if there is one.
+// Returns the list of output files created.
+func (d *dataset[T]) exportCombinedAndReport(items []T, cleanReports []sources.CleanReport, opts processOpts) ([]string, error) {
+	if opts.combined != nil {
+		if err := sources.WriteCombinedDataset(opts.combined, d.name, items); err != nil {
+			return nil, fmt.Errorf("failed to write combined JSON: %w", err)
+		}
+	}
+
+	if len(cleanReports) == 0 {
+		return nil, nil
+	}
+	reportName, err := renderName(d.cleanReportFilename, opts)
+	if err != nil {
+		return nil, err
+	}
+	reportFile := filepath.Join(opts.outputDir, reportName)
+	rows, err := sources.WriteCSVWith(reportFile, cleanReports, opts.csv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write clean report: %w", failedExport(d.name+"_clean_report", reportName, len(cleanReports), rows, err, opts))
+	}
+	if err := opts.manifest.RecordFile(d.name+"_clean_report", reportName, len(cleanReports), rows); err != nil {
+		return nil, err
+	}
+	return []string{reportFile}, nil
+}
+
+// cleanBrands repairs out-of-range brand percentages, then removes erroneous brands,
+// then flags outliers if requested with --flag-outliers, then sorts them if requested with --sort-by
+func cleanBrands(brands []ct.Brand, opts processOpts) ([]ct.Brand, []sources.CleanReport) {
+	ct.SetCanonicalBrandNames(brands, opts.nameMode)
+
+	// Convert measures to their column's unit first, as mg/g amounts are out of range as percentages
+	var cleanReports []sources.CleanReport
+	if opts.normalizeUnits {
+		cleanReports = ct.NormalizeBrandUnits(brands)
+		if opts.verbose {
+			log.Printf("Converted %d brand measures to the unit inferred for their column", len(cleanReports))
+		}
+	}
+
+	// Repair out-of-range percentages before they get the whole brand removed
+	clampReports := ct.ClampBrandPercents(brands, opts.clampMode)
+	if opts.verbose && opts.clampMode != ct.PercentClampNone {
+		log.Printf("Repaired %d out-of-range brand percentages (%s)", len(clampReports), opts.clampMode)
+	}
+	cleanReports = append(cleanReports, clampReports...)
+
+	brands = ct.CleanBrands(brands)
+
+	if opts.outliers != ct.OutlierNone {
+		var outlierReports []sources.CleanReport
+		brands, outlierReports = ct.FlagBrandOutliers(brands, opts.sigma, opts.outliers)
+		log.Printf("Flagged %d brand measures over %g standard deviations from their mean (%s)", len(outlierReports), opts.sigma, opts.outliers)
+		cleanReports = append(cleanReports, outlierReports...)
+	}
+
+	if opts.brandSort != nil {
+		ct.SortBrandsByMeasure(brands, opts.brandSort, opts.sortDesc)
+	}
+	return brands, cleanReports
+}
+
+// cleanCredentials reports credentials whose count is missing or invalid, which are kept
+// but left out of credential summaries rather than counted as zero
+func cleanCredentials(credentials []ct.Credential, opts processOpts) ([]ct.Credential, []sources.CleanReport) {
+	cleanReports := ct.CheckCredentialCounts(credentials)
+	if len(cleanReports) > 0 {
+		log.Printf("Found %d credentials with a missing or invalid count, which are left out of summaries", len(cleanReports))
+	}
+	return credentials, cleanReports
+}
+
+// cleanSales reports weeks whose total does not reconcile with its parts, if requested with --reconcile-sales,
+// and weeks whose products sold or average prices are inconsistent, if requested with --check-sales-prices
+func cleanSales(sales []ct.WeeklySales, opts processOpts) ([]ct.WeeklySales, []sources.CleanReport) {
+	var cleanReports []sources.CleanReport
+	if opts.reconSales {
+		reports := ct.CheckSalesTotals(sales, opts.reconThresh)
+		if len(reports) > 0 {
+			log.Printf("Found %d weeks of sales whose total differs from adult use plus medical by over $%g", len(reports), opts.reconThresh)
+		}
+		cleanReports = append(cleanReports, reports...)
+	}
+	if opts.checkPrices {
+		reports := ct.CheckSalesPrices(sales, opts.pricesThresh)
+		if len(reports) > 0 {
+			log.Printf("Found %d inconsistent products sold or average prices in weekly sales", len(reports))
+		}
+		cleanReports = append(cleanReports, reports...)
+	}
+	return sales, cleanReports
+}
+
+// exportCredentialSummaries exports the credentials rolled up by type, if requested with --summarize
+func exportCredentialSummaries(credentials []ct.Credential, opts processOpts) ([]string, error) {
+	if !opts.summarize["credentials"] {
+		return nil, nil
+	}
+
+	summaries := ct.SummarizeCredentials(credentials)
+	files, err := exportFiles("credentials_summary", summaries, ct.CredentialSummaryCSVFilename, ct.CredentialSummaryJSONFilename, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export credential summaries: %w", err)
+	}
+	if opts.verbose {
+		log.Printf("Summarized %d credentials into %d credential types", len(credentials), len(summaries))
+	}
+	return files, nil
+}
+
+// exportBrandExtras writes the optional brand exports: the tidy CSV, and the COAs
+func exportBrandExtras(brands []ct.Brand, opts processOpts) ([]string, error) {
+	files, err := exportBrandTidy(brands, opts)
+	if err != nil {
+		return nil, err
+	}
+	coaFiles, err := exportBrandCOAs(brands, opts)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, coaFiles...), nil
+}
+
+// exportBrandTidy exports the brand measures in long format if requested with --format tidy-csv
+func exportBrandTidy(brands []ct.Brand, opts processOpts) ([]string, error) {
+	if !opts.formats[formatTidyCSV] {
+		return nil, nil
+	}
+
+	rows := ct.TidyBrands(brands)
+	files, err := exportFiles("brands_tidy", rows, ct.BrandTidyCSVFilename, "", opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export tidy brand measures: %w", err)
+	}
+	if opts.verbose {
+		log.Printf("Unpivoted %d brands into %d tidy measure rows", len(brands), len(rows))
+	}
+	return files, nil
+}
+
+// exportBrandCOAs downloads each brand's COA document if requested with --fetch-coa,
+// then exports the index of which file is each brand's COA
+func exportBrandCOAs(brands []ct.Brand, opts processOpts) ([]string, error) {
+	if !opts.fetchCOA {
+		return nil, nil
+	}
+
+	if opts.sandbox != "" {
+		if err := checkSandbox(opts.sandbox, filepath.Join(opts.outputDir, ct.COADirname)); err != nil {
+			return nil, fmt.Errorf("failed to fetch brand COAs: %w", err)
+		}
+	}
+
+	stop := opts.timer.Start("brands_coa", phaseFetch)
+	coas, failures, err := ct.FetchBrandCOAs(brands, opts.outputDir)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	for _, failure := range failures {
+		log.Printf("Warning: %v", failure)
+	}
+	if len(failures) > 0 {
+		log.Printf("Failed to fetch %d brand COAs, which are missing from the COA index", len(failures))
+	}
+	files, err := exportFiles("brands_coa", coas, ct.BrandCOACSVFilename, ct.BrandCOAJSONFilename, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export brand COA index: %w", err)
+	}
+	if opts.verbose {
+		log.Printf("Fetched COAs of %d brands into %s", len(coas), filepath.Join(opts.outputDir, ct.COADirname))
+	}
+	return files, nil
+}
+
+// measurePrecisioner is implemented by records whose measures can be exported at a fixed precision, such as ct.Brand
+type measurePrecisioner[T any] interface {
+	WithMeasurePrecision(decimals int) T
+}
+
+// withMeasurePrecision sets the export precision of every record's measures, in place.
+// Records without measures, or the default precision, leave items unchanged.
+func withMeasurePrecision[T any](items []T, decimals int) []T {
+	if decimals == ct.DefaultMeasurePrecision {
+		return items
+	}
+	for i := range items {
+		p, ok := any(items[i]).(measurePrecisioner[T])
+		if !ok {
+			return items
+		}
+		items[i] = p.WithMeasurePrecision(decimals)
+	}
+	return items
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AgentDank/dank-extract/internal/db"
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// registeredDataset returns the dataset of the datasetRegistry with the given name
+func registeredDataset[T datasetRecord[T]](t testing.TB, name string) *dataset[T] {
+	t.Helper()
+	for _, d := range datasetRegistry {
+		if d, ok := d.(*dataset[T]); ok && d.name == name {
+			return d
+		}
+	}
+	t.Fatalf("no %s dataset of %T", name, *new(T))
+	return nil
+}
+
+// testBrands returns brands whose THC is each of thc
+func testBrands(thc ...float64) []ct.Brand {
+	brands := make([]ct.Brand, len(thc))
+	for i, amount := range thc {
+		brands[i] = ct.Brand{BrandName: "Brand", RegistrationNumber: "BRAND-" + strconv.Itoa(i), TetrahydrocannabinolThc: ct.NewMeasure(amount)}
+	}
+	return brands
+}
+
+func TestContentHashIgnoresMeasurePrecision(t *testing.T) {
+	d := registeredDataset[ct.Brand](t, "brands")
+	want := d.contentHash(testBrands(12.3456, 0.5), processOpts{measurePrec: ct.DefaultMeasurePrecision})
+	for _, decimals := range []int{0, 2, ct.DefaultMeasurePrecision, ct.MaxMeasurePrecision} {
+		brands := withMeasurePrecision(testBrands(12.3456, 0.5), decimals)
+		if got := d.contentHash(brands, processOpts{measurePrec: decimals}); got != want {
+			t.Errorf("content hash at precision %d = %s, want %s as at the default precision", decimals, got, want)
+		}
+		if brands[0].TetrahydrocannabinolThc.Precision() != decimals {
+			t.Errorf("content hash at precision %d changed the records' precision", decimals)
+		}
+	}
+
+	// Measures that only differ below the export precision still differ in content
+	rounded := withMeasurePrecision(testBrands(12.3), 0)
+	changed := withMeasurePrecision(testBrands(12.4), 0)
+	if d.contentHash(rounded, processOpts{}) == d.contentHash(changed, processOpts{}) {
+		t.Errorf("content hash of 12.3 and 12.4 at precision 0 are the same, want them to differ")
+	}
+}
+
+func TestLoadDBSkipsUnchanged(t *testing.T) {
+	conn, err := db.Open("", db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := db.RunMigration(conn, []string{"ct_brands"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := *registeredDataset[ct.Brand](t, "brands")
+	inserts := 0
+	d.dbInsert = func(conn *sql.DB, brands []ct.Brand) error {
+		inserts++
+		return ct.DBInsertBrands(conn, brands)
+	}
+	opts := processOpts{conn: conn, dbTables: map[string]bool{"ct_brands": true}}
+
+	reordered := testBrands(12.4, 0.5, 1)
+	slices.Reverse(reordered)
+	steps := []struct {
+		name       string
+		brands     []ct.Brand
+		precision  int
+		wantLoaded bool
+	}{
+		{name: "first load", brands: testBrands(12.3, 0.5), precision: ct.DefaultMeasurePrecision, wantLoaded: true},
+		{name: "unchanged is skipped", brands: testBrands(12.3, 0.5), precision: ct.DefaultMeasurePrecision, wantLoaded: false},
+		{name: "unchanged at another precision is skipped", brands: testBrands(12.3, 0.5), precision: 0, wantLoaded: false},
+		{name: "changed below the precision reloads", brands: testBrands(12.4, 0.5), precision: 0, wantLoaded: true},
+		{name: "added record reloads", brands: testBrands(12.4, 0.5, 1), precision: ct.DefaultMeasurePrecision, wantLoaded: true},
+		{name: "reordered is skipped", brands: reordered, precision: ct.DefaultMeasurePrecision, wantLoaded: false},
+	}
+	for _, step := range steps {
+		opts.measurePrec = step.precision
+		brands := withMeasurePrecision(step.brands, step.precision)
+		before := inserts
+		loaded, err := d.loadDB(brands, d.contentHash(brands, opts), false, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if loaded != step.wantLoaded || (inserts > before) != step.wantLoaded {
+			t.Errorf("%s: loaded = %v with %d inserts, want %v", step.name, loaded, inserts-before, step.wantLoaded)
+		}
+	}
+}
+
+// outputFiles returns the contents of each file in dir, by name
+func outputFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestProcessStreamMatchesBatch(t *testing.T) {
+	// Brands over several pages, which cleaning repairs one of and removes another of
+	brands := testBrands(12.3456, 0.5, 150, 7, 0.25)
+	brands[3].BrandName = ""
+	tests := []struct {
+		name   string
+		chunks sources.ChunkLimits
+	}{
+		{name: "single file"},
+		{name: "chunked", chunks: sources.ChunkLimits{Records: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := *registeredDataset[ct.Brand](t, "brands")
+			d.socrata = nil
+			d.fetch = func(string, time.Duration) ([]ct.Brand, error) {
+				return slices.Clone(brands), nil
+			}
+			d.stream = func(_ string, _ time.Duration, batches chan<- []ct.Brand) error {
+				defer close(batches)
+				for page := range slices.Chunk(slices.Clone(brands), 2) {
+					batches <- page
+				}
+				return nil
+			}
+
+			process := func(stream bool) (map[string]string, []sources.ManifestFile) {
+				opts := processOpts{
+					outputDir:   t.TempDir(),
+					manifest:    sources.NewManifest(),
+					timer:       newPhaseTimer(false),
+					stream:      stream,
+					clampMode:   ct.PercentClampClamp,
+					jsonChunks:  tt.chunks,
+					nameTmpl:    sources.DefaultNameTemplate,
+					nameDate:    "2026-01-02",
+					measurePrec: 2,
+				}
+				if _, err := d.Process(opts); err != nil {
+					t.Fatalf("Process() with stream %v: %v", stream, err)
+				}
+				return outputFiles(t, opts.outputDir), opts.manifest.Files
+			}
+			batchFiles, batchManifest := process(false)
+			streamFiles, streamManifest := process(true)
+
+			if len(batchFiles) < 3 {
+				t.Fatalf("batch path wrote %d files, want the CSV, JSON, and clean report", len(batchFiles))
+			}
+			if !maps.Equal(streamFiles, batchFiles) {
+				t.Errorf("--stream wrote %v, want the batch path's %v", slices.Sorted(maps.Keys(streamFiles)), slices.Sorted(maps.Keys(batchFiles)))
+				for name, data := range batchFiles {
+					if streamFiles[name] != data {
+						t.Errorf("--stream %s = %q, want %q", name, streamFiles[name], data)
+					}
+				}
+			}
+			if !slices.Equal(streamManifest, batchManifest) {
+				t.Errorf("--stream manifest files = %+v, want %+v", streamManifest, batchManifest)
+			}
+		})
+	}
+}
+
+func TestSelectDatasets(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string // selected datasets, in any order
+		wantErr bool
+	}{
+		{name: "names", names: []string{"brands", "tax"}, want: []string{"brands", "tax"}},
+		{name: "names are case-insensitive", names: []string{"Brands", "TAX", "brands"}, want: []string{"brands", "tax"}},
+		{name: "all", names: []string{"all"}, want: datasetNames()},
+		{name: "all is case-insensitive", names: []string{"ALL"}, want: datasetNames()},
+		{name: "all repeated", names: []string{"all", "All"}, want: datasetNames()},
+		{name: "none", names: []string{"none"}, want: []string{}},
+		{name: "none is case-insensitive", names: []string{"None"}, want: []string{}},
+		{name: "all with a name", names: []string{"all", "brands"}, wantErr: true},
+		{name: "name with all", names: []string{"tax", "ALL"}, wantErr: true},
+		{name: "none with a name", names: []string{"none", "brands"}, wantErr: true},
+		{name: "all with none", names: []string{"all", "none"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectDatasets(tt.names)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("selectDatasets(%q) = %v, want an error", tt.names, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectDatasets(%q) = %v", tt.names, err)
+			}
+			want := make(map[string]bool)
+			for _, name := range tt.want {
+				want[name] = true
+			}
+			if !maps.Equal(got, want) {
+				t.Errorf("selectDatasets(%q) = %v, want %v", tt.names, got, want)
+			}
+		})
+	}
+
+	// "all" includes the opt-in datasets, which are only processed when selected
+	if all, err := selectDatasets([]string{"all"}); err != nil || !all["discipline"] {
+		t.Error("selectDatasets(all) is missing the opt-in discipline dataset")
+	}
+}
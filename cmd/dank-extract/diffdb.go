@@ -0,0 +1,89 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/AgentDank/dank-extract/internal/db"
+	flag "github.com/spf13/pflag"
+)
+
+// runDiffDB implements "dank-extract diff-db old.duckdb new.duckdb",
+// reporting the rows added, removed, and changed in each table.
+// Returns the exit code.
+func runDiffDB(args []string) int {
+	flags := flag.NewFlagSet("diff-db", flag.ExitOnError)
+	dumpDir := flags.String("dump", "", "Also write the differing rows of each table as CSV files to this directory")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dank-extract diff-db [options] <old.duckdb> <new.duckdb>")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	oldFile, newFile := flags.Arg(0), flags.Arg(1)
+	for _, file := range []string{oldFile, newFile} {
+		if _, err := os.Stat(file); err != nil {
+			log.Printf("Cannot read DuckDB file: %v", err)
+			return exitUsage
+		}
+	}
+
+	// An in-memory database with both files attached
+	conn, err := sql.Open("duckdb", "")
+	if err != nil {
+		log.Printf("Failed to open DuckDB: %v", err)
+		return exitError
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	if err := db.AttachForDiff(conn, oldFile, newFile); err != nil {
+		log.Printf("Failed to attach databases: %v", err)
+		return exitError
+	}
+	diffs := db.DiffTables(conn)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tADDED\tREMOVED\tCHANGED")
+	for _, diff := range diffs {
+		switch {
+		case diff.Err != nil:
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t(%v)\n", diff.Table, diff.Err)
+		case !diff.Keyed:
+			fmt.Fprintf(tw, "%s\t%d\t%d\t-\n", diff.Table, diff.Added, diff.Removed)
+		default:
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", diff.Table, diff.Added, diff.Removed, diff.Changed)
+		}
+	}
+	tw.Flush()
+
+	if *dumpDir != "" {
+		if err := os.MkdirAll(*dumpDir, 0755); err != nil {
+			log.Printf("Failed to create dump directory: %v", err)
+			return exitError
+		}
+		for _, diff := range diffs {
+			if diff.Err != nil {
+				continue
+			}
+			files, err := db.DumpTableDiff(conn, diff, *dumpDir)
+			for _, file := range files {
+				fmt.Printf("Wrote %s\n", file)
+			}
+			if err != nil {
+				log.Printf("%v", err)
+				return exitError
+			}
+		}
+	}
+	return exitOK
+}
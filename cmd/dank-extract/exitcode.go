@@ -0,0 +1,67 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// Exit codes, documented in the README so shell scripts can branch on the kind of failure
+const (
+	exitOK         = 0 // Success
+	exitError      = 1 // Generic failure
+	exitUsage      = 2 // Invalid flags or usage
+	exitNetwork    = 3 // Network or HTTP failure
+	exitAuth       = 4 // Credentials, such as the app token, were rejected
+	exitValidation = 5 // Data failed a validation or integrity check
+	exitCache      = 6 // A required cache file was missing or unreadable
+)
+
+// exitCodeFor classifies an error into the exit code for its kind of failure
+func exitCodeFor(err error) int {
+	var httpErr *sources.HTTPError
+	var urlErr *url.Error
+	var netErr net.Error
+	var validationErr *sources.ValidationError
+	var cacheErr *sources.CacheError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &httpErr):
+		if httpErr.IsAuth() {
+			return exitAuth
+		}
+		return exitNetwork
+	case errors.As(err, &cacheErr):
+		// Before net.Error, which the syscall errors of missing cache files also satisfy
+		return exitCache
+	case errors.As(err, &validationErr):
+		// Before net.Error too, as rows lost to a failed write are joined with its syscall error
+		return exitValidation
+	case errors.As(err, &urlErr), errors.As(err, &netErr):
+		return exitNetwork
+	default:
+		return exitError
+	}
+}
+
+// fatalf logs a failure with err, then calls stop, e.g. stopProfiles, and exits with the code
+// exitCodeFor classifies err as.  Unlike log.Fatalf, the exit code reflects the kind of failure.
+func fatalf(stop func(), err error, format string, v ...any) {
+	log.Printf(format, v...)
+	stop()
+	os.Exit(exitCodeFor(err))
+}
+
+// usageFatalf logs a usage error and exits with exitUsage
+func usageFatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(exitUsage)
+}
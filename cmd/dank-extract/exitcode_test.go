@@ -0,0 +1,52 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	missingCache := &os.PathError{Op: "open", Path: "us_ct_brands.json.zst", Err: syscall.ENOENT}
+	var netErr net.Error
+	if !errors.As(missingCache, &netErr) {
+		t.Fatal("a missing file's error no longer satisfies net.Error, so the ordering cases below test nothing")
+	}
+	validation := &sources.ValidationError{Msg: "row count mismatch"}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: exitOK},
+		{name: "generic", err: errors.New("failed"), want: exitError},
+		{name: "file error", err: fs.ErrPermission, want: exitError},
+		{name: "HTTP error", err: &sources.HTTPError{StatusCode: 500}, want: exitNetwork},
+		{name: "HTTP unauthorized", err: &sources.HTTPError{StatusCode: 401}, want: exitAuth},
+		{name: "HTTP forbidden", err: fmt.Errorf("failed to fetch: %w", &sources.HTTPError{StatusCode: 403}), want: exitAuth},
+		{name: "URL error", err: &url.Error{Op: "Get", URL: "https://data.ct.gov", Err: errors.New("EOF")}, want: exitNetwork},
+		{name: "net error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: exitNetwork},
+		{name: "validation", err: fmt.Errorf("failed to write CSV: %w", validation), want: exitValidation},
+		{name: "cache", err: &sources.CacheError{Kind: sources.CacheTooOld, Reason: "cache file too old"}, want: exitCache},
+		{name: "cache before net.Error", err: &sources.CacheError{Kind: sources.CacheMissing, Reason: "cache file not found", Err: missingCache}, want: exitCache},
+		{name: "validation before net.Error", err: errors.Join(validation, missingCache), want: exitValidation},
+		{name: "HTTP before URL error", err: &url.Error{Op: "Get", URL: "https://data.ct.gov", Err: &sources.HTTPError{StatusCode: 401}}, want: exitAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AgentDank/dank-extract/sources"
+	flag "github.com/spf13/pflag"
+)
+
+// redactedFlags are flags whose values are never printed
+var redactedFlags = map[string]bool{
+	"token": true,
+}
+
+// envSetting is an environment variable that sets a flag's value when the flag is not given
+type envSetting struct {
+	Name  string
+	Value func(env string) (string, bool) // the flag's value for the variable's, and whether it applies
+}
+
+// envSettings are the environment variables that stand in for flags, in order of precedence
+var envSettings = map[string][]envSetting{
+	"gsheet-creds": {
+		{Name: "GOOGLE_APPLICATION_CREDENTIALS", Value: func(env string) (string, bool) { return env, env != "" }},
+	},
+	"no-color": {
+		{Name: "NO_COLOR", Value: func(env string) (string, bool) { return "true", env != "" }},
+		{Name: "TERM", Value: func(env string) (string, bool) { return "true", env == "dumb" }},
+	},
+}
+
+// explainSetting is a resolved setting and where its value came from
+type explainSetting struct {
+	Name   string
+	Value  string
+	Source string // "flag", "env <VARIABLE>", "derived", or "default"
+}
+
+// resolveSettings collects the effective value of every flag in the set, then the app token of each
+// of tokenSources, resolved from tokens as sources.AppTokens.TokenForSource does.  Flags not given
+// that an environment variable of envSettings sets are reported with the variable as their source.
+// It must be called after all derived values (e.g. snapshot mode) are applied,
+// so that values changed by the program rather than the user are reported as "derived".
+func resolveSettings(flags *flag.FlagSet, tokens sources.AppTokens, tokenSources []string) []explainSetting {
+	var settings []explainSetting
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		setting := explainSetting{Name: f.Name, Value: f.Value.String()}
+		switch {
+		case f.Changed:
+			setting.Source = "flag"
+		case setting.Value != f.DefValue:
+			setting.Source = "derived"
+		default:
+			setting.Source = "default"
+		}
+		if !f.Changed {
+			for _, env := range envSettings[f.Name] {
+				if value, ok := env.Value(os.Getenv(env.Name)); ok {
+					setting.Value, setting.Source = value, "env "+env.Name
+					break
+				}
+			}
+		}
+		if redactedFlags[f.Name] && setting.Value != f.DefValue {
+			setting.Value = "<redacted>"
+		}
+		settings = append(settings, setting)
+	})
+	for _, source := range tokenSources {
+		settings = append(settings, resolveToken(tokens, source))
+	}
+	return settings
+}
+
+// resolveToken returns the setting of the named source's app token, e.g. "token[ct]", redacted
+func resolveToken(tokens sources.AppTokens, source string) explainSetting {
+	setting := explainSetting{Name: "token[" + source + "]", Value: "<redacted>"}
+	envName := sources.TokenEnvPrefix + strings.ToUpper(source)
+	if _, ok := tokens.BySource[strings.ToLower(source)]; ok {
+		setting.Source = "flag"
+	} else if os.Getenv(envName) != "" {
+		setting.Source = "env " + envName
+	} else if tokens.Default != "" {
+		setting.Source = "flag"
+	} else {
+		setting.Value, setting.Source = "", "default"
+	}
+	return setting
+}
+
+// explainConfig writes the resolved settings as an aligned table.
+func explainConfig(w io.Writer, settings []explainSetting) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := s.Value
+		if value == "" {
+			value = `""`
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, value, s.Source)
+	}
+	return tw.Flush()
+}
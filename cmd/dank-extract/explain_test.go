@@ -0,0 +1,122 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"testing"
+
+	"github.com/AgentDank/dank-extract/sources"
+	flag "github.com/spf13/pflag"
+)
+
+// explainFlags returns a flag set of the flags that --explain resolves from each kind of source,
+// parsed from args
+func explainFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	flags := flag.NewFlagSet("dank-extract", flag.ContinueOnError)
+	flags.StringArrayP("token", "t", nil, "")
+	flags.String("gsheet-creds", "", "")
+	flags.Bool("no-color", false, "")
+	flags.String("output", ".", "")
+	flags.String("snapshot", "", "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestResolveSettingsPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		derived map[string]string // flags changed by the program after parsing
+		want    map[string]explainSetting
+	}{
+		{
+			name: "defaults",
+			want: map[string]explainSetting{
+				"output":       {Value: ".", Source: "default"},
+				"gsheet-creds": {Value: "", Source: "default"},
+				"no-color":     {Value: "false", Source: "default"},
+				"token":        {Value: "[]", Source: "default"},
+				"token[ct]":    {Value: "", Source: "default"},
+			},
+		},
+		{
+			name:    "flags and derived values",
+			args:    []string{"--output", "out", "--token", "secret"},
+			derived: map[string]string{"snapshot": "2026-01-02"},
+			want: map[string]explainSetting{
+				"output":    {Value: "out", Source: "flag"},
+				"snapshot":  {Value: "2026-01-02", Source: "derived"},
+				"token":     {Value: "<redacted>", Source: "flag"},
+				"token[ct]": {Value: "<redacted>", Source: "flag"},
+			},
+		},
+		{
+			name: "environment over defaults",
+			env:  map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "creds.json", "NO_COLOR": "1", "DANK_TOKEN_CT": "secret"},
+			want: map[string]explainSetting{
+				"gsheet-creds": {Value: "creds.json", Source: "env GOOGLE_APPLICATION_CREDENTIALS"},
+				"no-color":     {Value: "true", Source: "env NO_COLOR"},
+				"token[ct]":    {Value: "<redacted>", Source: "env DANK_TOKEN_CT"},
+			},
+		},
+		{
+			name: "TERM=dumb disables color",
+			env:  map[string]string{"TERM": "dumb"},
+			want: map[string]explainSetting{
+				"no-color": {Value: "true", Source: "env TERM"},
+			},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"--gsheet-creds", "mine.json", "--no-color", "--token", "ct=secret"},
+			env:  map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "creds.json", "NO_COLOR": "1", "DANK_TOKEN_CT": "other"},
+			want: map[string]explainSetting{
+				"gsheet-creds": {Value: "mine.json", Source: "flag"},
+				"no-color":     {Value: "true", Source: "flag"},
+				"token[ct]":    {Value: "<redacted>", Source: "flag"},
+			},
+		},
+		{
+			name: "source's environment over the bare token",
+			args: []string{"--token", "secret"},
+			env:  map[string]string{"DANK_TOKEN_CT": "other"},
+			want: map[string]explainSetting{
+				"token[ct]": {Value: "<redacted>", Source: "env DANK_TOKEN_CT"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"GOOGLE_APPLICATION_CREDENTIALS", "NO_COLOR", "TERM", "DANK_TOKEN_CT"} {
+				t.Setenv(name, tt.env[name])
+			}
+			flags := explainFlags(t, tt.args...)
+			for name, value := range tt.derived {
+				flags.Lookup(name).Value.Set(value)
+			}
+			tokenValues, err := flags.GetStringArray("token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := sources.ParseAppTokens(tokenValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]explainSetting)
+			for _, setting := range resolveSettings(flags, tokens, []string{"ct"}) {
+				got[setting.Name] = setting
+			}
+			for name, want := range tt.want {
+				want.Name = name
+				if got[name] != want {
+					t.Errorf("%s = %+v, want %+v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// Freshness statuses of a dataset's cache
+const (
+	freshnessFresh   = "fresh"   // The cache is younger than the dataset's update cadence
+	freshnessOverdue = "overdue" // The cache is older than the cadence, so upstream has likely updated
+	freshnessMissing = "missing" // There is no cache file
+)
+
+// FreshnessRow is the freshness of one dataset's cache against its expected update cadence
+type FreshnessRow struct {
+	Dataset  string
+	Cache    string        // Cache filename
+	Modified time.Time     // Modification time of the cache file, zero if missing
+	Age      time.Duration // Age of the cache file, zero if missing
+	Cadence  time.Duration // How often the dataset is expected to update upstream
+	Status   string        // "fresh", "overdue", or "missing"
+}
+
+// FreshnessReport compares the age of each registered dataset's cache file to its update cadence.
+// A cadence in cadences overrides the dataset's default; datasets are reported in registry order.
+// It only reads cache file metadata.
+func FreshnessReport(cadences map[string]time.Duration) []FreshnessRow {
+	now := time.Now()
+	rows := make([]FreshnessRow, 0, len(datasetRegistry))
+	for _, d := range datasetRegistry {
+		row := FreshnessRow{
+			Dataset: d.Name(),
+			Cache:   d.CacheFilename(),
+			Cadence: d.Cadence(),
+			Status:  freshnessMissing,
+		}
+		if cadence, ok := cadences[d.Name()]; ok {
+			row.Cadence = cadence
+		}
+		if stat, err := os.Stat(sources.CacheFilePath(row.Cache)); err == nil {
+			row.Modified = stat.ModTime()
+			row.Age = now.Sub(row.Modified)
+			row.Status = classifyFreshness(row.Age, row.Cadence)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// classifyFreshness returns whether a cache of the given age is fresh or overdue for the cadence
+func classifyFreshness(age time.Duration, cadence time.Duration) string {
+	if age > cadence {
+		return freshnessOverdue
+	}
+	return freshnessFresh
+}
+
+// parseCadences parses --freshness-cadence values, e.g. {"sales": "72h"}.
+// Returns an error for unknown datasets or invalid durations.
+func parseCadences(values map[string]string) (map[string]time.Duration, error) {
+	cadences := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		if !isDatasetName(name) {
+			return nil, fmt.Errorf("unknown dataset %q", name)
+		}
+		cadence, err := time.ParseDuration(value)
+		if err != nil || cadence <= 0 {
+			return nil, fmt.Errorf("invalid cadence %q for %s", value, name)
+		}
+		cadences[name] = cadence
+	}
+	return cadences, nil
+}
+
+// writeFreshnessReport writes the freshness rows as an aligned table.
+func writeFreshnessReport(w io.Writer, rows []FreshnessRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATASET\tSTATUS\tAGE\tCADENCE\tMODIFIED")
+	for _, row := range rows {
+		age, modified := "-", "-"
+		if row.Status != freshnessMissing {
+			age = row.Age.Round(time.Minute).String()
+			modified = row.Modified.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", row.Dataset, row.Status, age, row.Cadence, modified)
+	}
+	return tw.Flush()
+}
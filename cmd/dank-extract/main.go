@@ -5,58 +5,213 @@
 package main
 
 import (
+	"cmp"
+	"crypto/ed25519"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/AgentDank/dank-extract/internal/db"
 	"github.com/AgentDank/dank-extract/sources"
 	"github.com/AgentDank/dank-extract/sources/us/ct"
-	"github.com/klauspost/compress/zstd"
 	flag "github.com/spf13/pflag"
 )
 
-var availableDatasets = []string{
-	"brands",
+var availableDatasets = datasetNames()
+
+const (
+	formatTidyCSV = "tidy-csv" // formatTidyCSV is the --format exporting brand measures in long format, with ct.TidyBrands
+	formatParquet = "parquet"  // formatParquet is the --format also exporting each dataset to Parquet, with db.WriteParquet
+)
+
+// manifestFilename is the built-in name of the manifest describing each run's output files,
+// which is rendered through the name template like the other output files
+const manifestFilename = "us_ct_manifest.json"
+
+// summarizableDatasets are the datasets that support a rolled-up --summarize export
+var summarizableDatasets = []string{
 	"credentials",
-	"applications",
-	"sales",
-	"tax",
 }
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "diff-db" {
+		os.Exit(runDiffDB(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCache(os.Args[2:]))
+	}
+
 	// CLI flags
 	var (
-		appToken     string
+		tokenFlags   []string
 		rootDir      string
 		outputDir    string
 		dbFile       string
 		datasets     []string
+		tables       []string
+		where        string
+		lite         bool
+		formats      []string
+		summarize    []string
+		clampMode    string
+		normUnits    bool
+		nameMode     string
+		outlierMode  string
+		outlierSigma float64
+		retryBudget  int
+		reconSales   bool
+		reconThresh  float64
+		checkPrices  bool
+		pricesThresh float64
+		retryPerSet  int
+		pageTimeout  time.Duration
+		maxRPS       float64
+		httpCacheDir string
+		httpCacheTTL time.Duration
+		sortBy       string
+		sortDesc     bool
+		gsheetID     string
+		gsheetCreds  string
+		disciplineID string
+		combinedFile string
 		snapshotDir  string
 		snapshotDate string
+		compareSrcs  bool
 		noFetch      bool
+		skipBad      bool
+		cacheOnly    bool
+		forceFetch   bool
+		keepMtime    bool
+		strictCache  bool
+		incremental  bool
+		stream       bool
+		recreateDB   bool
+		appendDB     bool
+		bulkLoad     bool
+		duckMemLimit string
+		duckThreads  int
+		duckMaxConns int
+		fetchCOA     bool
+		explain      bool
+		freshness    bool
+		dictFile     string
+		stateFile    string
+		emitStats    bool
+		sandboxDir   string
+		cadenceFlags map[string]string
+		sourceFiles  map[string]string
+		revisions    map[string]string
+		dryRun       bool
+		sampleN      int
 		compress     bool
+		codecName    string
+		compressExt  string
+		crlf         bool
+		utf8BOM      bool
+		chunkSize    int
+		chunkBytes   int64
+		nameTemplate string
+		measurePrec  int
+		signKeyFile  string
+		historyFile  string
 		verbose      bool
+		noColor      bool
+		cpuProfile   string
+		memProfile   string
 		showHelp     bool
 		maxCacheAge  time.Duration
 	)
 
-	flag.StringVarP(&appToken, "token", "t", "", "ct.data.gov App Token")
+	flag.StringArrayVarP(&tokenFlags, "token", "t", nil, "Socrata App Token, either for all sources or a source's own as <source>=<token>, e.g. ct=XXX")
 	flag.StringVar(&rootDir, "root", ".", "Root directory for .dank data")
 	flag.StringVarP(&outputDir, "output", "o", "", "Output directory for exports (default: current directory)")
 	flag.StringVar(&dbFile, "db", "", "DuckDB file path (default: dank-data.duckdb)")
-	flag.StringSliceVarP(&datasets, "dataset", "d", availableDatasets, "Datasets to fetch (brands,credentials,applications,sales,tax)")
+	flag.StringSliceVarP(&datasets, "dataset", "d", defaultDatasetNames(), "Datasets to fetch ("+strings.Join(availableDatasets, ",")+"), or 'all' or 'none'")
+	flag.StringSliceVar(&tables, "tables", nil, "DuckDB tables to create and load, e.g. ct_brands,ct_tax (default: the selected datasets' tables)")
+	flag.StringVar(&where, "where", "", "Also export the rows of each selected dataset's DuckDB table matching this SQL predicate, e.g. \"tetrahydrocannabinol_thc > 20\"")
+	flag.BoolVar(&lite, "lite", false, "Fetch and export only each dataset's commonly-wanted columns, e.g. brand names and key cannabinoids")
+	flag.StringSliceVar(&formats, "format", nil, "Additional export formats: 'tidy-csv' for brand measures in long format, one row per brand and measure, and 'parquet' for a typed Parquet file of each dataset")
+	flag.StringSliceVar(&summarize, "summarize", nil, "Datasets to also export as rolled-up summaries (credentials)")
+	flag.StringVar(&clampMode, "clamp-percents", "", "Repair out-of-range brand percentages: 'clamp' to 100 or 'drop' to empty")
+	flag.Lookup("clamp-percents").NoOptDefVal = string(ct.PercentClampClamp)
+	flag.BoolVar(&normUnits, "normalize-units", false, "Convert brand measures reported in another unit, e.g. mg/g in a column mostly in percent, to the unit inferred for their column")
+	flag.StringVar(&nameMode, "normalize-names", "", "Also export each brand's name normalized, as canonical_name: 'keep' its case or 'title' case it")
+	flag.Lookup("normalize-names").NoOptDefVal = string(ct.BrandNameKeep)
+	flag.StringVar(&outlierMode, "flag-outliers", "", "Flag brand measures that are statistical outliers: 'report' them or also 'drop' their brands")
+	flag.Lookup("flag-outliers").NoOptDefVal = string(ct.OutlierReport)
+	flag.Float64Var(&outlierSigma, "outlier-sigma", 5, "Standard deviations from a measure's mean beyond which --flag-outliers flags it")
+	flag.StringVar(&sortBy, "sort-by", "", "Sort brands by a measure column, e.g. 'cannabidiols_cbd', or 'total_thc'")
+	flag.BoolVar(&sortDesc, "sort-desc", false, "Sort brands in descending order, highest first")
+	flag.BoolVar(&reconSales, "reconcile-sales", false, "Report weeks of sales whose total differs from adult use plus medical by more than --reconcile-sales-threshold")
+	flag.Float64Var(&reconThresh, "reconcile-sales-threshold", 1, "Dollars a week's sales total may differ from adult use plus medical before --reconcile-sales reports it")
+	flag.BoolVar(&checkPrices, "check-sales-prices", false, "Report weeks of sales whose products sold do not add up, or whose average prices differ from revenue over products sold by more than --check-sales-prices-threshold")
+	flag.Float64Var(&pricesThresh, "check-sales-prices-threshold", 0.05, "Fraction of the reported average price that the implied average may differ by before --check-sales-prices reports it")
+	flag.BoolVar(&compareSrcs, "compare-sources", false, "Reconcile monthly sales against tax, requires the sales and tax datasets")
+	flag.StringVar(&disciplineID, "discipline-view", "", "data.ct.gov view ID (e.g. abcd-1234) of the disciplinary actions dataset, required for --dataset discipline")
+	flag.StringVar(&gsheetID, "gsheet", "", "Google Sheets spreadsheet ID to also write each dataset to, one tab per dataset")
+	flag.StringVar(&gsheetCreds, "gsheet-creds", "", "Google service account key file (default: $GOOGLE_APPLICATION_CREDENTIALS)")
+	flag.StringVar(&combinedFile, "combined", "", "Also write the selected datasets to a single JSON file, keyed by dataset name")
+	flag.BoolVar(&emitStats, "emit-stats", false, "Also write a profile of each dataset, its empty rates, ranges, and distinct values, to a .stats.json sidecar of its JSON export")
 	flag.StringVarP(&snapshotDir, "snapshot", "s", "", "Create snapshot in directory (e.g., ./snapshots)")
 	flag.StringVar(&snapshotDate, "snapshot-date", "", "Snapshot date in YYYY-MM-DD format (default: today)")
+	flag.StringToStringVar(&sourceFiles, "source-file", nil, "Read datasets from local JSON files shaped like the API's responses, rather than fetching or caching them, e.g. brands=brands.json")
+	flag.StringToStringVar(&revisions, "revision", nil, "Pin datasets to a revision, such as an ETag recorded in a previous manifest, failing their fetch if they have been republished since, e.g. brands=abc123")
+	flag.BoolVar(&skipBad, "skip-bad-records", false, "Skip records that do not decode, listing them in a _bad_records.csv report, rather than failing the dataset")
 	flag.BoolVarP(&noFetch, "no-fetch", "n", false, "Don't fetch data, use existing cache")
-	flag.BoolVarP(&compress, "compress", "c", false, "Compress output files with zstd")
+	flag.StringVar(&stateFile, "state-file", "", "Record each dataset as it completes in this file, and skip the datasets it records as completed, to resume an interrupted run")
+	flag.BoolVar(&cacheOnly, "cache-only", false, "Don't fetch data, failing unless every selected dataset has a cache no older than --max-cache-age")
+	flag.BoolVar(&forceFetch, "force-fetch", false, "Always fetch data, ignoring any cache regardless of age")
+	flag.BoolVar(&keepMtime, "keep-cache-mtime", false, "Leave the modification time of caches a fetch left unchanged, rather than marking them freshly fetched")
+	flag.BoolVar(&strictCache, "fail-on-unreadable-cache", false, "Fail a dataset whose cache is there but cannot be read or decoded, rather than warning and fetching it again")
+	flag.BoolVar(&incremental, "incremental", false, "Fetch only records newer than each dataset's last run (sales, tax), upserting them into the cache and DuckDB")
+	flag.BoolVar(&stream, "stream", false, "Clean and write brands to JSON a page at a time as they are fetched, reducing peak memory")
+	flag.BoolVar(&recreateDB, "recreate-db", false, "Drop and recreate all DuckDB tables before loading, for a clean snapshot")
+	flag.BoolVar(&appendDB, "append-db", false, "Load into existing DuckDB tables, keeping prior rows (the default)")
+	flag.BoolVar(&bulkLoad, "db-bulk-load", false, "Load DuckDB tables from the CSV exports in bulk, rather than row-by-row")
+	flag.StringVar(&duckMemLimit, "duckdb-memory-limit", "", "DuckDB memory limit, e.g. '4GB' (default: DuckDB's)")
+	flag.IntVar(&duckThreads, "duckdb-threads", 0, "DuckDB worker threads (default: DuckDB's)")
+	flag.IntVar(&duckMaxConns, "duckdb-max-conns", 0, "Maximum open DuckDB connections (default: unlimited, or 1 with --db-bulk-load)")
+	flag.BoolVar(&fetchCOA, "fetch-coa", false, "Also download each brand's lab analysis (COA) document into a coa/ subdirectory of the output")
+	flag.BoolVar(&freshness, "freshness", false, "Report each dataset's cache age against its update cadence, then exit")
+	flag.StringVar(&dictFile, "data-dictionary", "", "Write a data dictionary of every dataset's columns to this CSV file, or JSON if it ends in .json, then exit")
+	flag.StringToStringVar(&cadenceFlags, "freshness-cadence", nil, "Override dataset update cadences for --freshness, e.g. sales=72h,tax=720h")
+	flag.BoolVar(&explain, "explain", false, "Print the resolved configuration and where each value came from")
+	flag.IntVar(&sampleN, "sample", 0, "Print this many records of each selected dataset to stderr for inspection, then exit")
+	flag.BoolVar(&dryRun, "dry-run", false, "Resolve the configuration, then exit without fetching or writing")
+	flag.BoolVarP(&compress, "compress", "c", false, "Compress output files")
+	flag.StringVar(&codecName, "compression", string(sources.CodecZstd), "Compression codec for --compress: 'zstd' or 'gzip'")
+	flag.StringVar(&compressExt, "output-compression-extension", "", "Extension for compressed files (default: the codec's, '.zst' or '.gz')")
+	flag.StringVar(&sandboxDir, "sandbox", "", "Fail rather than write anywhere outside this directory, including templated output names, caches, and the DuckDB file")
+	flag.StringVar(&nameTemplate, "name-template", sources.DefaultNameTemplate, "Go text/template for output file names, with {{.Source}} {{.State}} {{.Dataset}} {{.Date}} {{.Ext}}")
+	flag.IntVar(&measurePrec, "measure-precision", ct.DefaultMeasurePrecision, "Decimals to export measures with in CSV and JSON; DuckDB keeps full precision")
+	flag.StringVar(&signKeyFile, "sign-key", "", "Ed25519 private key PEM file to sign the manifest with, written alongside it with a .sig extension")
+	flag.IntVar(&chunkSize, "chunk-size", 0, "Split each JSON export into numbered files of at most this many records, e.g. us_ct_brands.0001.json")
+	flag.Int64Var(&chunkBytes, "chunk-bytes", 0, "Split each JSON export into numbered files of at most this many bytes, before compression")
+	flag.StringVar(&historyFile, "append-manifest-history", "", "Also append the manifest as a line of this NDJSON file, recording every run (default file: <root>/.dank/"+sources.ManifestHistoryFilename+")")
+	flag.Lookup("append-manifest-history").NoOptDefVal = "-"
+	flag.BoolVar(&crlf, "crlf", false, "End CSV rows with CRLF (\\r\\n) rather than LF, for Windows consumers")
+	flag.BoolVar(&utf8BOM, "utf8-bom", false, "Begin CSV files with a UTF-8 byte order mark, so Excel shows accented characters correctly")
 	flag.BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
-	flag.DurationVar(&maxCacheAge, "max-cache-age", 24*time.Hour, "Maximum age of cached data before re-fetching")
+	flag.BoolVar(&noColor, "no-color", false, "Don't colorize log output, which is colorized when stderr is a terminal and NO_COLOR is unset")
+	flag.IntVar(&retryBudget, "retry-budget", 0, "Retry failed page requests (network errors, HTTP 429 and 5xx) up to this many times across the whole run (default: no retries)")
+	flag.IntVar(&retryPerSet, "retry-per-dataset", 3, "Most retries any one dataset may spend of --retry-budget before it fails (0 for no cap)")
+	flag.DurationVar(&pageTimeout, "page-timeout", 60*time.Second, "Fail, and retry per --retry-budget, any page request taking longer than this (0 for the HTTP client's 30s timeout)")
+	flag.Float64Var(&maxRPS, "max-requests-per-second", 0, "Most page requests per second across every dataset of the run, e.g. 0.5 for one every 2 seconds (0 for no limit)")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the run to this file, for 'go tool pprof'")
+	flag.StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file at the end of the run, for 'go tool pprof'")
+	flag.StringVar(&httpCacheDir, "http-cache-dir", "", "Cache HTTP responses in this directory, per their cache headers, for development runs (default: no HTTP cache)")
+	flag.DurationVar(&httpCacheTTL, "http-cache-ttl", time.Hour, "How long --http-cache-dir serves responses whose headers do not say how long they are fresh")
+	flag.DurationVar(&maxCacheAge, "max-cache-age", 24*time.Hour, "Maximum age of cached data before re-fetching (0 for no limit)")
 	flag.BoolVarP(&showHelp, "help", "h", false, "Show help")
 
 	flag.Parse()
@@ -65,8 +220,14 @@ func main() {
 		fmt.Println("dank-extract - Cannabis data fetching, cleaning, and export tool")
 		fmt.Println()
 		fmt.Println("Usage: dank-extract [options]")
+		fmt.Println("       dank-extract diff-db [options] <old.duckdb> <new.duckdb>")
+		fmt.Println("       dank-extract verify [options] <dir>")
+		fmt.Println("       dank-extract cache <head|tail> [options] <dataset>")
+		fmt.Println("       dank-extract cache verify [options] [dataset...]")
 		fmt.Println()
 		fmt.Println("Available datasets: " + strings.Join(availableDatasets, ", "))
+		fmt.Println("Default datasets: " + strings.Join(defaultDatasetNames(), ", "))
+		fmt.Println("Summarizable datasets: " + strings.Join(summarizableDatasets, ", "))
 		fmt.Println()
 		fmt.Println("Snapshot mode:")
 		fmt.Println("  Use --snapshot to create a dated snapshot directory structure:")
@@ -76,10 +237,182 @@ func main() {
 		os.Exit(0)
 	}
 
+	if useColor(noColor) {
+		log.SetOutput(newColorLogWriter(os.Stderr, log.Flags(), availableDatasets))
+	}
+
+	// Paths given outright are checked before anything is written; output names once rendered
+	if sandboxDir != "" {
+		if historyFile == "-" {
+			historyFile = "" // the default, under --root, which is checked below
+		}
+		if err := checkSandbox(sandboxDir, cpuProfile, memProfile, httpCacheDir, combinedFile, dictFile, historyFile, stateFile); err != nil {
+			usageFatalf("Invalid --sandbox: %v", err)
+		}
+	}
+
+	// Profiles cover the rest of the run, and are written by stopProfiles before each exit
+	stopProfiles, err := startProfiles(cpuProfile, memProfile)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(exitCodeFor(err))
+	}
+
+	timer := newPhaseTimer(verbose)
+	sources.TouchUnchangedCache = !keepMtime
+	sources.FailOnUnreadableCache = strictCache
+
+	switch ct.PercentClampMode(clampMode) {
+	case ct.PercentClampNone, ct.PercentClampClamp, ct.PercentClampDrop:
+	default:
+		usageFatalf("Invalid --clamp-percents mode %q (expected 'clamp' or 'drop')", clampMode)
+	}
+	switch ct.BrandNameMode(nameMode) {
+	case ct.BrandNameNone, ct.BrandNameKeep, ct.BrandNameTitle:
+	default:
+		usageFatalf("Invalid --normalize-names mode %q (expected 'keep' or 'title')", nameMode)
+	}
+	switch ct.OutlierMode(outlierMode) {
+	case ct.OutlierNone, ct.OutlierReport, ct.OutlierDrop:
+	default:
+		usageFatalf("Invalid --flag-outliers mode %q (expected 'report' or 'drop')", outlierMode)
+	}
+	formatSet := make(map[string]bool)
+	for _, format := range formats {
+		switch format {
+		case formatTidyCSV, formatParquet:
+		default:
+			usageFatalf("Invalid --format %q (expected '%s' or '%s')", format, formatTidyCSV, formatParquet)
+		}
+		formatSet[format] = true
+	}
+	if reconThresh < 0 {
+		usageFatalf("--reconcile-sales-threshold must not be negative")
+	}
+	if pricesThresh < 0 {
+		usageFatalf("--check-sales-prices-threshold must not be negative")
+	}
+	if outlierSigma <= 0 {
+		usageFatalf("--outlier-sigma must be positive")
+	}
+
+	if retryBudget < 0 || retryPerSet < 0 {
+		usageFatalf("--retry-budget and --retry-per-dataset must not be negative")
+	}
+	if retryBudget > 0 {
+		sources.DefaultRetryBudget = &sources.RetryBudget{Total: retryBudget, PerFetch: retryPerSet, Delay: time.Second}
+	}
+
+	if pageTimeout < 0 {
+		usageFatalf("--page-timeout must not be negative")
+	}
+	sources.DefaultPageTimeout = pageTimeout
+	if pageTimeout > 0 {
+		sources.DefaultHTTPTimeout = 0 // --page-timeout bounds each page request instead
+	}
+
+	if maxRPS < 0 {
+		usageFatalf("--max-requests-per-second must not be negative")
+	}
+	if maxRPS > 0 {
+		sources.DefaultLimiter = sources.NewRateLimiter(maxRPS)
+	}
+
+	if httpCacheDir != "" {
+		if err := os.MkdirAll(httpCacheDir, 0755); err != nil {
+			fatalf(stopProfiles, err, "Failed to create HTTP cache directory: %v", err)
+		}
+		sources.DefaultTransport = &sources.HTTPCache{Dir: httpCacheDir, TTL: httpCacheTTL, Transport: sources.DefaultTransport}
+	}
+
+	var brandSort func(ct.Brand) ct.Measure
+	if sortBy != "" {
+		var err error
+		if brandSort, err = ct.BrandMeasureSelector(sortBy); err != nil {
+			usageFatalf("Invalid --sort-by: %v", err)
+		}
+	}
+
+	codec, err := sources.ParseCodec(codecName)
+	if err != nil {
+		usageFatalf("Invalid --compression: %v", err)
+	}
+	if compressExt == "" {
+		compressExt = codec.Extension()
+	} else if !strings.HasPrefix(compressExt, ".") {
+		compressExt = "." + compressExt
+	}
+
+	appTokens, err := sources.ParseAppTokens(tokenFlags)
+	if err != nil {
+		usageFatalf("Invalid --token: %v", err)
+	}
+
+	if measurePrec < 0 || measurePrec > ct.MaxMeasurePrecision {
+		usageFatalf("Invalid --measure-precision %d (expected 0 to %d)", measurePrec, ct.MaxMeasurePrecision)
+	}
+
+	if err := sources.ValidateNameTemplate(nameTemplate); err != nil {
+		usageFatalf("Invalid --name-template: %v", err)
+	}
+
+	var signKey ed25519.PrivateKey
+	if signKeyFile != "" {
+		if signKey, err = sources.LoadSigningKey(signKeyFile); err != nil {
+			usageFatalf("Invalid --sign-key: %v", err)
+		}
+	}
+
+	if noFetch && forceFetch {
+		usageFatalf("--no-fetch and --force-fetch are mutually exclusive")
+	}
+	if cacheOnly && (noFetch || forceFetch || incremental) {
+		usageFatalf("--cache-only is mutually exclusive with --no-fetch, --force-fetch, and --incremental")
+	}
+	if forceFetch {
+		maxCacheAge = sources.AlwaysFetch
+	}
+	if stream && sortBy != "" {
+		usageFatalf("--stream cannot be combined with --sort-by, which needs every brand before export")
+	}
+	if stream && outlierMode != "" {
+		usageFatalf("--stream cannot be combined with --flag-outliers, which needs every brand before export")
+	}
+	if incremental && (noFetch || forceFetch) {
+		usageFatalf("--incremental is mutually exclusive with --no-fetch and --force-fetch")
+	}
+
+	if recreateDB && appendDB {
+		usageFatalf("--recreate-db and --append-db are mutually exclusive")
+	}
+	if chunkSize < 0 || chunkBytes < 0 {
+		usageFatalf("--chunk-size and --chunk-bytes must not be negative")
+	}
+	if duckThreads < 0 || duckMaxConns < 0 {
+		usageFatalf("--duckdb-threads and --duckdb-max-conns must not be negative")
+	}
+
 	// Setup
 	sources.SetDankRoot(rootDir)
-	if err := sources.EnsureDankRoot(); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+
+	if freshness {
+		cadences, err := parseCadences(cadenceFlags)
+		if err != nil {
+			usageFatalf("Invalid --freshness-cadence: %v", err)
+		}
+		if err := writeFreshnessReport(os.Stdout, FreshnessReport(cadences)); err != nil {
+			fatalf(stopProfiles, err, "Failed to write freshness report: %v", err)
+		}
+		stopProfiles()
+		os.Exit(0)
+	}
+
+	if dictFile != "" {
+		if err := writeDataDictionary(dictFile); err != nil {
+			fatalf(stopProfiles, err, "Failed to write data dictionary: %v", err)
+		}
+		stopProfiles()
+		os.Exit(0)
 	}
 
 	// Handle snapshot mode
@@ -91,347 +424,727 @@ func main() {
 		outputDir = filepath.Join(snapshotDir, "us", "ct", snapshotDate)
 		dbFile = filepath.Join(outputDir, "dank-data.duckdb")
 		compress = true // Always compress in snapshot mode
-
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("Failed to create snapshot directory: %v", err)
-		}
-		if verbose {
-			log.Printf("Snapshot mode: output to %s", outputDir)
-		}
 	}
 
 	if outputDir == "" {
 		outputDir = "."
 	}
 
+	// Output names are dated by the snapshot, if there is one
+	nameDate := snapshotDate
+	if nameDate == "" {
+		nameDate = time.Now().Format("2006-01-02")
+	}
+
 	if dbFile == "" {
 		dbFile = "dank-data.duckdb"
 	}
 
-	// Convert datasets to a set for easy lookup
-	datasetSet := make(map[string]bool)
-	for _, d := range datasets {
-		datasetSet[strings.ToLower(d)] = true
+	if sandboxDir != "" {
+		if err := checkSandbox(sandboxDir, sources.GetDankDir(), outputDir, dbFile); err != nil {
+			usageFatalf("Invalid --sandbox: %v", err)
+		}
 	}
 
-	// Open DuckDB connection
-	conn, err := sql.Open("duckdb", dbFile)
-	if err != nil {
-		log.Fatalf("Failed to open DuckDB: %v", err)
+	if explain {
+		if err := explainConfig(os.Stdout, resolveSettings(flag.CommandLine, appTokens, datasetSources())); err != nil {
+			fatalf(stopProfiles, err, "Failed to explain configuration: %v", err)
+		}
+	}
+	if dryRun {
+		stopProfiles()
+		os.Exit(0)
 	}
 
-	if err := db.RunMigration(conn); err != nil {
-		log.Fatalf("Failed to run migration: %v", err)
+	if err := sources.EnsureDankRoot(); err != nil {
+		fatalf(stopProfiles, err, "Failed to create data directory: %v", err)
 	}
 
-	// Processing options passed to each processor
-	opts := processOpts{
-		appToken:    appToken,
-		maxCacheAge: maxCacheAge,
-		outputDir:   outputDir,
-		conn:        conn,
-		noFetch:     noFetch,
-		compress:    compress,
-		verbose:     verbose,
+	if err := sources.EnsureOutputDir(outputDir); err != nil {
+		fatalf(stopProfiles, err, "%v", err)
+	}
+	if snapshotDir != "" && verbose {
+		log.Printf("Snapshot mode: output to %s", outputDir)
 	}
 
-	var outputFiles []string
+	// Convert datasets to a set for easy lookup, expanding "all" and "none"
+	datasetSet, err := selectDatasets(datasets)
+	if err != nil {
+		usageFatalf("Invalid --dataset: %v", err)
+	}
 
-	// Process each selected dataset
-	processors := map[string]func(processOpts) ([]string, error){
-		"brands":       processBrands,
-		"credentials":  processCredentials,
-		"applications": processApplications,
-		"sales":        processWeeklySales,
-		"tax":          processTax,
+	if datasetSet["discipline"] {
+		if disciplineID == "" {
+			usageFatalf("--dataset discipline requires --discipline-view")
+		}
+		if err := ct.SetDisciplinaryActionsView(disciplineID); err != nil {
+			usageFatalf("Invalid --discipline-view: %v", err)
+		}
 	}
 
-	for _, name := range availableDatasets {
-		if !datasetSet[name] {
-			continue
+	// Only the selected datasets' tables are created and loaded, unless --tables says otherwise
+	if len(tables) == 0 {
+		for _, d := range datasetRegistry {
+			if datasetSet[d.Name()] {
+				tables = append(tables, d.DBTable())
+			}
 		}
-		processor := processors[name]
-		files, err := processor(opts)
-		if err != nil {
-			log.Printf("Error processing %s: %v", name, err)
-		} else {
-			outputFiles = append(outputFiles, files...)
+	}
+	tableSet := make(map[string]bool)
+	for _, table := range tables {
+		if !slices.Contains(ct.DuckDBTableNames(), table) {
+			usageFatalf("Invalid --tables: unknown table %q, expected one of: %s", table, strings.Join(ct.DuckDBTableNames(), ", "))
 		}
+		tableSet[table] = true
 	}
 
-	// Close database connection before compressing (ensures all writes are flushed)
-	if err := conn.Close(); err != nil {
-		log.Fatalf("Failed to close DuckDB: %v", err)
+	for name, file := range sourceFiles {
+		if !datasetSet[name] {
+			usageFatalf("Invalid --source-file: dataset %q is not selected with --dataset", name)
+		}
+		if _, err := os.Stat(file); err != nil {
+			usageFatalf("Invalid --source-file: %v", err)
+		}
+	}
+	for name, revision := range revisions {
+		if !datasetSet[name] {
+			usageFatalf("Invalid --revision: dataset %q is not selected with --dataset", name)
+		}
+		if revision == "" {
+			usageFatalf("Invalid --revision: empty revision for dataset %q", name)
+		}
+		if sourceFiles[name] != "" {
+			usageFatalf("Invalid --revision: dataset %q is read from its --source-file", name)
+		}
 	}
 
-	// Compress DuckDB if requested
-	if compress {
-		if err := compressFile(dbFile); err != nil {
-			log.Fatalf("Failed to compress DuckDB: %v", err)
+	// With --cache-only, every selected dataset must have a fresh cache before any is processed
+	if cacheOnly {
+		var unusable []string
+		for _, d := range datasetRegistry {
+			if !datasetSet[d.Name()] || sourceFiles[d.Name()] != "" {
+				continue
+			}
+			if err := d.CheckCache(lite, maxCacheAge); err != nil {
+				unusable = append(unusable, fmt.Sprintf("%s (%v)", d.Name(), err))
+			}
 		}
-		os.Remove(dbFile)
-		outputFiles = append(outputFiles, dbFile+".zst")
-		if verbose {
-			log.Printf("Compressed DuckDB to %s.zst", dbFile)
+		if len(unusable) > 0 {
+			log.Printf("--cache-only: no usable cache for %s", strings.Join(unusable, ", "))
+			stopProfiles()
+			os.Exit(exitCache)
 		}
-	} else {
-		outputFiles = append(outputFiles, dbFile)
+		noFetch = true // the caches were checked, so may be read as they are
 	}
 
-	// Summary
-	fmt.Println("Successfully processed CT cannabis datasets")
-	fmt.Println("Output files:")
-	for _, f := range outputFiles {
-		fmt.Printf("  - %s\n", f)
+	if where != "" {
+		if err := db.ValidatePredicate(where); err != nil {
+			usageFatalf("Invalid --where: %v", err)
+		}
 	}
-}
 
-// processOpts holds common options for all dataset processors
-type processOpts struct {
-	appToken    string
-	maxCacheAge time.Duration
-	outputDir   string
-	conn        *sql.DB
-	noFetch     bool
-	compress    bool
-	verbose     bool
-}
+	// Cross-dataset reports record which datasets' records they need kept after processing
+	processed := make(map[string]any)
+	if compareSrcs {
+		if !datasetSet["sales"] || !datasetSet["tax"] {
+			usageFatalf("--compare-sources requires the sales and tax datasets")
+		}
+		processed["sales"], processed["tax"] = nil, nil
+	}
 
-// exportFiles writes data to CSV and JSON files, with optional compression.
-// Returns the list of output files created.
-func exportFiles[T sources.CSVExportable](data []T, csvFilename, jsonFilename string, opts processOpts) ([]string, error) {
-	var files []string
+	// With --state-file, datasets that an interrupted run completed are skipped
+	runState := &sources.RunState{}
+	if stateFile != "" {
+		var err error
+		if runState, err = sources.ReadRunState(stateFile); err != nil {
+			fatalf(stopProfiles, err, "Failed to read --state-file: %v", err)
+		}
+	}
 
-	// Export to CSV
-	csvFile := filepath.Join(opts.outputDir, csvFilename)
-	if err := sources.WriteCSV(csvFile, data); err != nil {
-		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	if sampleN > 0 {
+		sampleOpts := processOpts{appTokens: appTokens, maxCacheAge: maxCacheAge, noFetch: noFetch, sourceFiles: sourceFiles, revisions: revisions, skipBadRecords: skipBad, timer: timer}
+		exitCode := exitOK
+		for _, d := range datasetRegistry {
+			if !datasetSet[d.Name()] {
+				continue
+			}
+			if err := d.Sample(sampleOpts, sampleN, os.Stderr); err != nil {
+				log.Printf("Error sampling %s: %v", d.Name(), err)
+				if exitCode == exitOK {
+					exitCode = exitCodeFor(err)
+				}
+			}
+		}
+		stopProfiles()
+		os.Exit(exitCode)
 	}
-	if opts.compress {
-		if err := compressFile(csvFile); err != nil {
-			return nil, fmt.Errorf("failed to compress CSV: %w", err)
+
+	summarizeSet := make(map[string]bool)
+	for _, d := range summarize {
+		d = strings.ToLower(d)
+		if !slices.Contains(summarizableDatasets, d) {
+			usageFatalf("Cannot summarize dataset %q (supported: %s)", d, strings.Join(summarizableDatasets, ", "))
 		}
-		os.Remove(csvFile)
-		files = append(files, csvFile+".zst")
-	} else {
-		files = append(files, csvFile)
+		summarizeSet[d] = true
 	}
 
-	// Export to JSON
-	jsonFile := filepath.Join(opts.outputDir, jsonFilename)
-	if err := sources.WriteJSON(jsonFile, data); err != nil {
-		return nil, fmt.Errorf("failed to write JSON: %w", err)
+	var sheets *sources.SheetsClient
+	if gsheetID != "" {
+		var err error
+		if sheets, err = sources.NewSheetsClient(gsheetID, gsheetCreds); err != nil {
+			fatalf(stopProfiles, err, "Failed to set up Google Sheets: %v", err)
+		}
 	}
-	if opts.compress {
-		if err := compressFile(jsonFile); err != nil {
-			return nil, fmt.Errorf("failed to compress JSON: %w", err)
+
+	var combined *sources.CombinedJSONWriter
+	if combinedFile != "" {
+		var err error
+		if combined, err = sources.NewCombinedJSONWriter(combinedFile); err != nil {
+			fatalf(stopProfiles, err, "Failed to create combined JSON: %v", err)
 		}
-		os.Remove(jsonFile)
-		files = append(files, jsonFile+".zst")
-	} else {
-		files = append(files, jsonFile)
 	}
 
-	return files, nil
-}
+	// Open DuckDB connection; bulk loads run their DDL on a single pinned connection
+	dbOpts := db.Options{MemoryLimit: duckMemLimit, Threads: duckThreads, MaxOpenConns: duckMaxConns}
+	if bulkLoad {
+		dbOpts.MaxOpenConns = 1
+	}
+	conn, err := db.Open(dbFile, dbOpts)
+	if err != nil {
+		fatalf(stopProfiles, err, "%v", err)
+	}
+	if verbose {
+		for _, pragma := range dbOpts.Pragmas() {
+			log.Printf("Applied %s to %s", pragma, dbFile)
+		}
+	}
 
-// fetchOrLoadCache fetches data from API or loads from cache based on noFetch flag
-func fetchOrLoadCache[T any](
-	cacheFilename string,
-	fetchFunc func(string, time.Duration) ([]T, error),
-	opts processOpts,
-) ([]T, error) {
-	if opts.noFetch {
-		cacheBytes, err := sources.CheckCacheFile(cacheFilename, 0)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load cache: %w", err)
+	if recreateDB {
+		if err := db.DropTables(conn); err != nil {
+			fatalf(stopProfiles, err, "Failed to drop tables: %v", err)
 		}
-		var data []T
-		if err := json.Unmarshal(cacheBytes, &data); err != nil {
-			return nil, fmt.Errorf("failed to parse cached data: %w", err)
+		if verbose {
+			log.Printf("Dropped existing tables in %s", dbFile)
 		}
-		return data, nil
 	}
-	return fetchFunc(opts.appToken, opts.maxCacheAge)
-}
 
-func processBrands(opts processOpts) ([]string, error) {
-	if opts.verbose {
-		log.Println("Fetching CT brands data...")
+	if err := db.RunMigration(conn, tables); err != nil {
+		fatalf(stopProfiles, err, "Failed to run migration: %v", err)
 	}
 
-	brands, err := fetchOrLoadCache(ct.BrandJSONFilename, ct.FetchBrands, opts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch brands: %w", err)
-	}
-	if opts.verbose {
-		log.Printf("Loaded %d brands", len(brands))
+	// Processing options passed to each processor
+	opts := processOpts{
+		timer:          timer,
+		appTokens:      appTokens,
+		maxCacheAge:    maxCacheAge,
+		outputDir:      outputDir,
+		conn:           conn,
+		dbTables:       tableSet,
+		summarize:      summarizeSet,
+		formats:        formatSet,
+		lite:           lite,
+		clampMode:      ct.PercentClampMode(clampMode),
+		normalizeUnits: normUnits,
+		nameMode:       ct.BrandNameMode(nameMode),
+		outliers:       ct.OutlierMode(outlierMode),
+		sigma:          outlierSigma,
+		reconSales:     reconSales,
+		reconThresh:    reconThresh,
+		checkPrices:    checkPrices,
+		pricesThresh:   pricesThresh,
+		brandSort:      brandSort,
+		sortDesc:       sortDesc,
+		sheets:         sheets,
+		combined:       combined,
+		manifest:       sources.NewManifest(),
+		processed:      processed,
+		noFetch:        noFetch,
+		sourceFiles:    sourceFiles,
+		revisions:      revisions,
+		skipBadRecords: skipBad,
+		emitStats:      emitStats,
+		incremental:    incremental,
+		stream:         stream,
+		compress:       compress,
+		codec:          codec,
+		compressExt:    compressExt,
+		csv:            sources.CSVOptions{CRLF: crlf, BOM: utf8BOM},
+		jsonChunks:     sources.ChunkLimits{Records: chunkSize, Bytes: chunkBytes},
+		nameTmpl:       nameTemplate,
+		sandbox:        sandboxDir,
+		measurePrec:    measurePrec,
+		bulkLoad:       bulkLoad,
+		fetchCOA:       fetchCOA,
+		nameDate:       nameDate,
+		verbose:        verbose,
 	}
 
-	// Clean brands (specific to this dataset)
-	originalCount := len(brands)
-	brands = ct.CleanBrands(brands)
-	if opts.verbose {
-		log.Printf("Cleaned brands: %d -> %d (removed %d erroneous records)",
-			originalCount, len(brands), originalCount-len(brands))
+	outputFiles, exitCode, err := processDatasets(datasetRegistry, datasetSet, runState, stateFile, opts)
+	if err != nil {
+		fatalf(stopProfiles, err, "Failed to write --state-file: %v", err)
 	}
 
-	// Export files
-	files, err := exportFiles(brands, ct.BrandCSVFilename, ct.BrandJSONFilename, opts)
-	if err != nil {
-		return nil, err
+	if compareSrcs {
+		files, err := exportReconciliation(opts)
+		if err != nil {
+			log.Printf("Error reconciling sales and tax: %v", err)
+			if exitCode == exitOK {
+				exitCode = exitCodeFor(err)
+			}
+		} else {
+			outputFiles = append(outputFiles, files...)
+		}
 	}
 
-	// Insert into DuckDB (specific to this dataset)
-	if err := ct.DBInsertBrands(opts.conn, brands); err != nil {
-		return nil, fmt.Errorf("failed to insert brands: %w", err)
+	if combined != nil {
+		if err := combined.Close(); err != nil {
+			fatalf(stopProfiles, err, "Failed to write combined JSON: %v", err)
+		}
+		outputFiles = append(outputFiles, combinedFile)
 	}
 
-	if opts.verbose {
-		log.Printf("Processed %d brands", len(brands))
+	// Close database connection before compressing (ensures all writes are flushed)
+	if err := conn.Close(); err != nil {
+		fatalf(stopProfiles, err, "Failed to close DuckDB: %v", err)
 	}
 
-	return files, nil
-}
+	// Rows matching --where are exported from the loaded tables, read-only so the predicate cannot modify them
+	if where != "" {
+		whereConn, err := db.Open(dbFile, db.Options{ReadOnly: true})
+		if err != nil {
+			fatalf(stopProfiles, err, "%v", err)
+		}
+		for _, d := range datasetRegistry {
+			if !datasetSet[d.Name()] || !tableSet[d.DBTable()] {
+				continue
+			}
+			files, err := exportWhere(whereConn, d.Name(), d.DBTable(), where, opts)
+			if err != nil {
+				log.Printf("Error exporting %s where %s: %v", d.Name(), where, err)
+				if exitCode == exitOK {
+					exitCode = exitCodeFor(err)
+				}
+			} else {
+				outputFiles = append(outputFiles, files...)
+			}
+		}
+		if err := whereConn.Close(); err != nil {
+			fatalf(stopProfiles, err, "Failed to close DuckDB: %v", err)
+		}
+	}
 
-func processCredentials(opts processOpts) ([]string, error) {
-	if opts.verbose {
-		log.Println("Fetching CT credentials data...")
+	// Compress DuckDB if requested
+	if compress {
+		stop := timer.Start("duckdb", phaseCompress)
+		compressed, err := compressFile(dbFile, opts)
+		stop()
+		if err != nil {
+			fatalf(stopProfiles, err, "Failed to compress DuckDB: %v", err)
+		}
+		outputFiles = append(outputFiles, compressed)
+		if verbose {
+			log.Printf("Compressed DuckDB to %s", compressed)
+		}
+	} else {
+		outputFiles = append(outputFiles, dbFile)
 	}
 
-	credentials, err := fetchOrLoadCache(ct.CredentialJSONFilename, ct.FetchCredentials, opts)
+	// The manifest is written last, so it has the timings of every phase and the final files' checksums
+	timer.Record(opts.manifest)
+	if err := opts.manifest.RecordChecksums(outputDir); err != nil {
+		fatalf(stopProfiles, err, "Failed to checksum output files: %v", err)
+	}
+	manifestName, err := renderName(manifestFilename, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch credentials: %w", err)
+		fatalf(stopProfiles, err, "Failed to name manifest: %v", err)
 	}
-	if opts.verbose {
-		log.Printf("Loaded %d credentials", len(credentials))
+	manifestFile := filepath.Join(outputDir, manifestName)
+	if err := sources.WriteManifest(manifestFile, opts.manifest); err != nil {
+		fatalf(stopProfiles, err, "Failed to write manifest: %v", err)
 	}
-
-	files, err := exportFiles(credentials, ct.CredentialCSVFilename, ct.CredentialJSONFilename, opts)
-	if err != nil {
-		return nil, err
+	outputFiles = append(outputFiles, manifestFile)
+	if signKey != nil {
+		sig, err := sources.SignManifest(*opts.manifest, signKey)
+		if err != nil {
+			fatalf(stopProfiles, err, "Failed to sign manifest: %v", err)
+		}
+		sigFile := manifestFile + sources.SignatureExtension
+		if err := sources.WriteSignature(sigFile, sig); err != nil {
+			fatalf(stopProfiles, err, "Failed to write manifest signature: %v", err)
+		}
+		outputFiles = append(outputFiles, sigFile)
+	}
+	if historyFile != "" {
+		if historyFile == "-" {
+			historyFile = filepath.Join(sources.GetDankDir(), sources.ManifestHistoryFilename)
+		}
+		if err := sources.AppendManifestHistory(historyFile, opts.manifest); err != nil {
+			fatalf(stopProfiles, err, "Failed to append manifest history: %v", err)
+		}
+		if verbose {
+			log.Printf("Appended manifest to %s", historyFile)
+		}
 	}
 
-	if err := ct.DBInsertCredentials(opts.conn, credentials); err != nil {
-		return nil, fmt.Errorf("failed to insert credentials: %w", err)
+	// Once every dataset has completed, the next run starts afresh
+	if stateFile != "" && exitCode == exitOK {
+		if err := os.Remove(stateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove --state-file: %v", err)
+		}
 	}
 
-	if opts.verbose {
-		log.Printf("Processed %d credentials", len(credentials))
+	// Summary
+	if exitCode == exitOK {
+		fmt.Println("Successfully processed CT cannabis datasets")
+	} else {
+		fmt.Println("Processed CT cannabis datasets, with errors")
+	}
+	fmt.Println("Output files:")
+	for _, f := range outputFiles {
+		fmt.Printf("  - %s\n", f)
 	}
+	if verbose {
+		fmt.Println("Timings:")
+		if err := timer.WriteSummary(os.Stdout); err != nil {
+			log.Printf("Failed to write timings: %v", err)
+		}
+	}
+	stopProfiles()
+	os.Exit(exitCode)
+}
 
-	return files, nil
+// processOpts holds common options for all dataset processors
+type processOpts struct {
+	appTokens      sources.AppTokens
+	timer          *phaseTimer
+	maxCacheAge    time.Duration
+	outputDir      string
+	conn           *sql.DB
+	dbTables       map[string]bool // DuckDB tables that were created, which datasets are loaded into
+	bulkLoad       bool            // load DuckDB from the CSV exports with db.DBLoadFromFile
+	fetchCOA       bool            // download brands' COA documents with ct.FetchBrandCOAs
+	summarize      map[string]bool
+	formats        map[string]bool // additional export formats, e.g. formatTidyCSV
+	lite           bool            // fetch and export only the columns of each dataset's lite preset
+	clampMode      ct.PercentClampMode
+	normalizeUnits bool                      // convert brand measures to the unit inferred for their column
+	nameMode       ct.BrandNameMode          // how to derive the canonical names of brands, if at all
+	outliers       ct.OutlierMode            // how to treat brand measures that are statistical outliers
+	sigma          float64                   // standard deviations from the mean beyond which a measure is an outlier
+	reconSales     bool                      // report weeks of sales whose total does not reconcile
+	reconThresh    float64                   // dollars a sales total may be off before it is reported
+	checkPrices    bool                      // report weeks of sales whose products sold or average prices are inconsistent
+	pricesThresh   float64                   // fraction an average price may be off before it is reported
+	brandSort      func(ct.Brand) ct.Measure // nil to keep the API order
+	sortDesc       bool
+	sheets         *sources.SheetsClient
+	combined       *sources.CombinedJSONWriter
+	manifest       *sources.Manifest
+	processed      map[string]any // records of datasets kept for cross-dataset reports, by name
+	noFetch        bool
+	sourceFiles    map[string]string // local JSON files that datasets are read from instead, by name
+	revisions      map[string]string // revisions that dataset fetches are pinned to, by name
+	skipBadRecords bool              // skip records that do not decode, reporting them, rather than failing
+	emitStats      bool              // write a .stats.json profile alongside each dataset's exports
+	incremental    bool              // fetch only what's new since each dataset's watermark, and upsert it
+	stream         bool              // fetch, clean, and write JSON a page at a time, for datasets that support it
+	compress       bool
+	codec          sources.Codec
+	compressExt    string // extension of compressed files, including the leading '.'
+	csv            sources.CSVOptions
+	jsonChunks     sources.ChunkLimits // limits that split JSON exports into numbered files
+	nameTmpl       string              // template for output file names, see sources.RenderName
+	nameDate       string              // date of output file names, in YYYY-MM-DD format
+	sandbox        string              // directory that every output must be within, or empty for no limit
+	measurePrec    int                 // decimals that measures are exported with
+	verbose        bool
 }
 
-func processApplications(opts processOpts) ([]string, error) {
-	if opts.verbose {
-		log.Println("Fetching CT applications data...")
-	}
+// processDatasets processes each of the datasets selected in datasetSet, in order, unless runState,
+// read from stateFile, records that it completed.  Datasets whose records a cross-dataset report
+// needs are processed again, to have their records.  With a stateFile, each dataset is recorded in
+// it as it completes.  Returns the output files, including those of the skipped datasets, and the
+// exit code of the first dataset that failed, which does not stop the others.  Returns an error
+// only if the stateFile cannot be written.
+func processDatasets(datasets []datasetProcessor, datasetSet map[string]bool, runState *sources.RunState, stateFile string, opts processOpts) ([]string, int, error) {
+	var outputFiles []string
+	exitCode := exitOK // exit code of the first failure, if any
+	for _, d := range datasets {
+		if !datasetSet[d.Name()] {
+			continue
+		}
+		if _, needed := opts.processed[d.Name()]; runState.IsComplete(d.Name()) && !needed {
+			log.Printf("Skipping %s, completed by a previous run per %s", d.Name(), stateFile)
+			for _, file := range runState.Restore(d.Name(), opts.manifest) {
+				outputFiles = append(outputFiles, filepath.Join(opts.outputDir, file.Filename))
+			}
+			continue
+		}
 
-	applications, err := fetchOrLoadCache(ct.ApplicationJSONFilename, ct.FetchApplications, opts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch applications: %w", err)
-	}
-	if opts.verbose {
-		log.Printf("Loaded %d applications", len(applications))
+		// Each dataset's manifest entries are collected apart, for the state file
+		datasetOpts := opts
+		datasetOpts.manifest = sources.NewManifest()
+		files, err := d.Process(datasetOpts)
+		opts.manifest.Append(datasetOpts.manifest)
+		if err != nil {
+			log.Printf("Error processing %s: %v", d.Name(), err)
+			if exitCode == exitOK {
+				exitCode = exitCodeFor(err)
+			}
+			continue
+		}
+		outputFiles = append(outputFiles, files...)
+		if stateFile != "" {
+			runState.Complete(d.Name(), datasetOpts.manifest)
+			if err := sources.WriteRunState(stateFile, runState); err != nil {
+				return outputFiles, exitCode, err
+			}
+		}
 	}
+	return outputFiles, exitCode, nil
+}
+
+// exportFiles writes the named dataset to CSV and JSON files, with optional compression,
+// and to its Google Sheets tab if one is configured.
+// An empty jsonFilename skips the JSON file, as when it was already written by --stream.
+// Returns the list of output files created.
+func exportFiles[T sources.CSVExportable](name string, data []T, csvFilename, jsonFilename string, opts processOpts) ([]string, error) {
+	var files []string
 
-	files, err := exportFiles(applications, ct.ApplicationCSVFilename, ct.ApplicationJSONFilename, opts)
+	csvFilename, err := renderName(csvFilename, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := ct.DBInsertApplications(opts.conn, applications); err != nil {
-		return nil, fmt.Errorf("failed to insert applications: %w", err)
+	if opts.sheets != nil {
+		stop := opts.timer.Start(name, phaseExport)
+		err := sources.WriteGoogleSheet(opts.sheets, name, data)
+		stop()
+		if err != nil {
+			return nil, fmt.Errorf("failed to write Google Sheet: %w", err)
+		}
+		if opts.verbose {
+			log.Printf("Wrote %d %s rows to Google Sheet", len(data), name)
+		}
 	}
 
-	if opts.verbose {
-		log.Printf("Processed %d applications", len(applications))
+	// Export to CSV
+	csvFile := filepath.Join(opts.outputDir, csvFilename)
+	stop := opts.timer.Start(name, phaseExport)
+	csvRows, err := sources.WriteCSVWith(csvFile, data, opts.csv)
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", failedExport(name, csvFilename, len(data), csvRows, err, opts))
 	}
+	csvFile, err = finishExport(name, csvFilename, len(data), csvRows, opts)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, csvFile)
 
-	return files, nil
-}
-
-func processWeeklySales(opts processOpts) ([]string, error) {
-	if opts.verbose {
-		log.Println("Fetching CT weekly sales data...")
+	if jsonFilename == "" {
+		return files, nil
 	}
 
-	sales, err := fetchOrLoadCache(ct.WeeklySalesJSONFilename, ct.FetchWeeklySales, opts)
+	// Export to JSON
+	jsonFilename, err = renderName(jsonFilename, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch weekly sales: %w", err)
+		return nil, err
 	}
-	if opts.verbose {
-		log.Printf("Loaded %d weekly sales", len(sales))
+	jsonFile := filepath.Join(opts.outputDir, jsonFilename)
+	stop = opts.timer.Start(name, phaseExport)
+	jw := sources.NewChunkedJSONWriter[T](jsonFile, opts.jsonChunks)
+	writeErr := jw.Write(data)
+	chunks, closeErr := jw.Close()
+	stop()
+	if err := cmp.Or(writeErr, closeErr); err != nil {
+		return nil, fmt.Errorf("failed to write JSON: %w", failedJSONExport(name, chunks, err, opts))
 	}
-
-	files, err := exportFiles(sales, ct.WeeklySalesCSVFilename, ct.WeeklySalesJSONFilename, opts)
+	jsonFiles, err := finishJSONExport(name, chunks, opts)
 	if err != nil {
 		return nil, err
 	}
+	return append(files, jsonFiles...), nil
+}
 
-	if err := ct.DBInsertWeeklySales(opts.conn, sales); err != nil {
-		return nil, fmt.Errorf("failed to insert weekly sales: %w", err)
+// finishJSONExport calls finishExport for each file of a JSON export written with a
+// sources.ChunkedJSONWriter, which is a single file unless --chunk-size or --chunk-bytes is set.
+// Returns the paths of the final output files.
+func finishJSONExport(name string, chunks []sources.JSONChunk, opts processOpts) ([]string, error) {
+	var files []string
+	for _, chunk := range chunks {
+		filename, err := filepath.Rel(opts.outputDir, chunk.Filename)
+		if err != nil {
+			return nil, err
+		}
+		file, err := finishExport(name, filename, chunk.Records, chunk.Rows, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
 	}
+	return files, nil
+}
 
-	if opts.verbose {
-		log.Printf("Processed %d weekly sales", len(sales))
+// failedExport records an export file of the named dataset whose writing failed with err in the
+// manifest, returning err joined with any row count mismatch, so rows that did not reach the file
+// are reported as a sources.ValidationError.
+func failedExport(name string, filename string, records int, rows int, err error, opts processOpts) error {
+	return errors.Join(opts.manifest.RecordFile(name, filename, records, rows), err)
+}
+
+// failedJSONExport calls failedExport for each file of a JSON export written with a
+// sources.ChunkedJSONWriter whose writing failed with err
+func failedJSONExport(name string, chunks []sources.JSONChunk, err error, opts processOpts) error {
+	for _, chunk := range chunks {
+		filename, relErr := filepath.Rel(opts.outputDir, chunk.Filename)
+		if relErr != nil {
+			return errors.Join(err, relErr)
+		}
+		err = failedExport(name, filename, chunk.Records, chunk.Rows, err, opts)
 	}
+	return err
+}
 
-	return files, nil
+// finishExport records a written export file of the named dataset in the manifest,
+// then compresses it if requested.  Returns the path of the final output file.
+func finishExport(name string, filename string, records int, rows int, opts processOpts) (string, error) {
+	if err := opts.manifest.RecordFile(name, outputName(filename, opts), records, rows); err != nil {
+		return "", err
+	}
+	file := filepath.Join(opts.outputDir, filename)
+	if !opts.compress {
+		return file, nil
+	}
+	stop := opts.timer.Start(name, phaseCompress)
+	compressed, err := compressFile(file, opts)
+	stop()
+	if err != nil {
+		return "", fmt.Errorf("failed to compress %s: %w", filename, err)
+	}
+	return compressed, nil
 }
 
-func processTax(opts processOpts) ([]string, error) {
-	if opts.verbose {
-		log.Println("Fetching CT tax data...")
+// exportReconciliation reconciles the processed sales and tax datasets and exports the result
+func exportReconciliation(opts processOpts) ([]string, error) {
+	sales, _ := opts.processed["sales"].([]ct.WeeklySales)
+	taxes, _ := opts.processed["tax"].([]ct.Tax)
+	if sales == nil || taxes == nil {
+		return nil, fmt.Errorf("sales and tax were not both processed")
 	}
 
-	taxes, err := fetchOrLoadCache(ct.TaxJSONFilename, ct.FetchTax, opts)
+	rows, err := ct.ReconcileSalesTax(sales, taxes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tax: %w", err)
+		return nil, err
 	}
 	if opts.verbose {
-		log.Printf("Loaded %d tax records", len(taxes))
+		flagged := 0
+		for _, row := range rows {
+			if row.Flag != "" {
+				flagged++
+			}
+		}
+		log.Printf("Reconciled %d months of sales and tax (%d flagged)", len(rows), flagged)
+	}
+	return exportFiles("sales_tax_reconciliation", rows, ct.ReconCSVFilename, ct.ReconJSONFilename, opts)
+}
+
+// writeDataDictionary writes the columns of every registered dataset to a CSV file,
+// or a JSON file if its name ends in ".json"
+func writeDataDictionary(filename string) error {
+	var entries []sources.DictionaryEntry
+	for _, d := range datasetRegistry {
+		dictionary, err := d.Dictionary()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, dictionary...)
+	}
+	var err error
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		_, err = sources.WriteJSON(filename, entries)
+	} else {
+		_, err = sources.WriteCSV(filename, entries)
 	}
+	return err
+}
 
-	files, err := exportFiles(taxes, ct.TaxCSVFilename, ct.TaxJSONFilename, opts)
+// exportWhere exports the rows of a dataset's DuckDB table matching a --where predicate
+// to us_ct_<dataset>_where.csv, with db.CopyWhere
+func exportWhere(conn *sql.DB, name string, table string, predicate string, opts processOpts) ([]string, error) {
+	name += "_where"
+	filename, err := renderName("us_ct_"+name+".csv", opts)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := ct.DBInsertTax(opts.conn, taxes); err != nil {
-		return nil, fmt.Errorf("failed to insert tax: %w", err)
+	stop := opts.timer.Start(name, phaseExport)
+	rows, err := db.CopyWhere(conn, table, predicate, filepath.Join(opts.outputDir, filename))
+	stop()
+	if err != nil {
+		return nil, err
 	}
-
 	if opts.verbose {
-		log.Printf("Processed %d tax records", len(taxes))
+		log.Printf("Exported %d rows of %s where %s", rows, table, predicate)
 	}
-
-	return files, nil
+	file, err := finishExport(name, filename, int(rows), int(rows), opts)
+	if err != nil {
+		return nil, err
+	}
+	return []string{file}, nil
 }
 
-func compressFile(filename string) error {
-	input, err := os.ReadFile(filename)
+// renderName renders a built-in output file name, e.g. "us_ct_brands.csv", through the name template.
+// With a sandbox, the rendered name must stay within the output directory, and so the sandbox.
+func renderName(filename string, opts processOpts) (string, error) {
+	name, err := sources.RenderName(opts.nameTmpl, sources.NameContextFor(filename, opts.nameDate))
 	if err != nil {
-		return fmt.Errorf("failed to read file for compression: %w", err)
+		return "", fmt.Errorf("failed to name %s: %w", filename, err)
+	}
+	if opts.sandbox != "" {
+		file, err := safeJoin(opts.outputDir, name)
+		if err == nil {
+			err = checkSandbox(opts.sandbox, file)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to name %s: %w", filename, err)
+		}
 	}
+	return name, nil
+}
 
-	output, err := os.Create(filename + ".zst")
-	if err != nil {
-		return fmt.Errorf("failed to create compressed file: %w", err)
+// outputName returns the name an exported file will have once any compression is applied
+func outputName(filename string, opts processOpts) string {
+	if opts.compress {
+		return filename + opts.compressExt
 	}
-	defer output.Close()
+	return filename
+}
 
-	encoder, err := zstd.NewWriter(output)
-	if err != nil {
-		return fmt.Errorf("failed to create zstd encoder: %w", err)
+// fetchOrLoadCache fetches data from API or loads from cache based on noFetch flag
+func fetchOrLoadCache[T any](
+	source string,
+	cacheFilename string,
+	fetchFunc func(string, time.Duration) ([]T, error),
+	bad *sources.BadRecords,
+	opts processOpts,
+) ([]T, error) {
+	if opts.noFetch {
+		data, err := sources.LoadCacheFile[T](cacheFilename, sources.AnyCacheAge, bad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cache: %w", err)
+		}
+		return data, nil
 	}
-	defer encoder.Close()
+	return fetchFunc(opts.appTokens.TokenForSource(source), opts.maxCacheAge)
+}
 
-	_, err = encoder.Write(input)
+// compressFile compresses filename with the configured codec, then removes the original.
+// Returns the compressed file's name.
+func compressFile(filename string, opts processOpts) (string, error) {
+	compressed, err := sources.CompressFile(filename, opts.codec, opts.compressExt)
 	if err != nil {
-		return fmt.Errorf("failed to write compressed data: %w", err)
+		return "", err
 	}
-
-	return nil
+	os.Remove(filename)
+	return compressed, nil
 }
@@ -0,0 +1,237 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// fakeDataset is a datasetProcessor whose Process records that it ran, and exports one file;
+// its other methods are not implemented
+type fakeDataset struct {
+	datasetProcessor
+	name string
+	err  error     // error Process returns, if any
+	ran  *[]string // names of the datasets processed, in order
+}
+
+func (f fakeDataset) Name() string { return f.name }
+
+func (f fakeDataset) Process(opts processOpts) ([]string, error) {
+	*f.ran = append(*f.ran, f.name)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if err := opts.manifest.RecordFile(f.name, f.name+".csv", 1, 1); err != nil {
+		return nil, err
+	}
+	return []string{filepath.Join(opts.outputDir, f.name+".csv")}, nil
+}
+
+func TestProcessDatasetsResumesFromStateFile(t *testing.T) {
+	names := []string{"brands", "credentials", "applications", "sales", "tax"}
+	done := []string{"brands", "applications", "tax"}
+	tests := []struct {
+		name         string
+		needed       []string // datasets a cross-dataset report needs the records of
+		failing      string   // dataset whose processing fails
+		wantRan      []string
+		wantComplete []string
+		wantExit     int
+	}{
+		{
+			name:         "completed datasets are skipped",
+			wantRan:      []string{"credentials", "sales"},
+			wantComplete: names,
+		},
+		{
+			name:         "needed datasets are processed again",
+			needed:       []string{"tax"},
+			wantRan:      []string{"credentials", "sales", "tax"},
+			wantComplete: names,
+		},
+		{
+			name:         "failed datasets are not completed",
+			failing:      "credentials",
+			wantRan:      []string{"credentials", "sales"},
+			wantComplete: []string{"brands", "applications", "sales", "tax"},
+			wantExit:     exitValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			stateFile := filepath.Join(outputDir, "state.json")
+
+			// The state of an interrupted run, which completed three of the datasets
+			prior := &sources.RunState{}
+			for _, name := range done {
+				m := &sources.Manifest{}
+				m.RecordFile(name, name+".csv", 1, 1)
+				prior.Complete(name, m)
+			}
+			if err := sources.WriteRunState(stateFile, prior); err != nil {
+				t.Fatal(err)
+			}
+			runState, err := sources.ReadRunState(stateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ran []string
+			var datasets []datasetProcessor
+			datasetSet := make(map[string]bool)
+			for _, name := range names {
+				d := fakeDataset{name: name, ran: &ran}
+				if name == tt.failing {
+					d.err = &sources.ValidationError{Msg: "failed"}
+				}
+				datasets = append(datasets, d)
+				datasetSet[name] = true
+			}
+			opts := processOpts{outputDir: outputDir, manifest: sources.NewManifest(), processed: make(map[string]any)}
+			for _, name := range tt.needed {
+				opts.processed[name] = nil
+			}
+
+			files, exitCode, err := processDatasets(datasets, datasetSet, runState, stateFile, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ran, tt.wantRan) {
+				t.Errorf("processed %v, want %v", ran, tt.wantRan)
+			}
+			if exitCode != tt.wantExit {
+				t.Errorf("exit code = %d, want %d", exitCode, tt.wantExit)
+			}
+
+			// Every dataset that completed, in this run or the last, has its file in the output and manifest
+			var wantFiles []string
+			for _, name := range names {
+				if name != tt.failing {
+					wantFiles = append(wantFiles, filepath.Join(outputDir, name+".csv"))
+				}
+			}
+			if !slices.Equal(files, wantFiles) {
+				t.Errorf("output files = %v, want %v", files, wantFiles)
+			}
+			var manifestFiles []string
+			for _, file := range opts.manifest.Files {
+				manifestFiles = append(manifestFiles, filepath.Join(outputDir, file.Filename))
+			}
+			if !slices.Equal(manifestFiles, wantFiles) {
+				t.Errorf("manifest files = %v, want %v", manifestFiles, wantFiles)
+			}
+
+			written, err := sources.ReadRunState(stateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range names {
+				if want := slices.Contains(tt.wantComplete, name); written.IsComplete(name) != want {
+					t.Errorf("state file has %s complete = %v, want %v", name, written.IsComplete(name), want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessDatasetsStateFileUnwritable(t *testing.T) {
+	var ran []string
+	datasets := []datasetProcessor{fakeDataset{name: "brands", ran: &ran}, fakeDataset{name: "tax", ran: &ran}}
+	stateFile := filepath.Join(t.TempDir(), "missing", "state.json")
+	opts := processOpts{outputDir: t.TempDir(), manifest: sources.NewManifest()}
+	_, _, err := processDatasets(datasets, map[string]bool{"brands": true, "tax": true}, &sources.RunState{}, stateFile, opts)
+	if err == nil {
+		t.Fatal("processDatasets() with an unwritable --state-file succeeded, want an error")
+	}
+	if !slices.Equal(ran, []string{"brands"}) {
+		t.Errorf("processed %v, want only brands before the state file failed", ran)
+	}
+}
+
+func TestDefaultNameTemplateKeepsFilenames(t *testing.T) {
+	opts := processOpts{nameTmpl: sources.DefaultNameTemplate, nameDate: "2026-01-02"}
+	filenames := []string{
+		manifestFilename,
+		ct.BrandCSVFilename, ct.BrandJSONFilename, ct.BrandCleanReportFilename, ct.BrandTidyCSVFilename,
+		ct.BrandCOACSVFilename, ct.BrandCOAJSONFilename,
+		ct.CredentialCSVFilename, ct.CredentialJSONFilename, ct.CredentialCleanReportFilename,
+		ct.CredentialSummaryCSVFilename, ct.CredentialSummaryJSONFilename,
+		ct.ApplicationCSVFilename, ct.ApplicationJSONFilename,
+		ct.WeeklySalesCSVFilename, ct.WeeklySalesJSONFilename, ct.WeeklySalesCleanReportFilename,
+		ct.TaxCSVFilename, ct.TaxJSONFilename,
+		ct.DisciplinaryActionCSVFilename, ct.DisciplinaryActionJSONFilename,
+		ct.ReconCSVFilename, ct.ReconJSONFilename,
+		"us_ct_brands.stats.json", "us_ct_brands.parquet", "us_ct_brands_bad_records.csv",
+	}
+	for _, filename := range filenames {
+		if got, err := renderName(filename, opts); err != nil || got != filename {
+			t.Errorf("renderName(%q) with the default template = %q, %v, want it unchanged", filename, got, err)
+		}
+	}
+}
+
+func TestExportFilesDefaultNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		compress  bool
+		chunks    sources.ChunkLimits
+		wantFiles []string
+	}{
+		{name: "uncompressed", wantFiles: []string{"us_ct_brands.csv", "us_ct_brands.json"}},
+		{name: "compressed", compress: true, wantFiles: []string{"us_ct_brands.csv.zst", "us_ct_brands.json.zst"}},
+		{name: "chunked", chunks: sources.ChunkLimits{Records: 1}, wantFiles: []string{"us_ct_brands.csv", "us_ct_brands.0001.json", "us_ct_brands.0002.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			opts := processOpts{
+				outputDir:   outputDir,
+				manifest:    sources.NewManifest(),
+				timer:       newPhaseTimer(false),
+				compress:    tt.compress,
+				codec:       sources.CodecZstd,
+				compressExt: sources.CodecZstd.Extension(),
+				jsonChunks:  tt.chunks,
+				nameTmpl:    sources.DefaultNameTemplate,
+				nameDate:    "2026-01-02",
+			}
+			files, err := exportFiles("brands", testBrands(1, 2), ct.BrandCSVFilename, ct.BrandJSONFilename, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wantFiles []string
+			for _, name := range tt.wantFiles {
+				wantFiles = append(wantFiles, filepath.Join(outputDir, name))
+			}
+			if !slices.Equal(files, wantFiles) {
+				t.Errorf("exportFiles() = %v, want %v", files, wantFiles)
+			}
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written []string
+			for _, entry := range entries {
+				written = append(written, entry.Name())
+			}
+			if want := slices.Sorted(slices.Values(tt.wantFiles)); !slices.Equal(written, want) {
+				t.Errorf("output directory has %v, want %v", written, want)
+			}
+			var manifestFiles []string
+			for _, file := range opts.manifest.Files {
+				manifestFiles = append(manifestFiles, file.Filename)
+			}
+			if !slices.Equal(manifestFiles, tt.wantFiles) {
+				t.Errorf("manifest files = %v, want %v", manifestFiles, tt.wantFiles)
+			}
+		})
+	}
+}
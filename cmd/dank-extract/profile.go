@@ -0,0 +1,63 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+// startProfiles starts a CPU profile written to cpuFile, if it is not empty, and returns a function
+// that stops it and writes a heap profile to memFile, if it is not empty.
+// The returned function must be called before exiting, as os.Exit does not run deferred calls;
+// calls after the first do nothing.
+func startProfiles(cpuFile, memFile string) (func(), error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpu = f
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if cpu != nil {
+				pprof.StopCPUProfile()
+				if err := cpu.Close(); err != nil {
+					log.Printf("Failed to write CPU profile: %v", err)
+				}
+			}
+			if memFile != "" {
+				if err := writeHeapProfile(memFile); err != nil {
+					log.Printf("Failed to write memory profile: %v", err)
+				}
+			}
+		})
+	}, nil
+}
+
+// writeHeapProfile writes a heap profile to filename, after a garbage collection so it
+// reflects the memory still in use
+func writeHeapProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return f.Close()
+}
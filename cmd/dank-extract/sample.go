@@ -0,0 +1,54 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// writeSample writes the first n records of a dataset for inspection, one field per line
+func writeSample[T any](w io.Writer, name string, items []T, n int) error {
+	n = min(n, len(items))
+	fmt.Fprintf(w, "== %s: %d of %d records\n", name, n, len(items))
+	for i, item := range items[:n] {
+		fmt.Fprintf(w, "\n[%d]\n", i+1)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeRecordFields(tw, "", reflect.ValueOf(item))
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// writeRecordFields writes each field of a struct as "name<TAB>value", named by its JSON tag.
+// Fields that are fmt.Stringers, such as measures, are written with String; other nested
+// structs have their fields written with the struct's name as a prefix, e.g. "product_image.url".
+func writeRecordFields(w io.Writer, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		name = prefix + name
+
+		value := v.Field(i)
+		if stringer, ok := value.Interface().(fmt.Stringer); ok {
+			fmt.Fprintf(w, "  %s\t%s\n", name, stringer.String())
+		} else if value.Kind() == reflect.Struct {
+			writeRecordFields(w, name+".", value)
+		} else {
+			fmt.Fprintf(w, "  %s\t%v\n", name, value.Interface())
+		}
+	}
+}
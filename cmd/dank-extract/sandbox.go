@@ -0,0 +1,75 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// safeJoin joins the relative path rel to base, returning a *sources.ValidationError if rel is
+// absolute or climbs out of base, e.g. "../brands.csv" or "a/../../brands.csv"
+func safeJoin(base, rel string) (string, error) {
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", &sources.ValidationError{Msg: fmt.Sprintf("path %q is not relative", rel)}
+	}
+	joined := filepath.Join(base, rel)
+	within, err := filepath.Rel(base, joined)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", &sources.ValidationError{Msg: fmt.Sprintf("path %q is outside %s", rel, base)}
+	}
+	return joined, nil
+}
+
+// checkSandbox returns a *sources.ValidationError unless each of the non-empty paths is within
+// the sandbox directory base.  Paths are compared absolute, following the symlinks of whatever
+// part of them exists, so a symlink within base cannot lead a write out of it.
+func checkSandbox(base string, paths ...string) error {
+	resolvedBase, err := resolvePath(base)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(resolvedBase, resolved)
+		if err != nil {
+			return &sources.ValidationError{Msg: fmt.Sprintf("%s is outside the sandbox %s", path, base)}
+		}
+		if _, err := safeJoin(resolvedBase, rel); err != nil {
+			return &sources.ValidationError{Msg: fmt.Sprintf("%s is outside the sandbox %s", path, base)}
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute form of path, with the symlinks of its longest existing
+// prefix evaluated; the rest of it, yet to be created, is appended as it is
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	var rest []string // the components of abs that do not exist, innermost first
+	for dir := abs; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			slices.Reverse(rest)
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		rest = append(rest, filepath.Base(dir))
+		dir = parent
+	}
+}
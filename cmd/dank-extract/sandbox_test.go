@@ -0,0 +1,117 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "out")
+	tests := []struct {
+		rel     string
+		want    string // the joined path, or "" if rel is rejected
+		wantErr bool
+	}{
+		{rel: "brands.csv", want: filepath.Join(base, "brands.csv")},
+		{rel: "us/ct/2026-01-02/brands.csv", want: filepath.Join(base, "us", "ct", "2026-01-02", "brands.csv")},
+		{rel: "a/../brands.csv", want: filepath.Join(base, "brands.csv")},
+		{rel: "..brands.csv", want: filepath.Join(base, "..brands.csv")},
+		{rel: "../x", wantErr: true},
+		{rel: "..", wantErr: true},
+		{rel: "a/../../x", wantErr: true},
+		{rel: "/etc/x", wantErr: true},
+		{rel: filepath.Join(base, "brands.csv"), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := safeJoin(base, tt.rel)
+		var validationErr *sources.ValidationError
+		if tt.wantErr {
+			if !errors.As(err, &validationErr) {
+				t.Errorf("safeJoin(%q) = %q, %v, want a ValidationError", tt.rel, got, err)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("safeJoin(%q) = %q, %v, want %q", tt.rel, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckSandbox(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "sandbox")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(base, "out"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A symlink within the sandbox leading out of it, and one leading within it
+	if err := os.Symlink(outside, filepath.Join(base, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "out"), filepath.Join(dir, "into")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "directory itself", path: base},
+		{name: "existing nested", path: filepath.Join(base, "out")},
+		{name: "nested yet to be created", path: filepath.Join(base, "out", "us", "ct", "brands.csv")},
+		{name: "symlink into the sandbox", path: filepath.Join(dir, "into", "brands.csv")},
+		{name: "parent", path: filepath.Join(base, ".."), wantErr: true},
+		{name: "climbs out", path: filepath.Join(base, "out") + "/../../x", wantErr: true},
+		{name: "sibling", path: filepath.Join(outside, "brands.csv"), wantErr: true},
+		{name: "sibling sharing a prefix", path: base + "-other/brands.csv", wantErr: true},
+		{name: "absolute elsewhere", path: "/etc/x", wantErr: true},
+		{name: "symlink escape", path: filepath.Join(base, "escape", "brands.csv"), wantErr: true},
+		{name: "symlink escape yet to be created", path: filepath.Join(base, "escape", "new", "brands.csv"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSandbox(base, tt.path)
+			var validationErr *sources.ValidationError
+			if tt.wantErr && !errors.As(err, &validationErr) {
+				t.Errorf("checkSandbox(%s) = %v, want a ValidationError", tt.path, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkSandbox(%s) = %v, want nil", tt.path, err)
+			}
+		})
+	}
+
+	// Of several paths, any one outside fails the check, and empty paths are skipped
+	if err := checkSandbox(base, "", filepath.Join(base, "out"), filepath.Join(base, "escape")); err == nil {
+		t.Error("checkSandbox() of several paths, one escaping, = nil, want an error")
+	}
+	if err := checkSandbox(base, "", filepath.Join(base, "out")); err != nil {
+		t.Errorf("checkSandbox() of paths within = %v, want nil", err)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	realDir := filepath.Join(dir, "real")
+	if err := os.Mkdir(realDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(realDir, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	resolvedReal, err := filepath.EvalSymlinks(realDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := resolvePath(filepath.Join(dir, "link", "new", "brands.csv"))
+	if want := filepath.Join(resolvedReal, "new", "brands.csv"); err != nil || got != want {
+		t.Errorf("resolvePath() = %q, %v, want %q", got, err, want)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// Phases of the pipeline that are timed
+const (
+	phaseFetch    = "fetch"
+	phaseClean    = "clean"
+	phaseExport   = "export"
+	phaseCompress = "compress"
+	phaseDBInsert = "db_insert"
+)
+
+// timedPhase is the accumulated duration of a phase of a dataset
+type timedPhase struct {
+	dataset  string
+	phase    string
+	duration time.Duration
+}
+
+// phaseTimer accumulates the wall-clock duration of each phase of a run, by dataset
+type phaseTimer struct {
+	start   time.Time
+	verbose bool
+	phases  []timedPhase
+}
+
+// newPhaseTimer returns a phaseTimer for a run starting now.
+// If verbose is true, each phase's duration is also logged as it ends.
+func newPhaseTimer(verbose bool) *phaseTimer {
+	return &phaseTimer{start: time.Now(), verbose: verbose}
+}
+
+// Start begins timing a phase of a dataset, returning the function that ends it.
+// Repeated phases of the same dataset, such as compressing each of its files, are summed.
+func (t *phaseTimer) Start(dataset string, phase string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		if t.verbose {
+			log.Printf("Timing dataset=%s phase=%s duration=%s", dataset, phase, elapsed.Round(time.Millisecond))
+		}
+		for i := range t.phases {
+			if t.phases[i].dataset == dataset && t.phases[i].phase == phase {
+				t.phases[i].duration += elapsed
+				return
+			}
+		}
+		t.phases = append(t.phases, timedPhase{dataset: dataset, phase: phase, duration: elapsed})
+	}
+}
+
+// Total returns the wall-clock duration of the run so far
+func (t *phaseTimer) Total() time.Duration {
+	return time.Since(t.start)
+}
+
+// Record stores the phase timings and the run's total duration in the manifest
+func (t *phaseTimer) Record(m *sources.Manifest) {
+	m.Timings = make([]sources.PhaseTiming, len(t.phases))
+	for i, p := range t.phases {
+		m.Timings[i] = sources.PhaseTiming{Dataset: p.dataset, Phase: p.phase, Seconds: p.duration.Seconds()}
+	}
+	m.DurationSeconds = t.Total().Seconds()
+}
+
+// WriteSummary writes the phase timings as an aligned table, grouped by dataset
+// in the order each was first timed, with each dataset's total and the run's.
+func (t *phaseTimer) WriteSummary(w io.Writer) error {
+	var datasets []string
+	for _, p := range t.phases {
+		if !slices.Contains(datasets, p.dataset) {
+			datasets = append(datasets, p.dataset)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATASET\tPHASE\tDURATION")
+	for _, dataset := range datasets {
+		var sum time.Duration
+		for _, p := range t.phases {
+			if p.dataset == dataset {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", dataset, p.phase, p.duration.Round(time.Millisecond))
+				sum += p.duration
+			}
+		}
+		fmt.Fprintf(tw, "%s\ttotal\t%s\n", dataset, sum.Round(time.Millisecond))
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%s\n", t.Total().Round(time.Millisecond))
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/AgentDank/dank-extract/sources"
+	flag "github.com/spf13/pflag"
+)
+
+// runVerify implements "dank-extract verify <dir>", checking that an extract's files
+// match its manifest's checksums and, with --key, that the manifest's signature is valid.
+// Returns the exit code.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyFile := flags.String("key", "", "Ed25519 public key PEM file to verify the manifest's signature with")
+	manifestName := flags.String("manifest", manifestFilename, "File name of the manifest within the directory")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dank-extract verify [options] <dir>")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	dir := flags.Arg(0)
+	manifestFile := filepath.Join(dir, *manifestName)
+
+	manifest, err := sources.ReadManifest(manifestFile)
+	if err != nil {
+		log.Printf("%v", err)
+		return exitCodeFor(err)
+	}
+
+	if *keyFile != "" {
+		key, err := sources.LoadVerifyKey(*keyFile)
+		if err != nil {
+			log.Printf("%v", err)
+			return exitUsage
+		}
+		sig, err := sources.ReadSignature(manifestFile + sources.SignatureExtension)
+		if err != nil {
+			log.Printf("%v", err)
+			return exitValidation
+		}
+		if err := sources.VerifyManifest(*manifest, sig, key); err != nil {
+			log.Printf("%v", err)
+			return exitCodeFor(err)
+		}
+		fmt.Printf("Signature of %s is valid\n", manifestFile)
+	}
+
+	if err := manifest.CheckFiles(dir); err != nil {
+		log.Printf("%v", err)
+		return exitCodeFor(err)
+	}
+	fmt.Printf("All %d files match %s\n", len(manifest.Files), manifestFile)
+	return exitOK
+}
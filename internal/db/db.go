@@ -13,11 +13,35 @@ import (
 
 ///////////////////////////////////////////////////////////////////////////////
 
-// RunMigration executes all migrations on the DuckDB connection.
-func RunMigration(conn *sql.DB) error {
+// RunMigration executes the migrations of the named tables on the DuckDB connection,
+// creating only those tables and the views over them, along with the MetaTable.
+// Nil tables migrates every table.
+func RunMigration(conn *sql.DB, tables []string) error {
 	// Run CT migrations
-	if _, err := conn.Exec(ct.DuckDBMigration); err != nil {
+	migration, err := ct.DuckDBMigrationFor(tables)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(migration); err != nil {
 		return fmt.Errorf("failed to run CT migration: %w", err)
 	}
+	if _, err := conn.Exec(metaMigration); err != nil {
+		return fmt.Errorf("failed to create %s: %w", MetaTable, err)
+	}
+	return nil
+}
+
+// DropTables drops every table created by RunMigration, along with their indexes,
+// and the MetaTable, so every dataset is loaded again.
+// Run RunMigration afterwards to recreate them empty.
+func DropTables(conn *sql.DB) error {
+	for _, table := range ct.DuckDBTables {
+		if _, err := conn.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table.Name)); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", table.Name, err)
+		}
+	}
+	if _, err := conn.Exec("DROP TABLE IF EXISTS " + MetaTable); err != nil {
+		return fmt.Errorf("failed to drop %s: %w", MetaTable, err)
+	}
 	return nil
 }
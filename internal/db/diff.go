@@ -0,0 +1,123 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// Catalog aliases that AttachForDiff gives the two databases being compared
+const (
+	DiffOldAlias = "old_db"
+	DiffNewAlias = "new_db"
+)
+
+// TableDiff counts the rows of a table that differ between two databases
+type TableDiff struct {
+	Table   string
+	Keyed   bool  // False if the table has no natural key, so changed rows count as removed and added
+	Added   int64 // Rows whose key is only in the new database
+	Removed int64 // Rows whose key is only in the old database
+	Changed int64 // Rows whose key is in both, but whose other columns differ
+	Err     error // Set if the table could not be compared, e.g. it is missing or its columns changed
+}
+
+// AttachForDiff attaches two DuckDB files, read-only, to the connection as DiffOldAlias and DiffNewAlias.
+// The connection should be limited to a single open connection, so the attachments stay in scope.
+func AttachForDiff(conn *sql.DB, oldFile string, newFile string) error {
+	for alias, file := range map[string]string{DiffOldAlias: oldFile, DiffNewAlias: newFile} {
+		if _, err := conn.Exec(fmt.Sprintf("ATTACH '%s' AS %s (READ_ONLY)", sources.SQLString(file), alias)); err != nil {
+			return fmt.Errorf("failed to attach %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// DiffTables compares every CT table between the attached databases, matching rows by
+// each table's natural key.  A table that cannot be compared has its Err set.
+func DiffTables(conn *sql.DB) []TableDiff {
+	diffs := make([]TableDiff, 0, len(ct.DuckDBTables))
+	for _, table := range ct.DuckDBTables {
+		diff := TableDiff{Table: table.Name, Keyed: len(table.Key) > 0}
+		queries := diffQueries(table)
+		counts := []*int64{&diff.Added, &diff.Removed, &diff.Changed}
+		for i, query := range []string{queries.added, queries.removed, queries.changed} {
+			if query == "" {
+				continue
+			}
+			if err := conn.QueryRow("SELECT count(*) FROM (" + query + ")").Scan(counts[i]); err != nil {
+				diff.Err = err
+				break
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// DumpTableDiff writes the added, removed, and changed rows of a table to CSV files in dir,
+// named <table>_<added|removed|changed>.csv, skipping kinds with no rows.
+// Changed rows are written as they are in the new database.
+// Returns the files written, and an error, if any.
+func DumpTableDiff(conn *sql.DB, diff TableDiff, dir string) ([]string, error) {
+	var table ct.DuckDBTable
+	for _, t := range ct.DuckDBTables {
+		if t.Name == diff.Table {
+			table = t
+		}
+	}
+	queries := diffQueries(table)
+
+	var files []string
+	for _, kind := range []struct {
+		name  string
+		count int64
+		query string
+	}{
+		{"added", diff.Added, queries.added},
+		{"removed", diff.Removed, queries.removed},
+		{"changed", diff.Changed, queries.changed},
+	} {
+		if kind.count == 0 || kind.query == "" {
+			continue
+		}
+		file := filepath.Join(dir, fmt.Sprintf("%s_%s.csv", diff.Table, kind.name))
+		copySQL := fmt.Sprintf("COPY (%s) TO '%s' (HEADER, DELIMITER ',')", kind.query, sources.SQLString(file))
+		if _, err := conn.Exec(copySQL); err != nil {
+			return files, fmt.Errorf("failed to dump %s rows of %s: %w", kind.name, diff.Table, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// tableDiffQueries are the queries selecting each kind of differing row of a table
+type tableDiffQueries struct {
+	added, removed, changed string // changed is empty for tables without a natural key
+}
+
+// diffQueries builds the diff queries for a table.  Keyed tables are matched with
+// anti and semi joins on the key; tables without a key are compared as whole rows.
+func diffQueries(table ct.DuckDBTable) tableDiffQueries {
+	oldTable := DiffOldAlias + "." + table.Name
+	newTable := DiffNewAlias + "." + table.Name
+	if len(table.Key) == 0 {
+		return tableDiffQueries{
+			added:   fmt.Sprintf("SELECT * FROM %s EXCEPT SELECT * FROM %s", newTable, oldTable),
+			removed: fmt.Sprintf("SELECT * FROM %s EXCEPT SELECT * FROM %s", oldTable, newTable),
+		}
+	}
+
+	using := strings.Join(table.Key, ", ")
+	return tableDiffQueries{
+		added:   fmt.Sprintf("SELECT n.* FROM %s n ANTI JOIN %s o USING (%s)", newTable, oldTable, using),
+		removed: fmt.Sprintf("SELECT o.* FROM %s o ANTI JOIN %s n USING (%s)", oldTable, newTable, using),
+		changed: fmt.Sprintf("SELECT n.* FROM %s n SEMI JOIN %s o USING (%s) EXCEPT SELECT * FROM %s", newTable, oldTable, using, oldTable),
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// DBLoadFromFile bulk loads a CSV file with a header row, such as a dataset's CSV export,
+// into one of the ct.DuckDBTables.  DuckDB reads the file directly, which is far faster
+// than inserting row-by-row.  The file may be zstd or gzip compressed, with any extension.
+//
+// Columns are matched by name, and values are cast to the column types, with unparsable
+// values loaded as NULL.  Like the row-by-row inserts, the table is cleared first unless
+// it has KeepRows set, in which case rows whose key is already present are skipped.
+func DBLoadFromFile(conn *sql.DB, table string, path string) error {
+	var spec *ct.DuckDBTable
+	for i := range ct.DuckDBTables {
+		if ct.DuckDBTables[i].Name == table {
+			spec = &ct.DuckDBTables[i]
+		}
+	}
+	if spec == nil {
+		return fmt.Errorf("unknown table %q", table)
+	}
+
+	source, err := readCSVSource(path)
+	if err != nil {
+		return err
+	}
+	columns, dataTypes, err := columnTypes(conn, table)
+	if err != nil {
+		return err
+	}
+	casts := make([]string, len(columns))
+	for i := range columns {
+		casts[i] = castColumn(columns[i], dataTypes[i])
+	}
+
+	insert := "INSERT INTO"
+	if spec.KeepRows {
+		insert = "INSERT OR IGNORE INTO"
+	}
+	loadSQL := fmt.Sprintf("%s %s (%s) SELECT %s FROM %s",
+		insert, table, strings.Join(columns, ", "), strings.Join(casts, ", "), source)
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin load of %s: %w", table, err)
+	}
+	defer tx.Rollback()
+	if !spec.KeepRows {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(loadSQL); err != nil {
+		return fmt.Errorf("failed to load %s from %s: %w", table, path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit load of %s: %w", table, err)
+	}
+	return nil
+}
+
+// readCSVSource returns the DuckDB table function reading a CSV file with a header row as text,
+// with the compression, if any, that its content shows
+func readCSVSource(path string) (string, error) {
+	codec, err := sources.SniffCodec(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	compression := string(codec)
+	if compression == "" {
+		compression = "none"
+	}
+	return fmt.Sprintf("read_csv('%s', header = true, all_varchar = true, allow_quoted_nulls = false, compression = '%s')",
+		sources.SQLString(path), compression), nil
+}
+
+// columnTypes returns the names of the table's columns, in order, and their DuckDB data types
+func columnTypes(conn *sql.DB, table string) ([]string, []string, error) {
+	rows, err := conn.Query("SELECT column_name, data_type FROM duckdb_columns() WHERE table_name = ? ORDER BY column_index", table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to describe %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns, dataTypes []string
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, nil, fmt.Errorf("failed to describe %s: %w", table, err)
+		}
+		columns = append(columns, column)
+		dataTypes = append(dataTypes, dataType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to describe %s: %w", table, err)
+	}
+	return columns, dataTypes, nil
+}
+
+// castColumn returns the expression casting a column of text read by read_csv to dataType,
+// with unparsable values NULL
+func castColumn(column string, dataType string) string {
+	if dataType == "VARCHAR" {
+		// Remove the quote that sources.CSVString prefixes to text beginning with a formula character
+		return fmt.Sprintf(`regexp_replace(%s, '^''(''*[-=+@])', '\1')`, column)
+	}
+	return fmt.Sprintf("TRY_CAST(%s AS %s)", column, dataType)
+}
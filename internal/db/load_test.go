@@ -0,0 +1,422 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"maps"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/AgentDank/dank-extract/sources/us/ct"
+)
+
+// TestDBLoadFromFileRoundTrip writes brands whose text needs escaping or guarding to a CSV
+// export, then checks that read_csv loads each back into ct_brands as it was
+func TestDBLoadFromFileRoundTrip(t *testing.T) {
+	names := []string{
+		"Blue Dream",
+		`the "best" one`,
+		"Entity, LLC",
+		"line one\nline two",
+		"line one\rline two",
+		"line one\r\nline two",
+		`=HYPERLINK("x")`,
+		"+1",
+		"-2 mg",
+		"@SUM(A1)",
+		"'quoted",
+		"'=x",
+		"''=x",
+	}
+	brands := make([]ct.Brand, len(names))
+	for i, name := range names {
+		brands[i] = ct.Brand{BrandName: name, BrandingEntity: name, RegistrationNumber: "BRAND-" + strconv.Itoa(i)}
+	}
+	filename := filepath.Join(t.TempDir(), ct.BrandCSVFilename)
+	if _, err := sources.WriteCSV(filename, brands); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := Open("", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := RunMigration(conn, []string{"ct_brands"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DBLoadFromFile(conn, "ct_brands", filename); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.Query("SELECT registration_number, brand_name, branding_entity FROM ct_brands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	loaded := make(map[string][2]string)
+	for rows.Next() {
+		var number, name, entity string
+		if err := rows.Scan(&number, &name, &entity); err != nil {
+			t.Fatal(err)
+		}
+		loaded[number] = [2]string{name, entity}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		got, ok := loaded[brands[i].RegistrationNumber]
+		if !ok {
+			t.Errorf("brand %q was not loaded", name)
+		} else if got != [2]string{name, name} {
+			t.Errorf("brand %q loaded as %q", name, got)
+		}
+	}
+}
+
+// disciplineFixture is a page of disciplinary actions as Socrata returns them
+const disciplineFixture = `[
+  {"license_number": "rtl 123", "name": "Green Leaf, LLC", "credential_type": "Retailer",
+   "action_type": "Consent Order", "action_date": "2025-03-04T00:00:00.000",
+   "violation": "Sold to a minor", "penalty": "$5,000 fine"},
+  {"license_number": "CUL-7", "name": "O'Brien Farms", "credential_type": "Cultivator",
+   "action_type": "Suspension", "action_date": "2025-06-30T12:30:00.000",
+   "violation": "Inventory \"discrepancy\"", "penalty": "30 days"},
+  {"license_number": "MFG-9", "name": "No Application", "credential_type": "Manufacturer",
+   "action_type": "Warning", "violation": "Labeling"}
+]`
+
+// TestDisciplinaryActionsInsertAndView inserts the disciplinary actions of disciplineFixture,
+// then checks them in ct_disciplinary_actions and joined to applications in ct_licensee_discipline
+func TestDisciplinaryActionsInsertAndView(t *testing.T) {
+	actions, err := sources.UnmarshalRecords[ct.DisciplinaryAction]([]byte(disciplineFixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := Open("", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := RunMigration(conn, []string{"ct_disciplinary_actions", "ct_applications"}); err != nil {
+		t.Fatal(err)
+	}
+	applications := []ct.Application{
+		{ApplicationLicenseNumber: "RTL-123", Name: "Green Leaf LLC", ApplicationCredentialStatus: "ACTIVE"},
+		{ApplicationLicenseNumber: "cul_7", Name: "O'Brien Farms", ApplicationCredentialStatus: "INACTIVE"},
+	}
+	if err := ct.DBInsertApplications(conn, applications); err != nil {
+		t.Fatal(err)
+	}
+	// Inserting twice replaces, rather than repeats, the actions
+	for range 2 {
+		if err := ct.DBInsertDisciplinaryActions(conn, actions); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	if err := conn.QueryRow("SELECT count(*) FROM ct_disciplinary_actions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(actions) {
+		t.Errorf("ct_disciplinary_actions has %d rows, want %d", count, len(actions))
+	}
+
+	rows, err := conn.Query(`SELECT license_number, name, violation, action_date, application_name, application_credential_status
+		FROM ct_licensee_discipline ORDER BY license_number`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type viewRow struct {
+		license, name, violation string
+		actionDate               sql.NullTime
+		appName, appStatus       sql.NullString
+	}
+	var got []viewRow
+	for rows.Next() {
+		var r viewRow
+		if err := rows.Scan(&r.license, &r.name, &r.violation, &r.actionDate, &r.appName, &r.appStatus); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []viewRow{
+		{license: "CUL-7", name: "O'Brien Farms", violation: `Inventory "discrepancy"`,
+			actionDate: sql.NullTime{Time: time.Date(2025, 6, 30, 12, 30, 0, 0, time.UTC), Valid: true},
+			appName:    sql.NullString{String: "O'Brien Farms", Valid: true}, appStatus: sql.NullString{String: "INACTIVE", Valid: true}},
+		{license: "MFG-9", name: "No Application", violation: "Labeling"},
+		{license: "rtl 123", name: "Green Leaf, LLC", violation: "Sold to a minor",
+			actionDate: sql.NullTime{Time: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), Valid: true},
+			appName:    sql.NullString{String: "Green Leaf LLC", Valid: true}, appStatus: sql.NullString{String: "ACTIVE", Valid: true}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ct_licensee_discipline has %d rows %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.license != w.license || g.name != w.name || g.violation != w.violation || g.appName != w.appName ||
+			g.appStatus != w.appStatus || g.actionDate.Valid != w.actionDate.Valid || !g.actionDate.Time.Equal(w.actionDate.Time) {
+			t.Errorf("ct_licensee_discipline row %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+// openBrandsDB returns an in-memory database with the ct_brands table
+func openBrandsDB(t testing.TB) *sql.DB {
+	t.Helper()
+	conn, err := Open("", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := RunMigration(conn, []string{"ct_brands"}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// loadedBrandNames returns the brand name of each registration number in ct_brands
+func loadedBrandNames(t *testing.T, conn *sql.DB) map[string]string {
+	t.Helper()
+	rows, err := conn.Query("SELECT registration_number, brand_name FROM ct_brands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := make(map[string]string)
+	for rows.Next() {
+		var number, name string
+		if err := rows.Scan(&number, &name); err != nil {
+			t.Fatal(err)
+		}
+		names[number] = name
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// TestDBInsertBrandsFullPrecision checks that brands exported with --measure-precision are
+// inserted into ct_brands with their measures' full precision
+func TestDBInsertBrandsFullPrecision(t *testing.T) {
+	conn := openBrandsDB(t)
+	b := ct.Brand{RegistrationNumber: "BRAND-1", TetrahydrocannabinolThc: ct.NewMeasure(12.3456)}.WithMeasurePrecision(2)
+	if err := ct.DBInsertBrands(conn, []ct.Brand{b}); err != nil {
+		t.Fatal(err)
+	}
+	var thc float64
+	if err := conn.QueryRow("SELECT tetrahydrocannabinol_thc FROM ct_brands").Scan(&thc); err != nil {
+		t.Fatal(err)
+	}
+	if thc != 12.3456 {
+		t.Errorf("tetrahydrocannabinol_thc = %v, want 12.3456", thc)
+	}
+}
+
+// TestDBLoadFromFileKeepRows loads two CSV exports of brands into ct_brands, which has KeepRows
+// set, checking that the second adds its new brands while those already loaded, or repeated
+// within the file, are skipped rather than failing the load or being replaced
+func TestDBLoadFromFileKeepRows(t *testing.T) {
+	conn := openBrandsDB(t)
+	dir := t.TempDir()
+	loads := []struct {
+		brands []ct.Brand
+		want   map[string]string
+	}{
+		{
+			brands: []ct.Brand{
+				{RegistrationNumber: "BRAND-1", BrandName: "First"},
+				{RegistrationNumber: "BRAND-2", BrandName: "Second"},
+			},
+			want: map[string]string{"BRAND-1": "First", "BRAND-2": "Second"},
+		},
+		{
+			brands: []ct.Brand{
+				{RegistrationNumber: "BRAND-2", BrandName: "Second, renamed"},
+				{RegistrationNumber: "BRAND-3", BrandName: "Third"},
+				{RegistrationNumber: "BRAND-3", BrandName: "Third, repeated"},
+			},
+			want: map[string]string{"BRAND-1": "First", "BRAND-2": "Second", "BRAND-3": "Third"},
+		},
+	}
+	for i, load := range loads {
+		filename := filepath.Join(dir, strconv.Itoa(i)+".csv")
+		if _, err := sources.WriteCSV(filename, load.brands); err != nil {
+			t.Fatal(err)
+		}
+		if err := DBLoadFromFile(conn, "ct_brands", filename); err != nil {
+			t.Fatalf("load %d: %v", i, err)
+		}
+		if got := loadedBrandNames(t, conn); !maps.Equal(got, load.want) {
+			t.Errorf("load %d: ct_brands = %v, want %v", i, got, load.want)
+		}
+	}
+}
+
+// benchLoadBrands returns n brands with every measure set
+func benchLoadBrands(n int) []ct.Brand {
+	brands := make([]ct.Brand, n)
+	for i := range brands {
+		b := &brands[i]
+		b.BrandName = "Brand " + strconv.Itoa(i)
+		b.DosageForm = "Flower"
+		b.BrandingEntity = "Entity, LLC"
+		b.RegistrationNumber = "BRAND-" + strconv.Itoa(i)
+		for j, nm := range b.Measures() {
+			*nm.Measure = ct.NewMeasure(float64(i%100) + float64(j)/10)
+		}
+	}
+	return brands
+}
+
+// BenchmarkLoadBrands loads 50k brands into an empty ct_brands with DBLoadFromFile from their
+// CSV export, and row-by-row with DBInsertBrands, which --bulk-load replaces
+func BenchmarkLoadBrands(b *testing.B) {
+	brands := benchLoadBrands(50000)
+	filename := filepath.Join(b.TempDir(), ct.BrandCSVFilename)
+	if _, err := sources.WriteCSV(filename, brands); err != nil {
+		b.Fatal(err)
+	}
+	loads := []struct {
+		name string
+		load func(conn *sql.DB) error
+	}{
+		{name: "DBLoadFromFile", load: func(conn *sql.DB) error { return DBLoadFromFile(conn, "ct_brands", filename) }},
+		{name: "DBInsertBrands", load: func(conn *sql.DB) error { return ct.DBInsertBrands(conn, brands) }},
+	}
+	for _, l := range loads {
+		b.Run(l.name, func(b *testing.B) {
+			conn := openBrandsDB(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				if _, err := conn.Exec("DELETE FROM ct_brands"); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				if err := l.load(conn); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestAppendAndRecreateRowCounts loads two runs' brands and taxes into one database, as
+// --append-db does by default and as --recreate-db does by dropping the tables first, checking
+// the rows each table is left with
+func TestAppendAndRecreateRowCounts(t *testing.T) {
+	runs := []struct {
+		brands []ct.Brand
+		taxes  []ct.Tax
+	}{
+		{
+			brands: []ct.Brand{{RegistrationNumber: "BRAND-1"}, {RegistrationNumber: "BRAND-2"}},
+			taxes:  []ct.Tax{{PeriodEndDate: "2025-01-31T00:00:00.000"}, {PeriodEndDate: "2025-02-28T00:00:00.000"}},
+		},
+		{
+			brands: []ct.Brand{{RegistrationNumber: "BRAND-2"}, {RegistrationNumber: "BRAND-3"}},
+			taxes:  []ct.Tax{{PeriodEndDate: "2025-03-31T00:00:00.000"}},
+		},
+	}
+	tests := []struct {
+		name       string
+		recreate   bool
+		wantBrands int // brands of both runs, less the one repeated, or only the second's
+		wantTaxes  int // each load of the taxes replaces the last
+	}{
+		{name: "append", recreate: false, wantBrands: 3, wantTaxes: 1},
+		{name: "recreate", recreate: true, wantBrands: 2, wantTaxes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := Open("", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			for i, run := range runs {
+				if tt.recreate {
+					if err := DropTables(conn); err != nil {
+						t.Fatalf("run %d: %v", i, err)
+					}
+				}
+				if err := RunMigration(conn, nil); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+				if err := ct.DBInsertBrands(conn, run.brands); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+				if err := ct.DBInsertTax(conn, run.taxes); err != nil {
+					t.Fatalf("run %d: %v", i, err)
+				}
+			}
+
+			for table, want := range map[string]int{"ct_brands": tt.wantBrands, "ct_tax": tt.wantTaxes} {
+				var count int
+				if err := conn.QueryRow("SELECT count(*) FROM " + table).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				if count != want {
+					t.Errorf("%s has %d rows, want %d", table, count, want)
+				}
+			}
+		})
+	}
+}
+
+// TestDropTables checks that DropTables drops every table and the MetaTable, which RunMigration
+// then recreates empty, and that it can be run on a database without them
+func TestDropTables(t *testing.T) {
+	conn, err := Open("", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := DropTables(conn); err != nil {
+		t.Fatalf("DropTables() of an empty database = %v", err)
+	}
+	if err := RunMigration(conn, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ct.DBInsertBrands(conn, []ct.Brand{{RegistrationNumber: "BRAND-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordLoad(conn, "brands", 1, "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DropTables(conn); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	if err := conn.QueryRow("SELECT count(*) FROM duckdb_tables()").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after DropTables()", tables)
+	}
+
+	if err := RunMigration(conn, nil); err != nil {
+		t.Fatal(err)
+	}
+	var brands int
+	if err := conn.QueryRow("SELECT count(*) FROM ct_brands").Scan(&brands); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := LoadedContentHash(conn, "brands"); err != nil || brands != 0 || hash != "" {
+		t.Errorf("recreated ct_brands has %d rows, and brands content hash %q, %v; want none", brands, hash, err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// MetaTable records, for each dataset, the content hash of the records last loaded into DuckDB,
+// so loads of unchanged records can be skipped
+const MetaTable = "_dank_meta"
+
+// metaMigration creates the MetaTable
+const metaMigration = `CREATE TABLE IF NOT EXISTS ` + MetaTable + ` (
+    dataset TEXT PRIMARY KEY,
+    content_hash TEXT NOT NULL,
+    records INTEGER,
+    loaded_at TIMESTAMP
+);`
+
+// LoadedContentHash returns the content hash recorded by RecordLoad for the dataset,
+// or "" if it has never been loaded
+func LoadedContentHash(conn *sql.DB, dataset string) (string, error) {
+	var contentHash string
+	err := conn.QueryRow("SELECT content_hash FROM "+MetaTable+" WHERE dataset = ?", dataset).Scan(&contentHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read %s load of %s: %w", MetaTable, dataset, err)
+	}
+	return contentHash, nil
+}
+
+// RecordLoad records the number and content hash of the dataset's records just loaded,
+// replacing those of its previous load
+func RecordLoad(conn *sql.DB, dataset string, records int, contentHash string) error {
+	_, err := conn.Exec("INSERT OR REPLACE INTO "+MetaTable+" (dataset, content_hash, records, loaded_at) VALUES (?, ?, ?, now())",
+		dataset, contentHash, records)
+	if err != nil {
+		return fmt.Errorf("failed to record %s load of %s: %w", MetaTable, dataset, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// Options tunes the DuckDB database and its connection pool.  Zero values keep DuckDB's defaults.
+type Options struct {
+	MemoryLimit  string // DuckDB memory_limit, e.g. "4GB"
+	Threads      int    // DuckDB threads
+	MaxOpenConns int    // Maximum open connections in the pool; set to 1 for single-connection bulk loads
+	ReadOnly     bool   // Open the database read-only, so queries cannot modify it
+}
+
+// Pragmas returns the statements that apply the options to a DuckDB database, in order
+func (o Options) Pragmas() []string {
+	var pragmas []string
+	if o.MemoryLimit != "" {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA memory_limit='%s'", sources.SQLString(o.MemoryLimit)))
+	}
+	if o.Threads > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA threads=%d", o.Threads))
+	}
+	return pragmas
+}
+
+// Open opens the DuckDB file, sizes its connection pool, and applies the options' Pragmas.
+// Returns nil with any error.
+func Open(dbFile string, opts Options) (*sql.DB, error) {
+	dsn := dbFile
+	if opts.ReadOnly {
+		dsn += "?access_mode=read_only"
+	}
+	conn, err := sql.Open("duckdb", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DuckDB: %w", err)
+	}
+	if opts.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	for _, pragma := range opts.Pragmas() {
+		if _, err := conn.Exec(pragma); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to apply %q: %w", pragma, err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// WriteParquet converts a CSV file with a header row, such as a dataset's CSV export, to a
+// Parquet file with DuckDB's COPY, typing its columns as in one of the ct.DuckDBTables.
+// Columns of the table have its types, e.g. DOUBLE for measures and taxes and TIMESTAMP for
+// dates, with unparsable values NULL, as DBLoadFromFile loads them; any other columns of the
+// file are text.  Columns are written in the file's order, so a --lite
+// export keeps its columns.  The CSV file may be zstd or gzip compressed, with any extension.
+// Returns the number of rows written.
+func WriteParquet(conn *sql.DB, table string, csvPath string, parquetPath string) (int64, error) {
+	source, err := readCSVSource(csvPath)
+	if err != nil {
+		return 0, err
+	}
+	columns, dataTypes, err := columnTypes(conn, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("unknown table %q", table)
+	}
+
+	rows, err := conn.Query("SELECT * FROM " + source + " LIMIT 0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", csvPath, err)
+	}
+	csvColumns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", csvPath, err)
+	}
+
+	casts := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		dataType := "VARCHAR"
+		if c := slices.Index(columns, column); c >= 0 {
+			dataType = dataTypes[c]
+		}
+		casts[i] = castColumn(column, dataType) + " AS " + column
+	}
+	copySQL := fmt.Sprintf("COPY (SELECT %s FROM %s) TO '%s' (FORMAT PARQUET)",
+		strings.Join(casts, ", "), source, sources.SQLString(parquetPath))
+	result, err := conn.Exec(copySQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write Parquet %s: %w", parquetPath, err)
+	}
+	written, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows of Parquet %s: %w", parquetPath, err)
+	}
+	return written, nil
+}
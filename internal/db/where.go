@@ -0,0 +1,72 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// ValidatePredicate checks that a SQL predicate is a single expression that can only be used
+// as the WHERE clause of CopyWhere: its quotes and parentheses are balanced, and outside of
+// quotes it has no statement separator or comment, so it cannot end the query it is placed in.
+func ValidatePredicate(predicate string) error {
+	if predicate == "" {
+		return &sources.ValidationError{Msg: "predicate is empty"}
+	}
+	depth := 0
+	var quote rune // the open quote, ' or ", or 0 outside of quotes
+	prev := rune(0)
+	for _, r := range predicate {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return &sources.ValidationError{Msg: "predicate has an unopened ')'"}
+			}
+		case r == ';':
+			return &sources.ValidationError{Msg: "predicate has a ';'"}
+		case (prev == '-' && r == '-') || (prev == '/' && r == '*'):
+			return &sources.ValidationError{Msg: "predicate has a comment"}
+		}
+		prev = r
+	}
+	if quote != 0 {
+		return &sources.ValidationError{Msg: fmt.Sprintf("predicate has an unclosed %c", quote)}
+	}
+	if depth != 0 {
+		return &sources.ValidationError{Msg: "predicate has an unclosed '('"}
+	}
+	return nil
+}
+
+// CopyWhere writes the rows of the table matching a SQL predicate, e.g. "total_thc > 20",
+// to a CSV file with DuckDB's COPY.  The predicate is checked with ValidatePredicate first;
+// conn should be opened with Options.ReadOnly so the predicate cannot modify the database.
+// Returns the number of rows written.
+func CopyWhere(conn *sql.DB, table string, predicate string, file string) (int64, error) {
+	if err := ValidatePredicate(predicate); err != nil {
+		return 0, err
+	}
+	copySQL := fmt.Sprintf("COPY (SELECT * FROM %s WHERE (%s)) TO '%s' (HEADER, DELIMITER ',')",
+		table, predicate, sources.SQLString(file))
+	result, err := conn.Exec(copySQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy rows of %s where %s: %w", table, predicate, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows of %s where %s: %w", table, predicate, err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BadRecord is a record that did not decode, and was skipped by a tolerant decode
+type BadRecord struct {
+	Index int    `json:"index"` // Index of the record in the dataset, counting from 0
+	Error string `json:"error"` // Why it did not decode
+}
+
+// CSVHeaders returns the CSV headers for the BadRecord struct
+func (r BadRecord) CSVHeaders() string {
+	return `"index","error"
+`
+}
+
+// CSVValue returns the CSV value for the BadRecord struct
+func (r BadRecord) CSVValue() string {
+	var row CSVRow
+	row.Int(r.Index)
+	row.String(r.Error)
+	return row.Line()
+}
+
+// BadRecords collects the records skipped by tolerant decodes, see SocrataConfig.BadRecords.
+// Its methods are safe for concurrent use.
+type BadRecords struct {
+	mu      sync.Mutex
+	records []BadRecord
+}
+
+// Add records skipped records
+func (b *BadRecords) Add(records ...BadRecord) {
+	b.mu.Lock()
+	b.records = append(b.records, records...)
+	b.mu.Unlock()
+}
+
+// Records returns every skipped record added so far
+func (b *BadRecords) Records() []BadRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := make([]BadRecord, len(b.records))
+	copy(records, b.records)
+	return records
+}
+
+// UnmarshalRecords decodes a JSON array of records of type T.  If bad is nil, this is
+// json.Unmarshal, failing if any record does not decode; otherwise such records are added
+// to bad and skipped, as by decodeRecords.
+func UnmarshalRecords[T any](data []byte, bad *BadRecords) ([]T, error) {
+	if bad == nil {
+		var items []T
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	items, skipped, err := decodeRecords[T](bytes.NewReader(data), 0, true)
+	if err != nil {
+		return nil, err
+	}
+	bad.Add(skipped...)
+	return items, nil
+}
+
+// decodeRecords decodes a JSON array of records of type T from r, such as a page of a
+// Socrata response whose first record is the offset'th of the dataset.  Unless tolerant,
+// the first record that does not decode fails the whole array.  If tolerant, the array is
+// decoded element by element, and records that do not decode are skipped, returned as
+// BadRecords numbered from offset.  Malformed JSON still fails, as the array cannot be read past it.
+func decodeRecords[T any](r io.Reader, offset int, tolerant bool) ([]T, []BadRecord, error) {
+	dec := json.NewDecoder(r)
+	if !tolerant {
+		var items []T
+		if err := dec.Decode(&items); err != nil {
+			return nil, nil, err
+		}
+		return items, nil, nil
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok == nil {
+		return nil, nil, nil // null, as json.Unmarshal allows
+	}
+	if tok != json.Delim('[') {
+		return nil, nil, fmt.Errorf("expected a JSON array of records, got %v", tok)
+	}
+	var items []T
+	var bad []BadRecord
+	for i := offset; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			bad = append(bad, BadRecord{Index: i, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	return items, bad, nil
+}
@@ -3,9 +3,21 @@
 package sources
 
 import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,17 +26,55 @@ const (
 	CacheDir = "cache" // CacheDir is the directory under DankDir where dank-extract stores its cache files.
 )
 
-var dankRoot string = "." // The root directory for dank-extract, default is '.'
+// CacheVersion is the version of the cached data's shape.  Bump it whenever a change to a
+// record struct means older cache files would no longer deserialize correctly.
+// Cache files written with a different version are treated as missing.
+const CacheVersion = 1
+
+// CacheVersionSuffix is appended to a cache filename to name its version sidecar file
+const CacheVersionSuffix = ".version"
+
+// CacheCompressedSuffix is appended to a cache filename to name the zstd-compressed file
+// its cache is kept in, e.g. "us_ct_brands.json.zst"
+const CacheCompressedSuffix = ".zst"
+
+// Special maxAge values for CheckCacheFile and the fetch functions that use it
+const (
+	AnyCacheAge time.Duration = 0  // AnyCacheAge accepts a cache file of any age.
+	AlwaysFetch time.Duration = -1 // AlwaysFetch treats every cache file as too old, so data is always fetched; any negative maxAge does the same.
+)
+
+// TouchUnchangedCache sets whether a cache write that is skipped, because the cache file
+// already holds the same bytes, still updates the file's modification time.  Touching it
+// marks the data as freshly fetched for CheckCacheFile's maxAge; set it false to keep the
+// time the content last changed.
+var TouchUnchangedCache = true
+
+// FailOnUnreadableCache sets whether a fetch fails with the cache's *CacheError when its cache
+// file is there but cannot be read or decoded, rather than discarding it, as a CacheDiscarded
+// event of the fetch's CacheEvents, and fetching the data again
+var FailOnUnreadableCache = false
+
+var (
+	dankRootMu sync.RWMutex
+	dankRoot   string = "." // The root directory for dank-extract, default is '.'; guarded by dankRootMu
+)
 
 //////////////////////////////////////////////////////////////////////////////
 
 // SetDankRoot sets the root directory for dank-extract.
+// It is safe to call concurrently with the cache functions, though files already in use
+// by a fetch in progress remain where they were.
 func SetDankRoot(root string) {
+	dankRootMu.Lock()
 	dankRoot = root
+	dankRootMu.Unlock()
 }
 
 // GetDankRoot returns the current root directory.
 func GetDankRoot() string {
+	dankRootMu.RLock()
+	defer dankRootMu.RUnlock()
 	return dankRoot
 }
 
@@ -37,51 +87,435 @@ func EnsureDankRoot() error {
 
 // GetDankDir returns the path to the DankDir directory.
 func GetDankDir() string {
-	return filepath.Join(dankRoot, DankDir)
+	return filepath.Join(GetDankRoot(), DankDir)
 }
 
 // GetDankCacheDir returns the path to the CacheDir directory.
 func GetDankCacheDir() string {
-	return filepath.Join(dankRoot, DankDir, CacheDir)
+	return filepath.Join(GetDankRoot(), DankDir, CacheDir)
 }
 
 // GetDankCachePathname returns the path to the given filename within the CacheDir.
+// The filename is sanitized so the result is always directly within the CacheDir.
 func GetDankCachePathname(filename string) string {
-	return filepath.Join(dankRoot, DankDir, CacheDir, filename)
+	return filepath.Join(GetDankRoot(), DankDir, CacheDir, sanitizeCacheName(filename))
+}
+
+// CacheFilePath returns the path of the file holding the named cache within the CacheDir:
+// its zstd-compressed file, unless only a legacy uncompressed file of the name itself exists,
+// as cache files were written before they were compressed
+func CacheFilePath(filename string) string {
+	compressed := GetDankCachePathname(filename + CacheCompressedSuffix)
+	if _, err := os.Stat(compressed); err != nil {
+		if plain := GetDankCachePathname(filename); fileExists(plain) {
+			return plain
+		}
+	}
+	return compressed
+}
+
+// fileExists returns true if path exists, whatever it is
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
-// CheckCacheFile checks DankDir/cache for a file. Returns its bytes and error, if any.
+// removeLegacyCache removes the legacy uncompressed file of the named cache, once its compressed
+// file has been written, so that it cannot be read in place of the compressed one
+func removeLegacyCache(filename string) {
+	os.Remove(GetDankCachePathname(filename))
+}
+
+// sanitizeCacheName deterministically maps a cache filename to a single safe path element.
+// Path separators and any character other than letters, digits, '.', '-', and '_' become '_',
+// and ".." sequences become "__", so the name can never traverse out of the cache directory.
+func sanitizeCacheName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		case r == '.' || r == '-' || r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	safe = strings.ReplaceAll(safe, "..", "__")
+	if safe == "" || safe == "." {
+		return "_"
+	}
+	return safe
+}
+
+// CheckCacheFile checks DankDir/cache for a file. Returns its bytes, decompressed, and error, if any.
+// The file is read from CacheFilePath, so legacy uncompressed caches are still read.
 // If the file is not found, it returns an error.
 // If the file is older than maxAge, it returns an error.
+// A maxAge of AnyCacheAge (0) accepts a file of any age, while a negative maxAge
+// such as AlwaysFetch rejects every file.
+// If the file was not written with the current CacheVersion, it returns an error.
 func CheckCacheFile(filename string, maxAge time.Duration) ([]byte, error) {
-	cacheFilename := filepath.Join(dankRoot, DankDir, "cache", filename)
-
-	if stat, err := os.Stat(cacheFilename); err != nil {
-		return nil, fmt.Errorf("cache file not found")
-	} else if maxAge != 0 && time.Now().After(stat.ModTime().Add(maxAge)) {
-		// now is past the max age
-		return nil, fmt.Errorf("cache file is too old")
+	if err := CacheStatus(filename, maxAge); err != nil {
+		return nil, err
 	}
 
-	cacheBytes, err := os.ReadFile(cacheFilename)
+	// Legacy cache files are uncompressed, and may have been compressed by hand, e.g. with gzip
+	reader, err := OpenMaybeCompressed(CacheFilePath(filename))
 	if err != nil {
-		return nil, fmt.Errorf("cache file read error: %w", err)
+		return nil, &CacheError{Kind: CacheUnreadable, Reason: "cache file read error", Err: err}
+	}
+	defer reader.Close()
+	cacheBytes, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &CacheError{Kind: CacheUnreadable, Reason: "cache file read error", Err: err}
 	}
 	return cacheBytes, nil
 }
 
-// MakeCacheFile creates a cache file in the DankDir/cache directory and returns its handles.
+// LoadCacheFile reads the records of a cache file checked as by CheckCacheFile, decoded as
+// by UnmarshalRecords.  Returns a *CacheError of kind CacheUnreadable if the file is there
+// but its records do not decode, alongside those CheckCacheFile returns.
+func LoadCacheFile[T any](filename string, maxAge time.Duration, bad *BadRecords) ([]T, error) {
+	cacheBytes, err := CheckCacheFile(filename, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	items, err := UnmarshalRecords[T](cacheBytes, bad)
+	if err != nil {
+		return nil, &CacheError{Kind: CacheUnreadable, Reason: "cache file parse error", Err: err}
+	}
+	return items, nil
+}
+
+// loadCache returns the records of cfg's cache, as LoadCacheFile does, and true if they can be used.
+// An unreadable cache is discarded, returning false and recording it in cfg.CacheEvents, unless
+// FailOnUnreadableCache is set, when its error is returned; a missing or too old cache just returns false.
+func loadCache[T any](cfg SocrataConfig, maxAge time.Duration) ([]T, bool, error) {
+	items, err := LoadCacheFile[T](cfg.CacheName(), maxAge, cfg.BadRecords)
+	if err == nil {
+		return items, true, nil
+	}
+	var cacheErr *CacheError
+	if errors.As(err, &cacheErr) && cacheErr.Kind == CacheUnreadable {
+		if FailOnUnreadableCache {
+			return nil, false, err
+		}
+		if cfg.CacheEvents != nil {
+			cfg.CacheEvents.Record(CacheEvent{Filename: cfg.CacheName(), Outcome: CacheDiscarded, Err: err})
+		}
+	}
+	return nil, false, nil
+}
+
+// CacheStatus checks that DankDir/cache has a file usable by CheckCacheFile, without reading it:
+// one written with the current CacheVersion, and no older than maxAge, as for CheckCacheFile.
+// Returns a *CacheError giving the reason if not.
+func CacheStatus(filename string, maxAge time.Duration) error {
+	if maxAge < 0 {
+		return &CacheError{Kind: CacheTooOld, Reason: "cache file is too old"}
+	}
+	stat, err := os.Stat(CacheFilePath(filename))
+	if err != nil {
+		return &CacheError{Kind: CacheMissing, Reason: "cache file not found", Err: err}
+	}
+	if version, err := readCacheVersion(filename); err != nil || version != CacheVersion {
+		return &CacheError{Kind: CacheMissing, Reason: "cache file version mismatch"}
+	}
+	if maxAge != AnyCacheAge && time.Now().After(stat.ModTime().Add(maxAge)) {
+		// now is past the max age
+		return &CacheError{Kind: CacheTooOld, Reason: "cache file is too old"}
+	}
+	return nil
+}
+
+// MakeCacheFile creates a cache file in the DankDir/cache directory and returns a writer of it,
+// which zstd-compresses what is written into the file named with CacheCompressedSuffix.
+// It writes to a temporary "*.tmp" file beside it, which Close renames into place, with its
+// version sidecar, and removes any legacy uncompressed cache it replaces.  If any write fails,
+// Close removes the temporary file instead, so a partial write never replaces a previous cache.
 // Returns nil with any error.
-func MakeCacheFile(filename string) (*os.File, error) {
-	cachedFilename := filepath.Join(dankRoot, DankDir, "cache", filename)
+func MakeCacheFile(filename string) (io.WriteCloser, error) {
+	cachedFilename := GetDankCachePathname(filename + CacheCompressedSuffix)
 	if err := os.MkdirAll(filepath.Dir(cachedFilename), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	cacheFile, err := os.Create(cachedFilename)
+	tmpFile, err := os.CreateTemp(filepath.Dir(cachedFilename), filepath.Base(cachedFilename)+".*.tmp")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cache file: %w", err)
 	}
+	encoder, err := CodecZstd.newWriter(tmpFile)
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+
+	return &cacheWriter{filename: filename, file: tmpFile, encoder: encoder}, nil
+}
+
+// cacheWriter compresses into a temporary cache file, see MakeCacheFile
+type cacheWriter struct {
+	filename string // cache filename, as given to GetDankCachePathname
+	file     *os.File
+	encoder  io.WriteCloser // compresses into file
+	err      error          // first write error, after which Close discards the file
+}
 
-	return cacheFile, nil
+// Write compresses p into the temporary file, remembering any error for Close
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.encoder.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	w.err = err
+	return n, err
+}
+
+// Close flushes the compressor and closes the temporary file, then renames it into place,
+// writes the version sidecar, and removes any legacy uncompressed cache.  After a failed
+// write, it removes the temporary file, leaving any previous cache as it was.
+func (w *cacheWriter) Close() error {
+	defer os.Remove(w.file.Name()) // once renamed, there is nothing to remove
+	if err := w.encoder.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.err != nil {
+		return fmt.Errorf("cache file not replaced after failed write: %w", w.err)
+	}
+	if err := os.Rename(w.file.Name(), GetDankCachePathname(w.filename+CacheCompressedSuffix)); err != nil {
+		return err
+	}
+	removeLegacyCache(w.filename)
+	return writeCacheVersion(w.filename)
+}
+
+// WriteCacheFile writes data to a cache file in the DankDir/cache directory, with its version sidecar.
+// If the cache file already holds exactly data, at the current CacheVersion, it is not rewritten,
+// and is touched if TouchUnchangedCache is set.
+// Any ETag sidecar of the data it replaces is removed, once it is replaced.
+// Returns true if the file was written, and any error.
+func WriteCacheFile(filename string, data []byte) (bool, error) {
+	return writeCacheFile(filename, data, "", nil)
+}
+
+// writeCacheFile is WriteCacheFile, recording etag, if any, in the cache's ETag sidecar,
+// and recording in events, if set, if the cache is kept unchanged
+func writeCacheFile(filename string, data []byte, etag string, events *CacheEvents) (bool, error) {
+	if cacheUnchanged(filename, sha256.Sum256(data), int64(len(data))) {
+		keepUnchangedCache(filename, events)
+		if etag != "" {
+			return false, writeCacheETag(filename, etag)
+		}
+		return false, nil
+	}
+	cacheFile, err := MakeCacheFile(filename)
+	if err != nil {
+		return false, err
+	}
+	if _, err := cacheFile.Write(data); err != nil {
+		cacheFile.Close()
+		return false, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := cacheFile.Close(); err != nil {
+		return false, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return true, replaceCacheETag(filename, etag)
+}
+
+// cacheUnchanged returns true if a compressed cache file exists at the current CacheVersion whose
+// content has the given size and SHA-256, so rewriting it would write the same bytes.  A legacy
+// uncompressed cache is never unchanged, so that it is replaced by a compressed one.
+func cacheUnchanged(filename string, sum [sha256.Size]byte, size int64) bool {
+	if version, err := readCacheVersion(filename); err != nil || version != CacheVersion {
+		return false
+	}
+	cacheFilename := GetDankCachePathname(filename + CacheCompressedSuffix)
+	if stat, err := os.Stat(cacheFilename); err != nil || !stat.Mode().IsRegular() {
+		return false
+	}
+	reader, err := OpenMaybeCompressed(cacheFilename)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, reader)
+	return err == nil && n == size && [sha256.Size]byte(hash.Sum(nil)) == sum
+}
+
+// keepUnchangedCache records in events, if set, that a cache write was skipped,
+// touching the file if TouchUnchangedCache is set
+func keepUnchangedCache(filename string, events *CacheEvents) {
+	if TouchUnchangedCache {
+		now := time.Now()
+		os.Chtimes(GetDankCachePathname(filename+CacheCompressedSuffix), now, now)
+	}
+	if events != nil {
+		events.Record(CacheEvent{Filename: filename, Outcome: CacheKept})
+	}
+}
+
+// writeCacheVersion writes the current CacheVersion to a cache file's version sidecar
+func writeCacheVersion(filename string) error {
+	versionFilename := GetDankCachePathname(filename + CacheVersionSuffix)
+	if err := os.WriteFile(versionFilename, []byte(strconv.Itoa(CacheVersion)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write cache version: %w", err)
+	}
+	return nil
+}
+
+// readCacheVersion returns the CacheVersion recorded in a cache file's version sidecar.
+// Returns an error if there is no sidecar, as for caches written before versioning.
+func readCacheVersion(filename string) (int, error) {
+	versionBytes, err := os.ReadFile(GetDankCachePathname(filename + CacheVersionSuffix))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(versionBytes)))
+}
+
+// EachCacheRecord calls fn with each record of a cache file, in order, without loading the
+// whole file.  The cache may hold a JSON array of records or newline-delimited JSON (NDJSON),
+// and may be zstd or gzip compressed, as it is kept.  Iteration stops early when fn returns false.
+// Unlike CheckCacheFile, it ignores the file's age and version, for inspecting any cache.
+func EachCacheRecord(filename string, fn func(record json.RawMessage) bool) error {
+	reader, err := OpenMaybeCompressed(CacheFilePath(filename))
+	if err != nil {
+		return &CacheError{Kind: CacheMissing, Reason: "cache file not found", Err: err}
+	}
+	defer reader.Close()
+
+	br := bufio.NewReader(reader)
+	decoder := json.NewDecoder(br)
+	if first, err := peekNonSpace(br); err == nil && first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return &CacheError{Kind: CacheUnreadable, Reason: "cache file read error", Err: err}
+		}
+		for decoder.More() {
+			var record json.RawMessage
+			if err := decoder.Decode(&record); err != nil {
+				return &CacheError{Kind: CacheUnreadable, Reason: "cache file read error", Err: err}
+			}
+			if !fn(record) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	// NDJSON, one record per line
+	for {
+		var record json.RawMessage
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return &CacheError{Kind: CacheUnreadable, Reason: "cache file read error", Err: err}
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+}
+
+// peekNonSpace returns the first byte of r that is not JSON whitespace, without consuming it
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// VerifyCacheFile checks that every record of a cache file, read as EachCacheRecord does,
+// decodes as a T.  If strict, records with fields that T does not define also fail; only
+// a record's top-level fields are checked, as types with their own UnmarshalJSON would
+// otherwise accept unknown fields however they are decoded.
+// Returns the number of records that decoded, and a *CacheError for the first record that
+// did not, or if the cache was not written with the current CacheVersion.
+func VerifyCacheFile[T any](filename string, strict bool) (int, error) {
+	var known map[string]bool
+	if strict {
+		known = jsonFieldNames(reflect.TypeFor[T]())
+	}
+	count := 0
+	var decodeErr error
+	err := EachCacheRecord(filename, func(record json.RawMessage) bool {
+		var item T
+		err := json.Unmarshal(record, &item)
+		if err == nil && known != nil {
+			err = checkKnownFields(record, known)
+		}
+		if err != nil {
+			decodeErr = &CacheError{Kind: CacheUnreadable, Reason: fmt.Sprintf("cache record %d does not decode", count), Err: err}
+			return false
+		}
+		count++
+		return true
+	})
+	if err := cmp.Or(err, decodeErr); err != nil {
+		return count, err
+	}
+	if version, err := readCacheVersion(filename); err != nil || version != CacheVersion {
+		return count, &CacheError{Kind: CacheMissing, Reason: "cache file version mismatch"}
+	}
+	return count, nil
+}
+
+// jsonFieldNames returns the lowercased JSON names of struct type t's fields, as encoding/json
+// matches them, or nil if t is not a struct
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			maps.Copy(names, jsonFieldNames(field.Type))
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
+
+// checkKnownFields returns an error naming the first field of a JSON object record that is not known,
+// or nil if the record is not an object
+func checkKnownFields(record json.RawMessage, known map[string]bool) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(fields))
+	for _, name := range names {
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("unknown field %q", name)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,335 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeCacheNameContained(t *testing.T) {
+	useTestDankRoot(t)
+	cacheDir := GetDankCacheDir()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"us_ct_brands.json", "us_ct_brands.json"},
+		{"../x", "___x"},
+		{"../../etc/passwd", "______etc_passwd"},
+		{"/abs", "_abs"},
+		{"/etc/passwd", "_etc_passwd"},
+		{"a/../../b", "a_______b"},
+		{`..\..\windows`, "______windows"},
+		{"..", "__"},
+		{".", "_"},
+		{"", "_"},
+		{"x\x00.json", "x_.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeCacheName(tt.name)
+			if got != tt.want {
+				t.Errorf("sanitizeCacheName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			if strings.ContainsAny(got, `/\`+"\x00") {
+				t.Errorf("sanitizeCacheName(%q) = %q, which is not a single path element", tt.name, got)
+			}
+			for _, path := range []string{GetDankCachePathname(tt.name), GetDankCachePathname(tt.name + CacheCompressedSuffix)} {
+				rel, err := filepath.Rel(cacheDir, path)
+				if err != nil || rel == "." || strings.HasPrefix(rel, "..") || filepath.Dir(rel) != "." {
+					t.Errorf("GetDankCachePathname(%q) = %q, not directly within %s", tt.name, path, cacheDir)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCacheFileContained(t *testing.T) {
+	useTestDankRoot(t)
+	for _, name := range []string{"../escape.json", "../../escape.json", "/tmp/escape.json"} {
+		if _, err := WriteCacheFile(name, []byte(`[]`)); err != nil {
+			t.Fatalf("WriteCacheFile(%q) = %v", name, err)
+		}
+	}
+	for _, pattern := range []string{"escape.json*", "*/escape.json*"} {
+		if outside, _ := filepath.Glob(filepath.Join(GetDankRoot(), pattern)); len(outside) > 0 {
+			t.Errorf("cache files written outside the cache directory: %v", outside)
+		}
+	}
+	inside, _ := filepath.Glob(filepath.Join(GetDankCacheDir(), "*escape.json"+CacheCompressedSuffix))
+	if len(inside) != 3 {
+		t.Errorf("cache directory holds %v, want the 3 sanitized caches", inside)
+	}
+}
+
+// failingWriter stands in for a cache file's compressor, failing once limit bytes are written:
+// with err, or with a short write if err is nil
+type failingWriter struct {
+	limit int
+	err   error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.limit {
+		w.limit -= len(p)
+		return len(p), nil
+	}
+	n := w.limit
+	w.limit = 0
+	return n, w.err
+}
+
+func (w *failingWriter) Close() error { return nil }
+
+// cacheDirFiles returns the names of the files in the cache directory
+func cacheDirFiles(t *testing.T) []string {
+	entries, err := os.ReadDir(GetDankCacheDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// checkCacheSurvived checks that a cache file is byte-for-byte as it was, and that no temporary
+// cache file was left behind
+func checkCacheSurvived(t *testing.T, filename string, before []byte) {
+	t.Helper()
+	after, err := os.ReadFile(CacheFilePath(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, before) {
+		t.Errorf("cache file changed from %d bytes to %d", len(before), len(after))
+	}
+	for _, name := range cacheDirFiles(t) {
+		if strings.HasSuffix(name, ".tmp") || strings.HasPrefix(name, ".stream-") {
+			t.Errorf("temporary cache file %s left behind", name)
+		}
+	}
+}
+
+func TestMakeCacheFileFailedWriteKeepsCache(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error // error of the failing write; nil for a short write
+	}{
+		{name: "short write"},
+		{name: "write error", err: errors.New("no space left on device")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			const filename = "survivor.json"
+			if _, err := WriteCacheFile(filename, []byte(`[{"id":"1"},{"id":"2"}]`)); err != nil {
+				t.Fatal(err)
+			}
+			before, err := os.ReadFile(CacheFilePath(filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := MakeCacheFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.(*cacheWriter).encoder = &failingWriter{limit: 4, err: tt.err}
+			if _, err := w.Write([]byte(`[{"id":"3"}]`)); err == nil {
+				t.Error("Write() succeeded, want its failure")
+			} else if tt.err == nil && !errors.Is(err, io.ErrShortWrite) {
+				t.Errorf("Write() = %v, want io.ErrShortWrite", err)
+			}
+			if err := w.Close(); err == nil {
+				t.Error("Close() after a failed write succeeded, want an error")
+			}
+			checkCacheSurvived(t, filename, before)
+
+			cached, err := LoadCacheFile[testRecord](filename, AnyCacheAge, nil)
+			if err != nil || len(cached) != 2 {
+				t.Errorf("LoadCacheFile() = %v, %v; want the 2 records first cached", cached, err)
+			}
+		})
+	}
+}
+
+func TestWriteCacheFileReplacesETagOnceWritten(t *testing.T) {
+	useTestDankRoot(t)
+	const filename = "revalidated.json"
+	if _, err := writeCacheFile(filename, []byte(`[{"id":"1"}]`), "v1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in place of the cache fails the rename of each write, as a full disk might fail it
+	blocked := GetDankCachePathname(filename + CacheCompressedSuffix)
+	if err := os.Remove(blocked); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(blocked, "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, etag := range []string{"v2", ""} {
+		if _, err := writeCacheFile(filename, []byte(`[{"id":"2"}]`), etag, nil); err == nil {
+			t.Fatalf("writeCacheFile() with ETag %q over a directory succeeded, want its failure", etag)
+		}
+		if got := readCacheETag(filename); got != "v1" {
+			t.Errorf("ETag after a failed write with ETag %q = %q, want the previous cache's v1", etag, got)
+		}
+	}
+	if err := os.RemoveAll(blocked); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once written, the new records have their own ETag, or none
+	for _, etag := range []string{"v2", ""} {
+		if _, err := writeCacheFile(filename, []byte(`[{"id":"`+etag+`"}]`), etag, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := readCacheETag(filename); got != etag {
+			t.Errorf("ETag after a write with ETag %q = %q", etag, got)
+		}
+	}
+}
+
+func TestMismatchedCacheVersionRefetched(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string // content of the version sidecar, or "" for none
+	}{
+		{name: "older version", version: strconv.Itoa(CacheVersion-1) + "\n"},
+		{name: "newer version", version: strconv.Itoa(CacheVersion+1) + "\n"},
+		{name: "garbled version", version: "v1\n"},
+		{name: "no version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			const filename = "versioned.json"
+			server := newSocrataServer(t, testRecords(5))
+			cfg := SocrataConfig{URL: server.URL, CacheFilename: filename, OrderBy: "week"}
+			if _, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch); err != nil {
+				t.Fatal(err)
+			}
+
+			// A fresh cache of the current version is used as it is
+			server.mu.Lock()
+			server.records = testRecords(6)
+			server.mu.Unlock()
+			requests := len(server.requests())
+			if cached, err := FetchSocrata[testRecord](cfg, "", time.Hour); err != nil || len(cached) != 5 {
+				t.Fatalf("FetchSocrata() = %d records, %v, want the 5 cached", len(cached), err)
+			}
+			if len(server.requests()) != requests {
+				t.Fatal("FetchSocrata() refetched a fresh cache of the current version")
+			}
+
+			versionFile := GetDankCachePathname(filename + CacheVersionSuffix)
+			if tt.version == "" {
+				if err := os.Remove(versionFile); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(versionFile, []byte(tt.version), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var cacheErr *CacheError
+			if _, err := LoadCacheFile[testRecord](filename, AnyCacheAge, nil); !errors.As(err, &cacheErr) || cacheErr.Kind != CacheMissing {
+				t.Errorf("LoadCacheFile() = %v, want a CacheMissing *CacheError", err)
+			}
+
+			// Once its version does not match, the cache is ignored, however fresh, and refetched
+			records, err := FetchSocrata[testRecord](cfg, "", time.Hour)
+			if err != nil || len(records) != 6 {
+				t.Fatalf("FetchSocrata() = %d records, %v, want the 6 refetched", len(records), err)
+			}
+			if len(server.requests()) == requests {
+				t.Error("FetchSocrata() did not refetch a cache of another version")
+			}
+			if version, err := readCacheVersion(filename); err != nil || version != CacheVersion {
+				t.Errorf("refetched cache has version %d, %v, want %d", version, err, CacheVersion)
+			}
+		})
+	}
+}
+
+func TestFailedFetchKeepsCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawCache bool
+	}{
+		{name: "re-encoded cache", rawCache: false},
+		{name: "raw cache, streamed to a temporary file", rawCache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			const filename = "fetched.json"
+			server := newSocrataServer(t, testRecords(5))
+			cfg := SocrataConfig{URL: server.URL, CacheFilename: filename, OrderBy: "week", BatchSize: 2, RawCache: tt.rawCache}
+			if _, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch); err != nil {
+				t.Fatal(err)
+			}
+			before, err := os.ReadFile(CacheFilePath(filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The fetch fails after its first page, which a raw cache has already written
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("$offset") != "0" {
+					http.Error(w, "bad request", http.StatusBadRequest)
+					return
+				}
+				server.serve(w, r)
+			}))
+			defer failing.Close()
+			cfg.URL = failing.URL
+			server.mu.Lock()
+			server.records = testRecords(6)
+			server.mu.Unlock()
+			if _, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch); err == nil {
+				t.Fatal("FetchSocrata() succeeded, want the failed page's error")
+			}
+			checkCacheSurvived(t, filename, before)
+		})
+	}
+}
+
+func TestUnreadableCacheDiscardedEvent(t *testing.T) {
+	useTestDankRoot(t)
+	server := newSocrataServer(t, testRecords(3))
+	cfg := SocrataConfig{URL: server.URL, CacheFilename: "corrupt.json", OrderBy: "week", CacheEvents: &CacheEvents{}}
+	if _, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(CacheFilePath(cfg.CacheName()), []byte("not zstd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := FetchSocrata[testRecord](cfg, "", AnyCacheAge)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("FetchSocrata() = %d records, %v; want the 3 fetched again", len(records), err)
+	}
+	var discarded []CacheEvent
+	for _, event := range cfg.CacheEvents.Events() {
+		if event.Outcome == CacheDiscarded {
+			discarded = append(discarded, event)
+		}
+	}
+	var cacheErr *CacheError
+	if len(discarded) != 1 || discarded[0].Filename != cfg.CacheName() ||
+		!errors.As(discarded[0].Err, &cacheErr) || cacheErr.Kind != CacheUnreadable {
+		t.Errorf("discarded cache events = %+v, want one of %s with its unreadable *CacheError", discarded, cfg.CacheName())
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ChunkLimits bound the files a ChunkedJSONWriter writes.  A zero limit is no limit.
+type ChunkLimits struct {
+	Records int   // Maximum records per file
+	Bytes   int64 // Maximum bytes per file; a record larger than this gets a file of its own
+}
+
+// IsZero returns true if there are no limits, so the JSON is written to a single file
+func (l ChunkLimits) IsZero() bool {
+	return l.Records <= 0 && l.Bytes <= 0
+}
+
+// JSONChunk is a file written by a ChunkedJSONWriter
+type JSONChunk struct {
+	Filename string // Path of the file
+	Records  int    // Number of records given to the file
+	Rows     int    // Number of records that reached the file
+}
+
+// ChunkFilename returns the name of the n'th chunk of filename, counting from 1,
+// numbered before its extension, e.g. "us_ct_brands.0001.json"
+func ChunkFilename(filename string, n int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%04d%s", strings.TrimSuffix(filename, ext), n, ext)
+}
+
+// ChunkedJSONWriter writes items as JSON arrays split across numbered files, named by
+// ChunkFilename, rolling to the next file when one reaches its limits.  Each file is a
+// complete JSON array formatted as WriteJSON would, and records are never split between
+// files, so concatenating the files' arrays gives the whole dataset.  Without limits,
+// every item is written to filename itself.
+type ChunkedJSONWriter[T any] struct {
+	filename string
+	limits   ChunkLimits
+	current  *JSONArrayWriter[T]
+	chunks   []JSONChunk
+	count    int // number of items written so far, across files
+}
+
+// NewChunkedJSONWriter returns a writer of filename's chunks.  The first file is created
+// by the first Write, or by Close if there are no items, and Close must be called to end it.
+func NewChunkedJSONWriter[T any](filename string, limits ChunkLimits) *ChunkedJSONWriter[T] {
+	return &ChunkedJSONWriter[T]{filename: filename, limits: limits}
+}
+
+// Write appends items to the current file, rolling to a new one at its limits
+func (cw *ChunkedJSONWriter[T]) Write(items []T) error {
+	for _, item := range items {
+		itemBytes, err := encodeJSONItem(item, cw.count)
+		if err != nil {
+			return err
+		}
+		if cw.current != nil && cw.full(itemBytes) {
+			if err := cw.closeCurrent(); err != nil {
+				return err
+			}
+		}
+		if cw.current == nil {
+			if err := cw.openNext(); err != nil {
+				return err
+			}
+		}
+		cw.chunks[len(cw.chunks)-1].Records++
+		cw.count++
+		if err := cw.current.writeEncoded(itemBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ends the last file, returning every file written, in order
+func (cw *ChunkedJSONWriter[T]) Close() ([]JSONChunk, error) {
+	if cw.current == nil && len(cw.chunks) == 0 {
+		if err := cw.openNext(); err != nil {
+			return nil, err
+		}
+	}
+	if cw.current != nil {
+		if err := cw.closeCurrent(); err != nil {
+			return cw.chunks, err
+		}
+	}
+	return cw.chunks, nil
+}
+
+// full returns true if the current file cannot take another encoded item within the limits.
+// An empty file takes any item.
+func (cw *ChunkedJSONWriter[T]) full(itemBytes []byte) bool {
+	if cw.current.count == 0 {
+		return false
+	}
+	if cw.limits.Records > 0 && cw.current.count >= cw.limits.Records {
+		return true
+	}
+	return cw.limits.Bytes > 0 && cw.current.sizeWith(itemBytes) > cw.limits.Bytes
+}
+
+// openNext creates the next file
+func (cw *ChunkedJSONWriter[T]) openNext() error {
+	filename := cw.filename
+	if !cw.limits.IsZero() {
+		filename = ChunkFilename(cw.filename, len(cw.chunks)+1)
+	}
+	jw, err := NewJSONArrayWriter[T](filename)
+	if err != nil {
+		return err
+	}
+	cw.current = jw
+	cw.chunks = append(cw.chunks, JSONChunk{Filename: filename})
+	return nil
+}
+
+// closeCurrent ends the current file, recording how many rows it wrote
+func (cw *ChunkedJSONWriter[T]) closeCurrent() error {
+	rows, err := cw.current.Close()
+	cw.current = nil
+	cw.chunks[len(cw.chunks)-1].Rows = rows
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import "strings"
+
+// CleanReport records a single action taken on a record while cleaning a dataset
+type CleanReport struct {
+	Dataset string `json:"dataset"` // Dataset name, e.g. "brands"
+	Record  string `json:"record"`  // Identifying key of the affected record
+	Field   string `json:"field"`   // Affected column, empty if the whole record
+	Action  string `json:"action"`  // What was done, e.g. "clamp" or "drop"
+	Detail  string `json:"detail"`  // Human-readable description
+}
+
+// CSVHeaders returns the CSV headers for the CleanReport struct
+func (r CleanReport) CSVHeaders() string {
+	return `"dataset","record","field","action","detail"
+`
+}
+
+// CSVValue returns the CSV value for the CleanReport struct
+func (r CleanReport) CSVValue() string {
+	var row CSVRow
+	row.String(r.Dataset)
+	row.String(r.Record)
+	row.String(r.Field)
+	row.String(r.Action)
+	row.String(r.Detail)
+	return row.Line()
+}
+
+// csvFormulaChars are the characters that make spreadsheets read a field beginning with one as a formula
+const csvFormulaChars = "=+-@"
+
+// CSVString sanitizes a string for use in a double-quoted CSV file field.  Embedded double quotes
+// are doubled, as RFC 4180 escapes them; commas and newlines need no escaping within the quotes.
+// A string beginning with a formula character, '=', '+', '-', or '@', is prefixed with a single
+// quote, so spreadsheets show it as text rather than evaluating it, as is a string of single
+// quotes followed by one, so that UnguardCSVString can tell them apart.
+func CSVString(str string) string {
+	if formulaLike(str) {
+		str = "'" + str
+	}
+	return strings.ReplaceAll(str, `"`, `""`)
+}
+
+// UnguardCSVString returns a CSV field, as read by a CSV reader, without the single quote that
+// CSVString prefixes to strings beginning with a formula character
+func UnguardCSVString(field string) string {
+	if rest, ok := strings.CutPrefix(field, "'"); ok && formulaLike(rest) {
+		return rest
+	}
+	return field
+}
+
+// formulaLike returns true if str begins with a formula character, after any single quotes
+func formulaLike(str string) bool {
+	str = strings.TrimLeft(str, "'")
+	return str != "" && strings.ContainsRune(csvFormulaChars, rune(str[0]))
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+// csvStringCases are strings that CSVString must escape or guard, and what it writes for each
+var csvStringCases = []struct {
+	name string
+	in   string
+	want string
+}{
+	{name: "plain", in: "Blue Dream", want: "Blue Dream"},
+	{name: "empty", in: "", want: ""},
+	{name: "embedded quotes", in: `the "best" one`, want: `the ""best"" one`},
+	{name: "only a quote", in: `"`, want: `""`},
+	{name: "comma", in: "Entity, LLC", want: "Entity, LLC"},
+	{name: "LF", in: "line one\nline two", want: "line one\nline two"},
+	{name: "CR", in: "line one\rline two", want: "line one\rline two"},
+	{name: "leading =", in: "=HYPERLINK(\"x\")", want: "'=HYPERLINK(\"\"x\"\")"},
+	{name: "leading +", in: "+1", want: "'+1"},
+	{name: "leading -", in: "-2 mg", want: "'-2 mg"},
+	{name: "leading @", in: "@SUM(A1)", want: "'@SUM(A1)"},
+	{name: "formula character not leading", in: "a=b", want: "a=b"},
+	{name: "quote before text", in: "'quoted", want: "'quoted"},
+	{name: "quote before formula", in: "'=x", want: "''=x"},
+	{name: "quotes before formula", in: "''=x", want: "'''=x"},
+}
+
+func TestCSVString(t *testing.T) {
+	for _, tt := range csvStringCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CSVString(tt.in); got != tt.want {
+				t.Errorf("CSVString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCSVStringRoundTrip writes each string as a quoted CSV field, as the exports do, and
+// checks that a CSV reader and UnguardCSVString give it back
+func TestCSVStringRoundTrip(t *testing.T) {
+	for _, tt := range csvStringCases {
+		t.Run(tt.name, func(t *testing.T) {
+			line := `"` + CSVString(tt.in) + `","next"` + "\n"
+			record, err := csv.NewReader(strings.NewReader(line)).Read()
+			if err != nil {
+				t.Fatalf("failed to read %q: %v", line, err)
+			}
+			if len(record) != 2 || record[1] != "next" {
+				t.Fatalf("read %q as %q, want 2 fields", line, record)
+			}
+			if got := UnguardCSVString(record[0]); got != tt.in {
+				t.Errorf("UnguardCSVString(%q) = %q, want %q", record[0], got, tt.in)
+			}
+		})
+	}
+}
+
+func TestUnguardCSVString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "'=x", want: "=x"},
+		{in: "''=x", want: "'=x"},
+		{in: "'''=x", want: "''=x"},
+		{in: "'quoted", want: "'quoted"},
+		{in: "'", want: "'"},
+		{in: "=x", want: "=x"},
+		{in: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := UnguardCSVString(tt.in); got != tt.want {
+			t.Errorf("UnguardCSVString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is a compression format for output files
+type Codec string
+
+const (
+	CodecZstd Codec = "zstd" // Zstandard, the default
+	CodecGzip Codec = "gzip" // gzip
+)
+
+// codecInfo is the extension and magic number of each codec
+var codecInfo = map[Codec]struct {
+	extension string
+	magic     []byte
+}{
+	CodecZstd: {".zst", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	CodecGzip: {".gz", []byte{0x1f, 0x8b}},
+}
+
+// ParseCodec returns the Codec with the given name.
+// Returns an error if the codec is not supported.
+func ParseCodec(name string) (Codec, error) {
+	codec := Codec(name)
+	if _, ok := codecInfo[codec]; !ok {
+		return "", fmt.Errorf("unsupported compression codec %q (expected 'zstd' or 'gzip')", name)
+	}
+	return codec, nil
+}
+
+// Extension returns the default filename extension of the codec, e.g. ".zst"
+func (c Codec) Extension() string {
+	return codecInfo[c].extension
+}
+
+// newWriter returns a WriteCloser compressing into w with the codec
+func (c Codec) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CodecZstd:
+		return zstd.NewWriter(w)
+	case CodecGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %q", c)
+	}
+}
+
+// CompressFile compresses filename with the codec into a new file named filename+extension,
+// leaving the original in place.  If extension is empty, the codec's default is used.
+// Returns the compressed file's name, and an error, if any.
+func CompressFile(filename string, codec Codec, extension string) (string, error) {
+	if extension == "" {
+		extension = codec.Extension()
+	}
+	outFilename := filename + extension
+
+	input, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file for compression: %w", err)
+	}
+	defer input.Close()
+
+	output, err := os.Create(outFilename)
+	if err != nil {
+		return "", fmt.Errorf("failed to create compressed file: %w", err)
+	}
+	defer output.Close()
+
+	encoder, err := codec.newWriter(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s encoder: %w", codec, err)
+	}
+	if _, err := io.Copy(encoder, input); err != nil {
+		encoder.Close()
+		return "", fmt.Errorf("failed to write compressed data: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to write compressed data: %w", err)
+	}
+	return outFilename, output.Close()
+}
+
+// SniffCodec returns the codec a file is compressed with, sniffed from its leading magic number
+// like OpenMaybeCompressed, or "" if it is not compressed.
+func SniffCodec(path string) (Codec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(file, head)
+	for _, codec := range []Codec{CodecZstd, CodecGzip} {
+		if bytes.HasPrefix(head[:n], codecInfo[codec].magic) {
+			return codec, nil
+		}
+	}
+	return "", nil
+}
+
+// OpenMaybeCompressed opens a file for reading, transparently decompressing it
+// if it is zstd or gzip compressed.  The format is sniffed from the file's
+// leading magic number rather than its extension, so files with any extension
+// are handled, and anything else is passed through as plain text.
+// Returns nil with any error.
+func OpenMaybeCompressed(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(file)
+	head, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(head, codecInfo[CodecZstd].magic):
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		return &decompressReader{Reader: decoder, closeFn: decoder.Close, file: file}, nil
+	case bytes.HasPrefix(head, codecInfo[CodecGzip].magic):
+		decoder, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create gzip decoder: %w", err)
+		}
+		return &decompressReader{Reader: decoder, closeFn: func() { decoder.Close() }, file: file}, nil
+	default:
+		return &decompressReader{Reader: br, file: file}, nil
+	}
+}
+
+// decompressReader reads through a decoder, closing both it and the underlying file
+type decompressReader struct {
+	io.Reader
+	closeFn func() // closes the decoder, if any
+	file    *os.File
+}
+
+// Close closes the decoder and the underlying file
+func (r *decompressReader) Close() error {
+	if r.closeFn != nil {
+		r.closeFn()
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ContentHash returns a hex SHA-256 fingerprint of the records' data, independent of their
+// order and of how they are exported.  Each record is encoded as canonical JSON, with its
+// object keys sorted, and the records are hashed in the order of their key, then of their
+// encoding, so records with the same key are still ordered.  A nil key orders the records
+// by their encoding alone.  Records that cannot be encoded as JSON are hashed as "null".
+func ContentHash[T any](items []T, key func(T) string) string {
+	type keyed struct {
+		key     string
+		encoded []byte
+	}
+	records := make([]keyed, len(items))
+	for i, item := range items {
+		records[i].encoded = canonicalJSON(item)
+		if key != nil {
+			records[i].key = key(item)
+		}
+	}
+	slices.SortFunc(records, func(a, b keyed) int {
+		if c := strings.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.encoded, b.encoded)
+	})
+
+	h := sha256.New()
+	for _, record := range records {
+		h.Write(record.encoded)
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalJSON returns v encoded as JSON with its object keys sorted and no insignificant
+// whitespace, preserving numbers as they were encoded.  Returns "null" if v cannot be encoded.
+func canonicalJSON(v any) []byte {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return []byte("null")
+	}
+	// Round trip through generic values, whose maps json.Marshal writes in key order
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return encoded
+	}
+	if canonical, err := json.Marshal(generic); err == nil {
+		return canonical
+	}
+	return encoded
+}
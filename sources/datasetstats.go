@@ -0,0 +1,202 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// LowCardinality is the most distinct values a string column may have for DatasetStats to count each of them
+const LowCardinality = 20
+
+// Stats profiles a dataset's records, as written to its --emit-stats sidecar
+type Stats struct {
+	Records int           `json:"records"` // Number of records profiled
+	Columns []ColumnStats `json:"columns"` // Profile of each field, in field order
+}
+
+// ColumnStats profiles one field of a dataset's records.  Which statistics are set depends on its type:
+// numbers and measures have their range and mean, and strings and bools their distinct values.
+type ColumnStats struct {
+	Column    string         `json:"column"`             // JSON name of the field, a dotted path for nested fields
+	Type      string         `json:"type"`               // As in a DictionaryEntry, or "list"
+	Empty     int            `json:"empty"`              // Number of records with the field null or empty
+	EmptyRate float64        `json:"empty_rate"`         // Fraction of records with the field null or empty
+	Trace     int            `json:"trace,omitempty"`    // Number of trace measures, which have no amount
+	Invalid   int            `json:"invalid,omitempty"`  // Number of values of a numeric field that are not numbers
+	Min       *float64       `json:"min,omitempty"`      // Least amount; nil if there are none
+	Max       *float64       `json:"max,omitempty"`      // Greatest amount; nil if there are none
+	Mean      *float64       `json:"mean,omitempty"`     // Mean amount; nil if there are none
+	Distinct  int            `json:"distinct,omitempty"` // Number of distinct non-empty values of a string or bool field
+	Values    map[string]int `json:"values,omitempty"`   // Count of each value, if there are at most LowCardinality of them
+}
+
+// measureAmount is implemented by measure types, whose amounts DatasetStats summarizes
+type measureAmount interface {
+	Amount() (float64, bool, bool) // amount, trace, empty
+}
+
+// statsField is a field that DatasetStats profiles, found by the index path of its struct field
+type statsField struct {
+	name  string
+	index []int
+	typ   string
+}
+
+// DatasetStats profiles the records, with a ColumnStats for each of T's JSON fields, nested
+// structs flattened into dotted paths, e.g. "lab_analysis.url".  Measures and numeric fields,
+// including FlexInt and FlexFloat, are summarized by their range and mean, and string and bool
+// fields by their distinct values.  Zero dates, nil pointers, and empty strings, lists,
+// and measures are empty.  T must be a struct.
+func DatasetStats[T any](items []T) Stats {
+	fields := statsFields(reflect.TypeFor[T](), "", nil)
+	stats := Stats{Records: len(items), Columns: make([]ColumnStats, len(fields))}
+	for c, field := range fields {
+		col := ColumnStats{Column: field.name, Type: field.typ}
+		var amounts []float64
+		values := make(map[string]int)
+		for i := range items {
+			v := reflect.ValueOf(&items[i]).Elem().FieldByIndex(field.index)
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					col.Empty++
+					continue
+				}
+				v = v.Elem()
+			}
+			switch field.typ {
+			case "measure":
+				amount, trace, empty := v.Interface().(measureAmount).Amount()
+				switch {
+				case empty:
+					col.Empty++
+				case trace:
+					col.Trace++
+				default:
+					amounts = append(amounts, amount)
+				}
+			case "date":
+				if v.Interface().(timeType).IsZero() {
+					col.Empty++
+				}
+			case "list":
+				if v.Len() == 0 {
+					col.Empty++
+				}
+			case "int", "number":
+				amount, empty, ok := numericValue(v)
+				switch {
+				case empty:
+					col.Empty++
+				case !ok:
+					col.Invalid++
+				default:
+					amounts = append(amounts, amount)
+				}
+			case "bool":
+				values[strconv.FormatBool(v.Bool())]++
+			default:
+				if s := fmt.Sprint(v.Interface()); s == "" {
+					col.Empty++
+				} else {
+					values[s]++
+				}
+			}
+		}
+		if len(items) > 0 {
+			col.EmptyRate = float64(col.Empty) / float64(len(items))
+		}
+		if len(amounts) > 0 {
+			lo, hi, sum := amounts[0], amounts[0], 0.0
+			for _, amount := range amounts {
+				lo, hi, sum = min(lo, amount), max(hi, amount), sum+amount
+			}
+			mean := sum / float64(len(amounts))
+			col.Min, col.Max, col.Mean = &lo, &hi, &mean
+		}
+		col.Distinct = len(values)
+		if len(values) > 0 && len(values) <= LowCardinality {
+			col.Values = values
+		}
+		stats.Columns[c] = col
+	}
+	return stats
+}
+
+// WriteStats writes the stats to a JSON file with pretty formatting
+func WriteStats(filename string, stats Stats) error {
+	statsBytes, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	if err := os.WriteFile(filename, append(statsBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+	return nil
+}
+
+// statsFields returns the fields of struct type t to profile, named by their JSON tags under prefix
+func statsFields(t reflect.Type, prefix string, index []int) []statsField {
+	var fields []statsField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name := prefix + field.Name
+		if tag != "" {
+			name = prefix + tag
+		}
+		path := append(append([]int(nil), index...), i)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch typ := fieldType(ft); {
+		case typ == "measure" && !ft.Implements(reflect.TypeFor[measureAmount]()):
+			fields = append(fields, statsField{name: name, index: path, typ: "string"}) // no amounts to summarize
+		case typ == "measure" || typ == "date":
+			fields = append(fields, statsField{name: name, index: path, typ: typ})
+		case ft.Kind() == reflect.Struct && field.Type.Kind() != reflect.Pointer:
+			fields = append(fields, statsFields(ft, name+".", path)...)
+		case ft.Kind() == reflect.Slice || ft.Kind() == reflect.Map:
+			fields = append(fields, statsField{name: name, index: path, typ: "list"})
+		default:
+			fields = append(fields, statsField{name: name, index: path, typ: typ})
+		}
+	}
+	return fields
+}
+
+// numericValue returns the amount of a numeric field, whether it is empty, and whether it is a number
+func numericValue(v reflect.Value) (float64, bool, bool) {
+	switch f := v.Interface().(type) {
+	case FlexInt:
+		if f == "" {
+			return 0, true, true
+		}
+		n, err := f.Int()
+		return float64(n), false, err == nil
+	case FlexFloat:
+		if f == "" {
+			return 0, true, true
+		}
+		x, err := f.Float()
+		return x, false, err == nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	default:
+		return v.Float(), false, true
+	}
+}
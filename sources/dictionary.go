@@ -0,0 +1,145 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// DictionaryEntry describes one CSV column of a dataset, as a row of a data dictionary
+type DictionaryEntry struct {
+	Dataset  string `json:"dataset"`  // Dataset name, e.g. "brands"
+	Column   string `json:"column"`   // Column name, as in the CSV export and DuckDB table
+	Type     string `json:"type"`     // "string", "measure", "int", "number", "bool", or "date"
+	Source   string `json:"source"`   // Socrata field the column is read from, a dotted path for nested fields
+	Nullable bool   `json:"nullable"` // False if the DuckDB column is NOT NULL
+}
+
+// CSVHeaders returns the CSV headers for the DictionaryEntry struct
+func (e DictionaryEntry) CSVHeaders() string {
+	return `"dataset","column","type","source","nullable"
+`
+}
+
+// CSVValue returns the CSV value for the DictionaryEntry struct
+func (e DictionaryEntry) CSVValue() string {
+	var row CSVRow
+	row.String(e.Dataset)
+	row.String(e.Column)
+	row.String(e.Type)
+	row.String(e.Source)
+	row.Bool(e.Nullable)
+	return row.Line()
+}
+
+// measureType is implemented by measure types, whose columns are of type "measure"
+type measureType interface {
+	IsTrace() bool
+	IsEmpty() bool
+}
+
+// timeType is implemented by time.Time and types embedding it, such as iso8601.Time,
+// whose columns are of type "date"
+type timeType interface {
+	Unix() int64
+	IsZero() bool
+}
+
+// ddlColumnPattern matches a column definition of a CREATE TABLE statement, e.g. "    status TEXT NOT NULL,"
+var ddlColumnPattern = regexp.MustCompile(`(?m)^\s+([a-z_][a-z0-9_]*)\s+([A-Z]+)([^,\n]*)`)
+
+// Dictionary returns the data dictionary of the dataset's records of type T, an entry for each of
+// its Columns, in order.  Types and sources are found by reflection over T's fields and their
+// JSON tags, and nullability from ddl, the CREATE TABLE statement of the dataset's DuckDB table;
+// columns that ddl types as dates are of type "date".
+// Returns an error if a column's source field is not one of T's fields.
+func Dictionary[T CSVImportable[T]](dataset string, ddl string) ([]DictionaryEntry, error) {
+	ddlTypes, notNull := make(map[string]string), make(map[string]bool)
+	if body, _, ok := strings.Cut(ddl, ");"); ok {
+		for _, match := range ddlColumnPattern.FindAllStringSubmatch(body, -1) {
+			ddlTypes[match[1]] = match[2]
+			notNull[match[1]] = strings.Contains(match[3], "NOT NULL") || strings.Contains(match[3], "PRIMARY KEY")
+		}
+	}
+
+	var zero T
+	columns := zero.Columns()
+	entries := make([]DictionaryEntry, 0, len(columns))
+	for _, column := range columns {
+		source := column.Source
+		if source == "" {
+			source = column.Name
+		}
+		field, ok := jsonField(reflect.TypeOf(zero), source)
+		if !ok {
+			return nil, fmt.Errorf("failed to describe %s column %s: no field %q", dataset, column.Name, source)
+		}
+		typ := fieldType(field)
+		switch ddlTypes[column.Name] {
+		case "DATE", "DATETIME", "TIMESTAMP":
+			typ = "date"
+		}
+		entries = append(entries, DictionaryEntry{
+			Dataset:  dataset,
+			Column:   column.Name,
+			Type:     typ,
+			Source:   source,
+			Nullable: !notNull[column.Name],
+		})
+	}
+	return entries, nil
+}
+
+// jsonField returns the type of the struct field at a dotted path of JSON names, e.g. "lab_analysis.url"
+func jsonField(t reflect.Type, path string) (reflect.Type, bool) {
+	name, rest, nested := strings.Cut(path, ".")
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag != name {
+			continue
+		}
+		if !nested {
+			return field.Type, true
+		}
+		elem := field.Type
+		if elem.Kind() == reflect.Slice || elem.Kind() == reflect.Pointer {
+			elem = elem.Elem() // fields of a list of records are of each record
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil, false
+		}
+		return jsonField(elem, rest)
+	}
+	return nil, false
+}
+
+// fieldType returns the data dictionary type of a field of type t
+func fieldType(t reflect.Type) string {
+	if t.Implements(reflect.TypeFor[measureType]()) {
+		return "measure"
+	}
+	if t.Implements(reflect.TypeFor[timeType]()) {
+		return "date"
+	}
+	switch t {
+	case reflect.TypeFor[FlexInt]():
+		return "int"
+	case reflect.TypeFor[FlexFloat]():
+		return "number"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
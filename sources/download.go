@@ -0,0 +1,72 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// DownloadFile downloads a URL into dir, naming the file by the hex SHA-256 of its contents
+// with the given extension, e.g. "<sha256>.pdf", so identical documents are stored once.
+// Downloads wait out any backoff of DefaultRateController, like Socrata fetches.
+// Returns the file's name within dir and its SHA-256.
+func DownloadFile(rawURL string, dir string, ext string) (string, string, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	rc := DefaultRateController
+	if rc != nil {
+		if err := rc.Wait(context.Background()); err != nil {
+			return "", "", err
+		}
+	}
+
+	resp, err := (&http.Client{Transport: DefaultTransport}).Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if rc != nil && resp.StatusCode == http.StatusTooManyRequests {
+			rc.Throttled(retryAfter(resp))
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body), RetryAfter: retryAfter(resp)}
+	}
+	if rc != nil {
+		rc.Succeeded()
+	}
+
+	// Download to a temporary file, hashing as we go, then rename it by its hash
+	tmpFile, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // fails harmlessly once renamed
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmpFile, h), resp.Body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	filename := sum + ext
+	if err := os.Rename(tmpFile.Name(), filepath.Join(dir, filename)); err != nil {
+		return "", "", fmt.Errorf("failed to save download: %w", err)
+	}
+	return filename, sum, nil
+}
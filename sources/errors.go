@@ -0,0 +1,102 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPError reports a request that received a non-200 HTTP response
+type HTTPError struct {
+	StatusCode int    // HTTP status code, e.g. 404
+	Status     string // HTTP status line, e.g. "404 Not Found"
+	Body       string // Response body, which often explains the error
+	// RetryAfter is how long the response's Retry-After header asked to wait before retrying; 0 if it had none
+	RetryAfter time.Duration
+}
+
+// Error returns the status and body of the response
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d %s %s", e.StatusCode, e.Status, e.Body)
+}
+
+// IsAuth returns true if the response rejected the request's credentials, e.g. an invalid app token
+func (e *HTTPError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// RetryError reports a request that still failed once its retries were exhausted
+type RetryError struct {
+	Attempts   int   // Number of attempts, including the first
+	StatusCode int   // HTTP status code of the last attempt; 0 if it received no response
+	Err        error // Error of the last attempt
+}
+
+// Error returns the number of attempts and the last one's status code and error
+func (e *RetryError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("failed after %d attempts, last with HTTP %d: %v", e.Attempts, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// CacheErrorKind classifies why a cache file cannot be used
+type CacheErrorKind int
+
+const (
+	CacheMissing    CacheErrorKind = iota // CacheMissing is a cache file that is not there, or of another CacheVersion, as if it were not.
+	CacheTooOld                           // CacheTooOld is a cache file older than the maxAge it was checked against.
+	CacheUnreadable                       // CacheUnreadable is a cache file that is there but cannot be read or decoded, e.g. corrupted.
+)
+
+// String returns the name of the kind, e.g. "unreadable"
+func (k CacheErrorKind) String() string {
+	switch k {
+	case CacheMissing:
+		return "missing"
+	case CacheTooOld:
+		return "too old"
+	case CacheUnreadable:
+		return "unreadable"
+	default:
+		return "CacheErrorKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// CacheError reports a cache file that is missing, too old, of another version, or unreadable
+type CacheError struct {
+	Kind   CacheErrorKind // Why the cache file cannot be used
+	Reason string         // e.g. "cache file not found"
+	Err    error          // Underlying error, if any
+}
+
+// Error returns the reason, followed by the underlying error if there is one
+func (e *CacheError) Error() string {
+	if e.Err != nil {
+		return e.Reason + ": " + e.Err.Error()
+	}
+	return e.Reason
+}
+
+// Unwrap returns the underlying error, if any
+func (e *CacheError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError reports data that failed an integrity or validation check
+type ValidationError struct {
+	Msg string
+}
+
+// Error returns the description of the failed check
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
@@ -3,10 +3,15 @@
 package sources
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // CSVExportable is an interface for types that can be exported to CSV
@@ -15,37 +20,421 @@ type CSVExportable interface {
 	CSVValue() string
 }
 
+// CSVRow builds a row of a CSV export, as CSVValue returns it, appending each of its fields
+// in turn with strconv and CSVString rather than formatting the row with fmt.
+// The zero value is an empty row, and Line ends it.
+type CSVRow struct {
+	buf    []byte
+	fields int // number of fields appended so far
+}
+
+// Grow reserves room for another n bytes of fields
+func (r *CSVRow) Grow(n int) {
+	r.buf = slices.Grow(r.buf, n)
+}
+
+// csvRowSize is the room a CSVRow first makes for its fields, unless Grow made more
+const csvRowSize = 64
+
+// next begins another field, separating it from the last
+func (r *CSVRow) next() {
+	if r.buf == nil {
+		r.buf = make([]byte, 0, csvRowSize)
+	}
+	if r.fields > 0 {
+		r.buf = append(r.buf, ',')
+	}
+	r.fields++
+}
+
+// String appends a text field, double-quoted and sanitized with CSVString
+func (r *CSVRow) String(s string) {
+	r.next()
+	r.buf = append(r.buf, '"')
+	r.buf = append(r.buf, CSVString(s)...)
+	r.buf = append(r.buf, '"')
+}
+
+// Raw appends a field as it is, unquoted, such as a number already formatted as text
+func (r *CSVRow) Raw(s string) {
+	r.next()
+	r.buf = append(r.buf, s...)
+}
+
+// Int appends an integer field
+func (r *CSVRow) Int(n int) {
+	r.next()
+	r.buf = strconv.AppendInt(r.buf, int64(n), 10)
+}
+
+// Float appends a number field with the given number of decimals
+func (r *CSVRow) Float(f float64, decimals int) {
+	r.next()
+	r.buf = strconv.AppendFloat(r.buf, f, 'f', decimals, 64)
+}
+
+// Bool appends a "true" or "false" field
+func (r *CSVRow) Bool(b bool) {
+	r.next()
+	r.buf = strconv.AppendBool(r.buf, b)
+}
+
+// Time appends a time field, double-quoted, formatted with layout
+func (r *CSVRow) Time(t time.Time, layout string) {
+	r.next()
+	r.buf = append(t.AppendFormat(append(r.buf, '"'), layout), '"')
+}
+
+// Append appends an unquoted field that field appends to dst, such as a measure's AppendCSV
+func (r *CSVRow) Append(field func(dst []byte) []byte) {
+	r.next()
+	r.buf = field(r.buf)
+}
+
+// Line returns the row, ending in "\n" as CSVValue does
+func (r *CSVRow) Line() string {
+	r.buf = append(r.buf, '\n')
+	return string(r.buf)
+}
+
 // SQLString escapes single quotes for use in SQL queries
 func SQLString(str string) string {
 	return strings.ReplaceAll(str, "'", "''")
 }
 
-// WriteJSON writes any slice of items to a JSON file with pretty formatting
-func WriteJSON[T any](filename string, items []T) error {
+// WriteJSON writes any slice of items to a JSON file with pretty formatting.
+// Returns the number of items that reached the file, and an error, if any.
+func WriteJSON[T any](filename string, items []T) (int, error) {
+	jw, err := NewJSONArrayWriter[T](filename)
+	if err != nil {
+		return 0, err
+	}
+	if err := jw.Write(items); err != nil {
+		jw.file.Close()
+		return jw.counter.rows, err
+	}
+	return jw.Close()
+}
+
+// JSONArrayWriter writes items to a JSON file as a pretty-formatted array, a batch at a time,
+// so a dataset can be written as it is fetched.  The file is the same as WriteJSON would write.
+type JSONArrayWriter[T any] struct {
+	file    io.WriteCloser
+	counter *rowCounter
+	w       *bufio.Writer
+	count   int   // number of items written so far
+	size    int64 // number of bytes written so far, not counting the end of the array
+}
+
+// NewJSONArrayWriter creates the file and begins the JSON array.
+// Items are added with Write, and Close must be called to end the array.
+func NewJSONArrayWriter[T any](filename string) (*JSONArrayWriter[T], error) {
 	file, err := os.Create(filename)
 	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
+		return nil, fmt.Errorf("failed to create JSON file: %w", err)
 	}
-	defer file.Close()
+	return newJSONArrayWriter[T](file), nil
+}
+
+// newJSONArrayWriter begins a JSON array written to file
+func newJSONArrayWriter[T any](file io.WriteCloser) *JSONArrayWriter[T] {
+	counter := &rowCounter{w: file}
+	jw := &JSONArrayWriter[T]{file: file, counter: counter, w: bufio.NewWriter(counter), size: 1}
+	counter.skip(1)
+	jw.w.WriteString("[")
+	return jw
+}
+
+// Write appends items to the JSON array
+func (jw *JSONArrayWriter[T]) Write(items []T) error {
+	// Items are encoded one at a time so they can be counted,
+	// formatted exactly as a json.Encoder indenting the whole slice would
+	for _, item := range items {
+		itemBytes, err := encodeJSONItem(item, jw.count)
+		if err != nil {
+			return err
+		}
+		if err := jw.writeEncoded(itemBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJSONItem encodes the i'th item as it is formatted within a JSONArrayWriter's array
+func encodeJSONItem[T any](item T, i int) ([]byte, error) {
+	itemBytes, err := json.MarshalIndent(item, "  ", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON item %d: %w", i, err)
+	}
+	return itemBytes, nil
+}
+
+// sizeWith returns the size the file would have, once closed, with another encoded item written
+func (jw *JSONArrayWriter[T]) sizeWith(itemBytes []byte) int64 {
+	return jw.size + min(int64(jw.count), 1) + 3 + int64(len(itemBytes)) + 3
+}
+
+// writeEncoded appends an item encoded with encodeJSONItem to the JSON array
+func (jw *JSONArrayWriter[T]) writeEncoded(itemBytes []byte) error {
+	if jw.count > 0 {
+		jw.counter.skip(1)
+		jw.w.WriteString(",")
+		jw.size++
+	}
+	jw.counter.row(3 + len(itemBytes))
+	jw.w.WriteString("\n  ")
+	if _, err := jw.w.Write(itemBytes); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	jw.size += 3 + int64(len(itemBytes))
+	jw.count++
+	return nil
+}
+
+// Close ends the JSON array and closes the file.
+// Returns the number of items that reached the file, and an error, if any.
+func (jw *JSONArrayWriter[T]) Close() (int, error) {
+	defer jw.file.Close()
+
+	if jw.count > 0 {
+		jw.w.WriteString("\n")
+	}
+	jw.w.WriteString("]\n")
+	if err := jw.w.Flush(); err != nil {
+		return jw.counter.rows, fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	return jw.counter.rows, jw.file.Close()
+}
+
+// rowCounter counts the rows of an export that reach the writer beneath it, rather than those
+// handed to the buffer in front of it, so rows lost to a failed or short write are not counted.
+// Each row's length is declared with row, and anything between rows with skip, before it is written.
+type rowCounter struct {
+	w       io.Writer
+	written int64   // bytes accepted by w
+	end     int64   // offset of the end of the bytes declared so far
+	ends    []int64 // offsets of the ends of declared rows not yet wholly accepted by w
+	rows    int     // number of rows wholly accepted by w
+}
+
+// skip declares n bytes about to be written that are not part of a row
+func (c *rowCounter) skip(n int) {
+	c.end += int64(n)
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(items)
+// row declares a row of n bytes about to be written
+func (c *rowCounter) row(n int) {
+	c.end += int64(n)
+	c.ends = append(c.ends, c.end)
 }
 
-// WriteCSV writes any slice of CSVExportable items to a CSV file
-func WriteCSV[T CSVExportable](filename string, items []T) error {
+// Write writes p to the writer beneath, counting each row that is now wholly written
+func (c *rowCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	for len(c.ends) > 0 && c.ends[0] <= c.written {
+		c.ends = c.ends[1:]
+		c.rows++
+	}
+	return n, err
+}
+
+// CSVOptions control how WriteCSVWith formats a CSV file
+type CSVOptions struct {
+	CRLF bool // End lines with "\r\n" rather than "\n", within quoted fields too, as csv.Writer's UseCRLF does
+	BOM  bool // Begin the file with a UTF-8 byte order mark, so Excel reads it as UTF-8
+
+	// Columns only writes these columns of each row, in this order; empty for every column.
+	// Each must be one of the header's columns.
+	Columns []string
+}
+
+// UTF8BOM is the UTF-8 byte order mark that CSVOptions.BOM writes before the header
+const UTF8BOM = "\xEF\xBB\xBF"
+
+// WriteCSV writes any slice of CSVExportable items to a CSV file, with default options.
+// Returns the number of rows written, not counting the header, and an error, if any.
+func WriteCSV[T CSVExportable](filename string, items []T) (int, error) {
+	return WriteCSVWith(filename, items, CSVOptions{})
+}
+
+// WriteCSVWith writes any slice of CSVExportable items to a CSV file, formatted per opts.
+// Returns the number of rows that reached the file, not counting the header, and an error, if any.
+func WriteCSVWith[T CSVExportable](filename string, items []T, opts CSVOptions) (int, error) {
 	file, err := os.Create(filename)
 	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
+		return 0, fmt.Errorf("failed to create CSV file: %w", err)
 	}
 	defer file.Close()
 
+	rows, err := writeCSV(file, items, opts)
+	if err != nil {
+		return rows, err
+	}
+	return rows, file.Close()
+}
+
+// writeCSV writes items to dst as WriteCSVWith writes them to its file
+func writeCSV[T CSVExportable](dst io.Writer, items []T, opts CSVOptions) (int, error) {
+	counter := &rowCounter{w: dst}
+	w := bufio.NewWriter(counter)
+	var keep []int // indexes of the columns written, if not all of them
 	if len(items) > 0 {
-		file.WriteString(items[0].CSVHeaders())
+		header := items[0].CSVHeaders()
+		if len(opts.Columns) > 0 {
+			var err error
+			if keep, err = csvColumnIndexes(header, opts.Columns); err != nil {
+				return 0, err
+			}
+			header = projectCSVLine(header, keep)
+		}
+		if opts.BOM {
+			counter.skip(len(UTF8BOM))
+			w.WriteString(UTF8BOM)
+		}
+		header = csvLine(header, opts)
+		counter.skip(len(header))
+		w.WriteString(header)
 	}
 	for _, item := range items {
-		file.WriteString(item.CSVValue())
+		line := item.CSVValue()
+		if keep != nil {
+			line = projectCSVLine(line, keep)
+		}
+		line = csvLine(line, opts)
+		counter.row(len(line))
+		if _, err := w.WriteString(line); err != nil {
+			return counter.rows, fmt.Errorf("failed to write CSV file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return counter.rows, fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	return counter.rows, nil
+}
+
+// csvLine applies the line ending of opts to a CSV row ending in "\n".
+// With CRLF, every newline becomes "\r\n", including those within quoted fields, as
+// encoding/csv.Writer with UseCRLF writes them, so the file never mixes line endings.
+func csvLine(line string, opts CSVOptions) string {
+	if opts.CRLF {
+		return strings.ReplaceAll(strings.ReplaceAll(line, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return line
+}
+
+// csvColumnIndexes returns the indexes in the header of each of the columns.
+// Returns an error if a column is not in the header.
+func csvColumnIndexes(header string, columns []string) ([]int, error) {
+	names := splitCSVLine(header)
+	for i, name := range names {
+		names[i] = strings.Trim(name, `"`)
 	}
+	keep := make([]int, len(columns))
+	for i, column := range columns {
+		if keep[i] = slices.Index(names, column); keep[i] < 0 {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+	return keep, nil
+}
+
+// projectCSVLine returns the fields of a CSV row ending in "\n" at the indexes of keep, in order.
+// Fields are kept as they were written, so quoted and unquoted values stay as they are.
+func projectCSVLine(line string, keep []int) string {
+	fields := splitCSVLine(line)
+	projected := make([]string, len(keep))
+	for i, k := range keep {
+		if k < len(fields) {
+			projected[i] = fields[k]
+		}
+	}
+	return strings.Join(projected, ",") + "\n"
+}
+
+// splitCSVLine splits a CSV row ending in "\n" into its fields as they were written, including
+// any quotes.  Commas and newlines within quoted fields do not split them.
+func splitCSVLine(line string) []string {
+	line = strings.TrimSuffix(line, "\n")
+	var fields []string
+	start, quoted := 0, false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted // an escaped "" toggles twice
+		case ',':
+			if !quoted {
+				fields = append(fields, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, line[start:])
+}
+
+// CombinedJSONWriter streams several datasets into a single JSON document,
+// an object keyed by dataset name.  Each dataset's items are encoded one at a
+// time, so no dataset is held in memory a second time as encoded JSON.
+type CombinedJSONWriter struct {
+	file  *os.File
+	w     *bufio.Writer
+	count int // number of datasets written so far
+}
+
+// NewCombinedJSONWriter creates the file and begins the combined JSON object.
+// Datasets are added with WriteCombinedDataset, and Close must be called to end the object.
+func NewCombinedJSONWriter(filename string) (*CombinedJSONWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create combined JSON file: %w", err)
+	}
+	c := &CombinedJSONWriter{file: file, w: bufio.NewWriter(file)}
+	c.w.WriteString("{")
+	return c, nil
+}
+
+// WriteCombinedDataset adds the named dataset to the combined JSON object, as an array of items
+func WriteCombinedDataset[T any](c *CombinedJSONWriter, name string, items []T) error {
+	key, err := json.Marshal(name)
+	if err != nil {
+		return fmt.Errorf("failed to encode dataset name: %w", err)
+	}
+	if c.count > 0 {
+		c.w.WriteString(",")
+	}
+	c.count++
+	c.w.WriteString("\n  ")
+	c.w.Write(key)
+	c.w.WriteString(": [")
+	for i, item := range items {
+		itemBytes, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s item %d: %w", name, i, err)
+		}
+		if i > 0 {
+			c.w.WriteString(",")
+		}
+		c.w.WriteString("\n    ")
+		c.w.Write(itemBytes)
+	}
+	if len(items) > 0 {
+		c.w.WriteString("\n  ")
+	}
+	c.w.WriteString("]")
 	return nil
 }
+
+// Close ends the combined JSON object and closes the file
+func (c *CombinedJSONWriter) Close() error {
+	defer c.file.Close()
+
+	if c.count > 0 {
+		c.w.WriteString("\n")
+	}
+	c.w.WriteString("}\n")
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write combined JSON file: %w", err)
+	}
+	return c.file.Close()
+}
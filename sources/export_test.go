@@ -0,0 +1,353 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchCSVRecord is a record with a mix of text and numeric columns, for benchmarking CSV export
+type benchCSVRecord struct {
+	Name   string
+	Status string
+	Count  int
+	Amount float64
+}
+
+// CSVHeaders returns the CSV headers for the benchCSVRecord struct
+func (r benchCSVRecord) CSVHeaders() string {
+	return `"name","status","count","amount"
+`
+}
+
+// CSVValue returns the CSV value for the benchCSVRecord struct
+func (r benchCSVRecord) CSVValue() string {
+	var row CSVRow
+	row.String(r.Name)
+	row.String(r.Status)
+	row.Int(r.Count)
+	row.Float(r.Amount, 2)
+	return row.Line()
+}
+
+// sprintfCSVRecord is a benchCSVRecord whose CSVValue formats the row with fmt.Sprintf,
+// as CSVValue did before CSVRow, to compare with it
+type sprintfCSVRecord struct {
+	benchCSVRecord
+}
+
+// CSVValue returns the CSV value for the sprintfCSVRecord struct
+func (r sprintfCSVRecord) CSVValue() string {
+	return fmt.Sprintf(`"%s","%s",%d,%s
+`, CSVString(r.Name), CSVString(r.Status), r.Count, strconv.FormatFloat(r.Amount, 'f', 2, 64))
+}
+
+func TestCSVRowMatchesSprintf(t *testing.T) {
+	for _, r := range []benchCSVRecord{
+		{},
+		{Name: "Record, 1", Status: "ACTIVE", Count: 1, Amount: 1.0 / 7},
+		{Name: `=the "best"`, Status: "line\nbreak", Count: -2, Amount: -0.5},
+	} {
+		if got, want := r.CSVValue(), (sprintfCSVRecord{r}).CSVValue(); got != want {
+			t.Errorf("CSVRow row = %q, want %q as with fmt.Sprintf", got, want)
+		}
+	}
+}
+
+// TestWriteCSVLineEndings checks that LF and CRLF exports both parse with encoding/csv to the same
+// fields, including newlines within quoted fields, and that neither mixes its line endings
+func TestWriteCSVLineEndings(t *testing.T) {
+	records := []benchCSVRecord{
+		{Name: "plain", Status: "ACTIVE", Count: 1},
+		{Name: "line\nbreak", Status: "two\nline\nbreaks", Count: 2},
+		{Name: "crlf\r\nbreak", Status: "Record, 3", Count: 3},
+	}
+	var parsed [][][]string
+	for _, crlf := range []bool{false, true} {
+		var buf bytes.Buffer
+		if _, err := writeCSV(&buf, records, CSVOptions{CRLF: crlf}); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if crlf && strings.Count(out, "\n") != strings.Count(out, "\r\n") {
+			t.Errorf("CRLF export has LF line endings: %q", out)
+		}
+		if !crlf && strings.Count(out, "\r\n") != 1 { // only the third name's own
+			t.Errorf("LF export has CRLF line endings: %q", out)
+		}
+		fields, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		if err != nil {
+			t.Fatalf("CRLF = %v export does not parse: %v", crlf, err)
+		}
+		if len(fields) != len(records)+1 {
+			t.Fatalf("CRLF = %v export parsed to %d rows, want %d", crlf, len(fields), len(records)+1)
+		}
+		for i, r := range records {
+			if want := strings.ReplaceAll(r.Name, "\r\n", "\n"); fields[i+1][0] != want {
+				t.Errorf("CRLF = %v row %d name parsed as %q, want %q", crlf, i, fields[i+1][0], want)
+			}
+		}
+		parsed = append(parsed, fields)
+	}
+	if !slices.EqualFunc(parsed[0], parsed[1], slices.Equal) {
+		t.Errorf("LF export parsed to %q, CRLF to %q, want the same", parsed[0], parsed[1])
+	}
+}
+
+// benchCSVRecords returns n benchCSVRecords
+func benchCSVRecords(n int) []benchCSVRecord {
+	records := make([]benchCSVRecord, n)
+	for i := range records {
+		records[i] = benchCSVRecord{Name: "Record, " + strconv.Itoa(i), Status: "ACTIVE", Count: i, Amount: float64(i) / 7}
+	}
+	return records
+}
+
+// TestExportCountsRowsReachingFile checks that the CSV and JSON writers count only the rows
+// that reached a writer that short-writes, and that the manifest reports the rows lost
+func TestExportCountsRowsReachingFile(t *testing.T) {
+	records := benchCSVRecords(1000)
+	const reached = 10
+
+	// The writer takes the rows that reached it, and the first bytes of the next
+	csvLimit := len(records[0].CSVHeaders()) + 3
+	for _, r := range records[:reached] {
+		csvLimit += len(r.CSVValue())
+	}
+	jsonLimit := 1 + 3
+	for i, r := range records[:reached] {
+		itemBytes, err := json.MarshalIndent(r, "  ", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonLimit += min(i, 1) + 3 + len(itemBytes)
+	}
+
+	tests := []struct {
+		name  string
+		write func(w io.WriteCloser) (int, error)
+	}{
+		{name: "CSV", write: func(w io.WriteCloser) (int, error) {
+			return writeCSV(w, records, CSVOptions{})
+		}},
+		{name: "JSON", write: func(w io.WriteCloser) (int, error) {
+			jw := newJSONArrayWriter[benchCSVRecord](w)
+			if err := jw.Write(records); err != nil {
+				return jw.counter.rows, err
+			}
+			return jw.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := csvLimit
+			if tt.name == "JSON" {
+				limit = jsonLimit
+			}
+			rows, err := tt.write(&failingWriter{limit: limit})
+			if !errors.Is(err, io.ErrShortWrite) {
+				t.Errorf("write error = %v, want %v", err, io.ErrShortWrite)
+			}
+			if rows != reached {
+				t.Errorf("rows = %d after a short write, want the %d that reached the writer", rows, reached)
+			}
+			var validationErr *ValidationError
+			if err := (&Manifest{}).RecordFile("bench", "bench", len(records), rows); !errors.As(err, &validationErr) {
+				t.Errorf("RecordFile() = %v, want a ValidationError of the rows lost", err)
+			}
+
+			if rows, err := tt.write(&failingWriter{limit: 1 << 30}); err != nil || rows != len(records) {
+				t.Errorf("rows = %d, %v, want %d", rows, err, len(records))
+			}
+		})
+	}
+}
+
+// BenchmarkWriteCSV writes 50k rows with CSVRow, and with fmt.Sprintf as rows were before it
+func BenchmarkWriteCSV(b *testing.B) {
+	records := benchCSVRecords(50000)
+	sprintfRecords := make([]sprintfCSVRecord, len(records))
+	for i, r := range records {
+		sprintfRecords[i] = sprintfCSVRecord{r}
+	}
+	filename := filepath.Join(b.TempDir(), "bench.csv")
+	b.Run("CSVRow", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := WriteCSV(filename, records); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Sprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := WriteCSV(filename, sprintfRecords); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRenderName(t *testing.T) {
+	brands := NameContextFor("us_ct_brands.csv", "2025-06-30")
+	tests := []struct {
+		name    string
+		tmpl    string
+		ctx     NameContext
+		want    string // the rendered name, or "" if RenderName fails
+		wantErr bool
+	}{
+		{name: "default", tmpl: DefaultNameTemplate, ctx: brands, want: "us_ct_brands.csv"},
+		{name: "default compound dataset", tmpl: DefaultNameTemplate, ctx: NameContextFor("us_ct_weekly_sales.json", "2025-06-30"), want: "us_ct_weekly_sales.json"},
+		{name: "default sidecar", tmpl: DefaultNameTemplate, ctx: NameContextFor("us_ct_brands.stats.json", "2025-06-30"), want: "us_ct_brands.stats.json"},
+		{name: "custom scheme", tmpl: `{{.State}}-{{.Dataset}}-{{slice .Date 0 4}}.{{.Ext}}`, ctx: brands, want: "ct-brands-2025.csv"},
+		{name: "date", tmpl: "{{.Dataset}}_{{.Date}}.{{.Ext}}", ctx: brands, want: "brands_2025-06-30.csv"},
+		{name: "unparseable", tmpl: "{{.Dataset", ctx: brands, wantErr: true},
+		{name: "unknown variable", tmpl: "{{.Region}}.{{.Ext}}", ctx: brands, wantErr: true},
+		{name: "empty", tmpl: "{{if false}}x{{end}}", ctx: brands, wantErr: true},
+		{name: "path separator", tmpl: "{{.Date}}/{{.Dataset}}.{{.Ext}}", ctx: brands, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderName(tt.tmpl, tt.ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("RenderName(%q) = %q, want an error", tt.tmpl, got)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("RenderName(%q) = %q, %v, want %q", tt.tmpl, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNameTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{tmpl: DefaultNameTemplate},
+		{tmpl: "{{.State}}-{{.Dataset}}-{{slice .Date 0 4}}.{{.Ext}}"},
+		{tmpl: "{{.Dataset", wantErr: true},
+		{tmpl: "{{.Dataset}}.json", wantErr: true},
+		{tmpl: "{{.State}}.{{.Ext}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := ValidateNameTemplate(tt.tmpl); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateNameTemplate(%q) = %v, want error %v", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestChunkedJSONWriterReproducesDataset(t *testing.T) {
+	records := testRecords(10)
+	recordBytes := int64(len(`{"id":"r0","week":"2026-01-01"}`))
+	tests := []struct {
+		name       string
+		limits     ChunkLimits
+		wantChunks int
+	}{
+		{name: "no limits", wantChunks: 1},
+		{name: "records", limits: ChunkLimits{Records: 3}, wantChunks: 4},
+		{name: "record a file", limits: ChunkLimits{Records: 1}, wantChunks: 10},
+		{name: "records over the dataset", limits: ChunkLimits{Records: 100}, wantChunks: 1},
+		{name: "bytes", limits: ChunkLimits{Bytes: 4 * recordBytes}},
+		{name: "bytes under a record", limits: ChunkLimits{Bytes: 1}, wantChunks: 10},
+		{name: "records and bytes", limits: ChunkLimits{Records: 2, Bytes: 4 * recordBytes}, wantChunks: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "us_ct_brands.json")
+			cw := NewChunkedJSONWriter[testRecord](filename, tt.limits)
+			// Batches that do not line up with the chunks
+			for _, batch := range [][]testRecord{records[:3], nil, records[3:], {}} {
+				if err := cw.Write(batch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			chunks, err := cw.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantChunks > 0 && len(chunks) != tt.wantChunks {
+				t.Errorf("wrote %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+
+			var concatenated []testRecord
+			for i, chunk := range chunks {
+				want := filename
+				if !tt.limits.IsZero() {
+					want = ChunkFilename(filename, i+1)
+				}
+				if chunk.Filename != want {
+					t.Errorf("chunk %d is %s, want %s", i, chunk.Filename, want)
+				}
+				data, err := os.ReadFile(chunk.Filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Each chunk is a whole JSON array of whole records
+				var chunkRecords []testRecord
+				if err := json.Unmarshal(data, &chunkRecords); err != nil {
+					t.Fatalf("chunk %s is not a JSON array of records: %v", chunk.Filename, err)
+				}
+				if len(chunkRecords) == 0 {
+					t.Errorf("chunk %s is empty", chunk.Filename)
+				}
+				if chunk.Records != len(chunkRecords) || chunk.Rows != len(chunkRecords) {
+					t.Errorf("chunk %s records %d and rows %d, want the %d it holds", chunk.Filename, chunk.Records, chunk.Rows, len(chunkRecords))
+				}
+				if tt.limits.Records > 0 && len(chunkRecords) > tt.limits.Records {
+					t.Errorf("chunk %s holds %d records, over the limit of %d", chunk.Filename, len(chunkRecords), tt.limits.Records)
+				}
+				if tt.limits.Bytes > 0 && int64(len(data)) > tt.limits.Bytes && len(chunkRecords) > 1 {
+					t.Errorf("chunk %s is %d bytes, over the limit of %d", chunk.Filename, len(data), tt.limits.Bytes)
+				}
+				concatenated = append(concatenated, chunkRecords...)
+			}
+			if !slices.Equal(concatenated, records) {
+				t.Errorf("concatenated chunks = %v, want %v", concatenated, records)
+			}
+		})
+	}
+}
+
+func TestChunkedJSONWriterMatchesWriteJSON(t *testing.T) {
+	dir := t.TempDir()
+	records := testRecords(5)
+	want := filepath.Join(dir, "want.json")
+	if _, err := WriteJSON(want, records); err != nil {
+		t.Fatal(err)
+	}
+	got := filepath.Join(dir, "got.json")
+	cw := NewChunkedJSONWriter[testRecord](got, ChunkLimits{})
+	if err := cw.Write(records); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotData, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotData, wantData) {
+		t.Errorf("unlimited ChunkedJSONWriter wrote %q, want WriteJSON's %q", gotData, wantData)
+	}
+}
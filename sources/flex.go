@@ -0,0 +1,111 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FlexInt is an integer field that Socrata emits as a JSON string in some rows
+// and as a JSON number in others.  It unmarshals from either, or null, and keeps
+// the raw text so nothing is lost if it does not parse; it marshals as a string.
+type FlexInt string
+
+// FlexFloat is a decimal field that Socrata emits as a JSON string in some rows
+// and as a JSON number in others.  It unmarshals from either, or null, and keeps
+// the raw text so nothing is lost if it does not parse; it marshals as a string.
+type FlexFloat string
+
+// unmarshalFlex returns the raw text of a JSON string, number, or null (as "")
+func unmarshalFlex(b []byte) (string, error) {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		return "", nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(str), nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return "", fmt.Errorf("expected a string or number: %w", err)
+	}
+	return num.String(), nil
+}
+
+// UnmarshalJSON accepts a JSON string, number, or null
+func (f *FlexInt) UnmarshalJSON(b []byte) error {
+	str, err := unmarshalFlex(b)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal integer: %w", err)
+	}
+	*f = FlexInt(str)
+	return nil
+}
+
+// Int returns the value as an integer, accepting integral decimals such as "12.0".
+// Returns 0 with no error if it is empty, or an error if it is not an integer.
+func (f FlexInt) Int() (int64, error) {
+	if f == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(string(f), 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if val, ferr := strconv.ParseFloat(string(f), 64); ferr == nil && val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+		return int64(val), nil
+	}
+	return 0, err
+}
+
+// AsSQL converts the value to "NULL" if it is empty or not an integer, or else "<integer>".
+func (f FlexInt) AsSQL() string {
+	if f == "" {
+		return "NULL"
+	}
+	n, err := f.Int()
+	if err != nil {
+		return "NULL"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// UnmarshalJSON accepts a JSON string, number, or null
+func (f *FlexFloat) UnmarshalJSON(b []byte) error {
+	str, err := unmarshalFlex(b)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal number: %w", err)
+	}
+	*f = FlexFloat(str)
+	return nil
+}
+
+// Float returns the value as a float64.
+// Returns 0 with no error if it is empty, or an error if it is not a number.
+func (f FlexFloat) Float() (float64, error) {
+	if f == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(string(f), 64)
+}
+
+// AsSQL converts the value to "NULL" if it is empty or not a number, or else "<number>".
+func (f FlexFloat) AsSQL() string {
+	if f == "" {
+		return "NULL"
+	}
+	val, err := f.Float()
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+		return "NULL"
+	}
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
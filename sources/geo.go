@@ -0,0 +1,133 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GeoPoint is a Socrata location or point field.  It unmarshals from any of the shapes
+// Socrata portals emit, or null, and is Valid only if it had coordinates:
+//
+//   - a GeoJSON point, {"type":"Point","coordinates":[lon,lat]}
+//   - a legacy location, {"latitude":"41.76","longitude":"-72.67","human_address":"{...}"},
+//     whose human address alone, without coordinates, is not a point
+//   - a WKT string, "POINT (-72.67 41.76)"
+//
+// It marshals as a GeoJSON point, or null if it is not Valid.
+type GeoPoint struct {
+	Lat   float64 // Latitude, in degrees
+	Lon   float64 // Longitude, in degrees
+	Valid bool    // True if the point had coordinates
+}
+
+// geoPointJSON holds the fields of every object shape of a GeoPoint
+type geoPointJSON struct {
+	Type        string    `json:"type,omitempty"`
+	Coordinates []float64 `json:"coordinates,omitempty"`
+	Latitude    FlexFloat `json:"latitude,omitempty"`
+	Longitude   FlexFloat `json:"longitude,omitempty"`
+}
+
+// UnmarshalJSON accepts a GeoJSON point, a legacy location object, a WKT point string, or null
+func (p *GeoPoint) UnmarshalJSON(b []byte) error {
+	*p = GeoPoint{}
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return fmt.Errorf("failed to unmarshal point: %w", err)
+		}
+		point, err := ParseWKTPoint(str)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal point: %w", err)
+		}
+		*p = point
+		return nil
+	}
+
+	var obj geoPointJSON
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("failed to unmarshal point: %w", err)
+	}
+	switch {
+	case obj.Coordinates != nil:
+		if len(obj.Coordinates) < 2 {
+			return fmt.Errorf("failed to unmarshal point: %d coordinates", len(obj.Coordinates))
+		}
+		*p = GeoPoint{Lon: obj.Coordinates[0], Lat: obj.Coordinates[1], Valid: true}
+	case obj.Latitude != "" && obj.Longitude != "":
+		lat, err := obj.Latitude.Float()
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal point latitude: %w", err)
+		}
+		lon, err := obj.Longitude.Float()
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal point longitude: %w", err)
+		}
+		*p = GeoPoint{Lat: lat, Lon: lon, Valid: true}
+	}
+	return nil
+}
+
+// MarshalJSON returns the point as a GeoJSON point, or null if it is not Valid
+func (p GeoPoint) MarshalJSON() ([]byte, error) {
+	if !p.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(geoPointJSON{Type: "Point", Coordinates: []float64{p.Lon, p.Lat}})
+}
+
+// ParseWKTPoint parses a Well-Known Text point, e.g. "POINT (-72.67 41.76)", whose
+// coordinates are longitude then latitude.  An empty string or "POINT EMPTY" is not Valid.
+func ParseWKTPoint(s string) (GeoPoint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return GeoPoint{}, nil
+	}
+	rest, ok := strings.CutPrefix(strings.ToUpper(s), "POINT")
+	if !ok {
+		return GeoPoint{}, fmt.Errorf("unrecognized point %q", s)
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "EMPTY" {
+		return GeoPoint{}, nil
+	}
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return GeoPoint{}, fmt.Errorf("unrecognized point %q", s)
+	}
+	coords := strings.Fields(rest[1 : len(rest)-1])
+	if len(coords) < 2 {
+		return GeoPoint{}, fmt.Errorf("unrecognized point %q", s)
+	}
+	lon, err := strconv.ParseFloat(coords[0], 64)
+	if err != nil {
+		return GeoPoint{}, fmt.Errorf("invalid longitude in point %q: %w", s, err)
+	}
+	lat, err := strconv.ParseFloat(coords[1], 64)
+	if err != nil {
+		return GeoPoint{}, fmt.Errorf("invalid latitude in point %q: %w", s, err)
+	}
+	return GeoPoint{Lat: lat, Lon: lon, Valid: true}, nil
+}
+
+// GeoPointCSVHeaders returns the CSV headers of a point column exported with AsCSV,
+// the column name suffixed with "_lat" and "_lon", e.g. `"location_lat","location_lon"`
+func GeoPointCSVHeaders(column string) string {
+	return fmt.Sprintf(`"%s_lat","%s_lon"`, column, column)
+}
+
+// AsCSV returns the point as two CSV values, "<lat>,<lon>", or "," if it is not Valid
+func (p GeoPoint) AsCSV() string {
+	if !p.Valid {
+		return ","
+	}
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lon, 'f', -1, 64)
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SheetsScope is the OAuth scope required to rewrite spreadsheet tabs
+	SheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+	// SheetsBatchRows is the number of rows sent per Sheets API values update,
+	// keeping each request well under the API's payload limits
+	SheetsBatchRows = 5000
+
+	sheetsAPIBase = "https://sheets.googleapis.com/v4/spreadsheets/"
+)
+
+// serviceAccount holds the fields of a Google service account key file used for auth
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// SheetsClient writes datasets to tabs of a Google Sheets spreadsheet
+// through the Sheets v4 REST API, authenticating as a service account.
+type SheetsClient struct {
+	SpreadsheetID string
+
+	client  *http.Client
+	account serviceAccount
+	key     *rsa.PrivateKey
+	token   string
+	expiry  time.Time
+}
+
+// NewSheetsClient creates a SheetsClient for the given spreadsheet, using the
+// service account key file credsFile.  If credsFile is empty, the file named by
+// GOOGLE_APPLICATION_CREDENTIALS is used.  Returns nil with any error.
+func NewSheetsClient(spreadsheetID string, credsFile string) (*SheetsClient, error) {
+	if credsFile == "" {
+		credsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credsFile == "" {
+		return nil, fmt.Errorf("no Google credentials file given and GOOGLE_APPLICATION_CREDENTIALS is unset")
+	}
+
+	credsBytes, err := os.ReadFile(credsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Google credentials: %w", err)
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(credsBytes, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse Google credentials: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("Google credentials are not a service account key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode service account private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account private key is not RSA")
+	}
+
+	return &SheetsClient{
+		SpreadsheetID: spreadsheetID,
+		client:        &http.Client{Timeout: 60 * time.Second},
+		account:       account,
+		key:           key,
+	}, nil
+}
+
+// WriteGoogleSheet clears the named tab of the spreadsheet, creating it if needed,
+// then writes the items' CSV headers and rows to it in chunks of SheetsBatchRows.
+// Cells that hold plain numbers, such as measures, are written as numbers.
+func WriteGoogleSheet[T CSVExportable](c *SheetsClient, tab string, items []T) error {
+	if err := c.ensureTab(tab); err != nil {
+		return err
+	}
+	if err := c.clearTab(tab); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	headers, err := csvRecord(items[0].CSVHeaders())
+	if err != nil {
+		return fmt.Errorf("failed to parse CSV headers: %w", err)
+	}
+	rows := [][]any{sheetRow(headers)}
+	startRow := 1
+	for i, item := range items {
+		record, err := csvRecord(item.CSVValue())
+		if err != nil {
+			return fmt.Errorf("failed to parse CSV row %d: %w", i, err)
+		}
+		rows = append(rows, sheetRow(record))
+		if len(rows) == SheetsBatchRows {
+			if err := c.updateRows(tab, startRow, rows); err != nil {
+				return err
+			}
+			startRow += len(rows)
+			rows = rows[:0]
+		}
+	}
+	if len(rows) > 0 {
+		return c.updateRows(tab, startRow, rows)
+	}
+	return nil
+}
+
+// csvRecord parses a single CSV line into its fields
+func csvRecord(line string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.LazyQuotes = true
+	return r.Read()
+}
+
+// sheetRow converts CSV fields to Sheets cell values, keeping numbers numeric.  Values are written
+// RAW, so are never evaluated as formulas, and CSVString's formula guard is removed.
+func sheetRow(fields []string) []any {
+	row := make([]any, len(fields))
+	for i, field := range fields {
+		row[i] = sheetCell(UnguardCSVString(field))
+	}
+	return row
+}
+
+// sheetCell returns the field as a float64 if it is a plain decimal number, otherwise as a string.
+// Numbers with leading zeros (e.g. "00123" codes) are kept as strings to preserve them.
+func sheetCell(field string) any {
+	if field == "" {
+		return field
+	}
+	digits := strings.TrimPrefix(field, "-")
+	if digits == "" || digits[0] < '0' || digits[0] > '9' || digits[len(digits)-1] < '0' || digits[len(digits)-1] > '9' {
+		return field
+	}
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return field
+	}
+	if val, err := strconv.ParseFloat(field, 64); err == nil {
+		return val
+	}
+	return field
+}
+
+// sheetRange returns the A1 notation for a tab, quoted to allow any tab name
+func sheetRange(tab string, cell string) string {
+	quoted := "'" + strings.ReplaceAll(tab, "'", "''") + "'"
+	if cell == "" {
+		return quoted
+	}
+	return quoted + "!" + cell
+}
+
+// ensureTab adds a tab with the given title if the spreadsheet does not have one
+func (c *SheetsClient) ensureTab(tab string) error {
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := c.call("GET", c.SpreadsheetID+"?fields=sheets.properties.title", nil, &meta); err != nil {
+		return fmt.Errorf("failed to read spreadsheet: %w", err)
+	}
+	for _, sheet := range meta.Sheets {
+		if sheet.Properties.Title == tab {
+			return nil
+		}
+	}
+
+	addSheet := map[string]any{
+		"requests": []any{
+			map[string]any{"addSheet": map[string]any{"properties": map[string]any{"title": tab}}},
+		},
+	}
+	if err := c.call("POST", c.SpreadsheetID+":batchUpdate", addSheet, nil); err != nil {
+		return fmt.Errorf("failed to add tab %q: %w", tab, err)
+	}
+	return nil
+}
+
+// clearTab removes all values from the tab
+func (c *SheetsClient) clearTab(tab string) error {
+	path := c.SpreadsheetID + "/values/" + url.PathEscape(sheetRange(tab, "")) + ":clear"
+	if err := c.call("POST", path, map[string]any{}, nil); err != nil {
+		return fmt.Errorf("failed to clear tab %q: %w", tab, err)
+	}
+	return nil
+}
+
+// updateRows writes rows to the tab starting at the given 1-based row
+func (c *SheetsClient) updateRows(tab string, startRow int, rows [][]any) error {
+	rng := sheetRange(tab, fmt.Sprintf("A%d", startRow))
+	path := c.SpreadsheetID + "/values/" + url.PathEscape(rng) + "?valueInputOption=RAW"
+	body := map[string]any{
+		"range":          rng,
+		"majorDimension": "ROWS",
+		"values":         rows,
+	}
+	if err := c.call("PUT", path, body, nil); err != nil {
+		return fmt.Errorf("failed to write rows %d-%d of tab %q: %w", startRow, startRow+len(rows)-1, tab, err)
+	}
+	return nil
+}
+
+// call makes an authenticated Sheets API request, JSON-encoding body and decoding into out if non-nil
+func (c *SheetsClient) call(method string, path string, body any, out any) error {
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequest(method, sheetsAPIBase+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d %s %s", resp.StatusCode, resp.Status, string(respBody))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+	}
+	return nil
+}
+
+// accessToken returns a cached OAuth access token, exchanging a freshly signed
+// service account JWT for a new one when it is missing or about to expire.
+func (c *SheetsClient) accessToken() (string, error) {
+	now := time.Now()
+	if c.token != "" && now.Before(c.expiry.Add(-time.Minute)) {
+		return c.token, nil
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   c.account.ClientEmail,
+		"scope": SheetsScope,
+		"aud":   c.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	resp, err := c.client.PostForm(c.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request HTTP %d %s %s", resp.StatusCode, resp.Status, string(respBody))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	c.token = tokenResp.AccessToken
+	c.expiry = now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return c.token, nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTransport is the transport of the HTTP clients of Socrata fetches and downloads.
+// It is nil by default, for http.DefaultTransport, and may be wrapped, e.g. by an HTTPCache.
+var DefaultTransport http.RoundTripper
+
+// HTTPCache is an http.RoundTripper that caches successful GET responses on disk, one file
+// per URL, for development runs that repeat the same requests.  It caches below the dataset
+// caches, so each page of a paginated fetch is cached on its own.
+//
+// Cache headers are respected: responses with Cache-Control no-store are not cached, and
+// a cached response is fresh for its max-age, or until it Expires, or else for TTL.
+// Stale responses, and those with no-cache, are revalidated with their ETag or Last-Modified.
+// Errors reading or writing the cache are ignored, falling back to the network.
+type HTTPCache struct {
+	Dir       string            // Directory of the cached responses, which must exist
+	TTL       time.Duration     // Freshness of responses that do not give their own
+	Transport http.RoundTripper // Transport of requests not served from the cache; nil for http.DefaultTransport
+}
+
+// RoundTrip serves a GET request from the cache if its response there is fresh,
+// and otherwise makes the request, caching a successful response
+func (c *HTTPCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return c.transport().RoundTrip(req)
+	}
+
+	file := c.filename(req)
+	cached, storedAt := c.load(file, req)
+	if cached != nil {
+		if httpCacheFresh(cached.Header, storedAt, c.TTL) {
+			return cached, nil
+		}
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := c.transport().RoundTrip(req)
+	if err != nil {
+		if cached != nil {
+			cached.Body.Close()
+		}
+		return nil, err
+	}
+	if cached != nil {
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			now := time.Now()
+			os.Chtimes(file, now, now)
+			return cached, nil
+		}
+		cached.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK || httpCacheDirectives(resp.Header)["no-store"] {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Del("Content-Length")
+	c.store(file, resp, body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// transport returns the transport of requests not served from the cache
+func (c *HTTPCache) transport() http.RoundTripper {
+	if c.Transport == nil {
+		return http.DefaultTransport
+	}
+	return c.Transport
+}
+
+// filename returns the path of the cached response to a request, named by the SHA-256 of its URL
+func (c *HTTPCache) filename(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".http")
+}
+
+// load returns the cached response to a request, and when it was stored or last revalidated,
+// or nil if there is none
+func (c *HTTPCache) load(file string, req *http.Request) (*http.Response, time.Time) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	return resp, info.ModTime()
+}
+
+// store writes a response with the given body to the cache, replacing any cached response
+func (c *HTTPCache) store(file string, resp *http.Response, body []byte) {
+	tmpFile, err := os.CreateTemp(c.Dir, ".http-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmpFile.Name()) // fails harmlessly once renamed
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	err = resp.Write(tmpFile)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		os.Rename(tmpFile.Name(), file)
+	}
+}
+
+// httpCacheFresh returns true if a response stored at storedAt may still be served without
+// revalidation, per its Cache-Control max-age, or its Expires, or else ttl
+func httpCacheFresh(header http.Header, storedAt time.Time, ttl time.Duration) bool {
+	directives := httpCacheDirectives(header)
+	if directives["no-cache"] {
+		return false
+	}
+	lifetime := ttl
+	if maxAge, ok := httpCacheMaxAge(header); ok {
+		lifetime = maxAge
+	} else if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return false // an invalid Expires means already expired
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			date = storedAt
+		}
+		lifetime = t.Sub(date)
+	}
+	return time.Since(storedAt) < lifetime
+}
+
+// httpCacheDirectives returns the set of the Cache-Control directives of a response, without their values
+func httpCacheDirectives(header http.Header) map[string]bool {
+	directives := make(map[string]bool)
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		directives[strings.ToLower(name)] = true
+	}
+	return directives
+}
+
+// httpCacheMaxAge returns the Cache-Control max-age of a response, and whether it has one
+func httpCacheMaxAge(header http.Header) (time.Duration, bool) {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Column describes a CSV column of records of type T, how to set it from a CSV field,
+// and optionally how to write it, for records whose CSVValue is made with CSVColumnsValue
+type Column[T any] struct {
+	Name   string                       // Header name, as written by CSVHeaders, e.g. "brand_name"
+	Set    func(rec *T, v string) error // Parses a field into the record
+	Format func(row *CSVRow, rec *T)    // Appends the record's field to a row; nil if the column is only read
+	// Source is the Socrata field the column is read from, as a dotted path of JSON names
+	// for nested fields, e.g. "lab_analysis.url", if it is not Name.  See Dictionary.
+	Source string
+	// Optional is true if the column may be missing from a CSV header, as it is from exports
+	// written before it was added.  A missing optional column is left empty.
+	Optional bool
+}
+
+// WithFormat returns a copy of the column written by format
+func (c Column[T]) WithFormat(format func(row *CSVRow, rec *T)) Column[T] {
+	c.Format = format
+	return c
+}
+
+// WithSource returns a copy of the column read from the given Socrata field
+func (c Column[T]) WithSource(source string) Column[T] {
+	c.Source = source
+	return c
+}
+
+// AsOptional returns a copy of the column that may be missing from a CSV header
+func (c Column[T]) AsOptional() Column[T] {
+	c.Optional = true
+	return c
+}
+
+// CSVImportable is implemented by records that can be read back from their CSV export with ReadCSV
+type CSVImportable[T any] interface {
+	// Columns returns the record's CSV columns, in CSVHeaders order
+	Columns() []Column[T]
+}
+
+// CSVField is implemented by field types that parse themselves from a CSV field, and append
+// themselves to one unquoted, such as measures
+type CSVField interface {
+	UnmarshalCSV(value string) error
+	AppendCSV(dst []byte) []byte
+}
+
+// StringColumn returns a Column that sets the string field returned by field, and writes it quoted
+func StringColumn[T any](name string, field func(rec *T) *string) Column[T] {
+	return Column[T]{
+		Name: name,
+		Set: func(rec *T, v string) error {
+			*field(rec) = UnguardCSVString(v)
+			return nil
+		},
+		Format: func(row *CSVRow, rec *T) { row.String(*field(rec)) },
+	}
+}
+
+// UnmarshalColumn returns a Column that parses into the field returned by field with its UnmarshalCSV,
+// and writes it with its AppendCSV
+func UnmarshalColumn[T any](name string, field func(rec *T) CSVField) Column[T] {
+	return Column[T]{
+		Name: name,
+		Set: func(rec *T, v string) error {
+			return field(rec).UnmarshalCSV(v)
+		},
+		Format: func(row *CSVRow, rec *T) { row.Append(field(rec).AppendCSV) },
+	}
+}
+
+// CSVColumnsValue returns the CSV row of rec, as CSVValue returns it, with a field for each of
+// the columns in order, so a record's columns are written just as ReadCSV reads them.
+// Columns without a Format are written empty.
+func CSVColumnsValue[T any](rec *T, columns []Column[T]) string {
+	var row CSVRow
+	row.Grow(10 * len(columns))
+	for _, column := range columns {
+		if column.Format != nil {
+			column.Format(&row, rec)
+		} else {
+			row.Raw("")
+		}
+	}
+	return row.Line()
+}
+
+// ReadCSV reads records of type T from CSV with a header row, such as written by WriteCSV.
+// Columns are mapped by name, so they may be in any order.
+// Returns a *ValidationError if the header is missing any of T's columns, other than optional ones,
+// or has extra ones.
+func ReadCSV[T CSVImportable[T]](r io.Reader) ([]T, error) {
+	var zero T
+	columns := zero.Columns()
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, &ValidationError{Msg: "CSV has no header row"}
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if len(header) > 0 { // Tolerate a byte order mark, as spreadsheet applications often add one
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	if err := checkCSVHeader(header, columns); err != nil {
+		return nil, err
+	}
+
+	// setters[i] sets the header's i'th column
+	setters := make([]func(*T, string) error, len(header))
+	for i, name := range header {
+		for _, col := range columns {
+			if col.Name == name {
+				setters[i] = col.Set
+			}
+		}
+	}
+
+	var records []T
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		var rec T
+		for i, field := range fields {
+			if err := setters[i](&rec, field); err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, &ValidationError{Msg: fmt.Sprintf("invalid %s %q on line %d: %v", header[i], field, line, err)}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// checkCSVHeader compares a CSV header with the expected columns, ignoring order.
+// Returns a *ValidationError listing the missing, extra, and duplicated columns, if there are any.
+func checkCSVHeader[T any](header []string, columns []Column[T]) error {
+	var missing, extra, duplicated []string
+	for _, col := range columns {
+		if !col.Optional && !slices.Contains(header, col.Name) {
+			missing = append(missing, col.Name)
+		}
+	}
+	seen := make(map[string]bool, len(header))
+	for _, name := range header {
+		if seen[name] {
+			duplicated = append(duplicated, name)
+		}
+		seen[name] = true
+		if !slices.ContainsFunc(columns, func(col Column[T]) bool { return col.Name == name }) {
+			extra = append(extra, name)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 && len(duplicated) == 0 {
+		return nil
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing columns "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		problems = append(problems, "extra columns "+strings.Join(extra, ", "))
+	}
+	if len(duplicated) > 0 {
+		problems = append(problems, "duplicated columns "+strings.Join(duplicated, ", "))
+	}
+	return &ValidationError{Msg: "CSV header does not match: " + strings.Join(problems, "; ")}
+}
+
+// ReadJSONFile reads records of type T from a file holding a JSON array of them,
+// in the shape a Socrata endpoint responds with, e.g. a manually downloaded extract.
+// If bad is not nil, records that do not decode are skipped and added to it, see UnmarshalRecords.
+func ReadJSONFile[T any](filename string, bad *BadRecords) ([]T, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	items, err := UnmarshalRecords[T](data, bad)
+	if err != nil {
+		return nil, &ValidationError{Msg: fmt.Sprintf("failed to parse %s: %v", filename, err)}
+	}
+	return items, nil
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest describes the files produced by an extraction run
+type Manifest struct {
+	GeneratedAt     time.Time        `json:"generated_at"`
+	Files           []ManifestFile   `json:"files"`
+	Datasets        []ManifestData   `json:"datasets,omitempty"`
+	Sources         []ManifestSource `json:"sources,omitempty"`
+	Timings         []PhaseTiming    `json:"timings,omitempty"`
+	DurationSeconds float64          `json:"duration_seconds,omitempty"` // Wall-clock duration of the whole run
+}
+
+// ManifestFile records an exported file and how many rows it holds
+type ManifestFile struct {
+	Dataset  string `json:"dataset"`          // Dataset name, e.g. "brands"
+	Filename string `json:"filename"`         // File name, relative to the output directory
+	Records  int    `json:"records"`          // Number of records in memory when exported
+	Rows     int    `json:"rows_written"`     // Number of rows the writer reported writing
+	SHA256   string `json:"sha256,omitempty"` // Hex SHA-256 of the file as written, set by RecordChecksums
+}
+
+// ManifestData fingerprints a dataset's records, so runs can be compared for upstream changes
+type ManifestData struct {
+	Dataset     string `json:"dataset"`      // Dataset name, e.g. "brands"
+	Records     int    `json:"records"`      // Number of records hashed
+	ContentHash string `json:"content_hash"` // ContentHash of the records, independent of order and format
+}
+
+// ManifestSource records the provenance of a dataset's fetch, so a run can be tied to the revision it read
+type ManifestSource struct {
+	Dataset string `json:"dataset"` // Dataset name, e.g. "brands"
+	SourceProvenance
+}
+
+// PhaseTiming records the wall-clock time a phase of the run took for a dataset
+type PhaseTiming struct {
+	Dataset string  `json:"dataset"` // Dataset name, e.g. "brands"
+	Phase   string  `json:"phase"`   // Phase name, e.g. "fetch" or "db_insert"
+	Seconds float64 `json:"seconds"`
+}
+
+// NewManifest returns an empty Manifest generated now
+func NewManifest() *Manifest {
+	return &Manifest{GeneratedAt: time.Now().UTC()}
+}
+
+// RecordFile adds an exported file to the manifest.
+// Returns an error if the rows written diverge from the in-memory record count,
+// which indicates rows were silently lost between fetch and export.
+func (m *Manifest) RecordFile(dataset string, filename string, records int, rows int) error {
+	m.Files = append(m.Files, ManifestFile{
+		Dataset:  dataset,
+		Filename: filename,
+		Records:  records,
+		Rows:     rows,
+	})
+	if records != rows {
+		return &ValidationError{Msg: fmt.Sprintf("row count mismatch for %s: %d records but %d rows written", filename, records, rows)}
+	}
+	return nil
+}
+
+// RecordContentHash adds a dataset's record count and ContentHash to the manifest
+func (m *Manifest) RecordContentHash(dataset string, records int, contentHash string) {
+	m.Datasets = append(m.Datasets, ManifestData{
+		Dataset:     dataset,
+		Records:     records,
+		ContentHash: contentHash,
+	})
+}
+
+// RecordSource adds the provenance of a dataset's fetch to the manifest
+func (m *Manifest) RecordSource(dataset string, source SourceProvenance) {
+	m.Sources = append(m.Sources, ManifestSource{Dataset: dataset, SourceProvenance: source})
+}
+
+// Append adds the files, fingerprints, and sources of other to the manifest
+func (m *Manifest) Append(other *Manifest) {
+	m.Files = append(m.Files, other.Files...)
+	m.Datasets = append(m.Datasets, other.Datasets...)
+	m.Sources = append(m.Sources, other.Sources...)
+}
+
+// RecordChecksums sets the SHA256 of each of the manifest's files, which are relative to dir.
+// It is called once the files are final, e.g. after compression.
+func (m *Manifest) RecordChecksums(dir string) error {
+	for i := range m.Files {
+		sum, err := fileSHA256(filepath.Join(dir, m.Files[i].Filename))
+		if err != nil {
+			return err
+		}
+		m.Files[i].SHA256 = sum
+	}
+	return nil
+}
+
+// CheckFiles verifies that each of the manifest's files, which are relative to dir,
+// exists and has its recorded SHA256.  Files without a checksum are only checked to exist.
+// Returns a *ValidationError listing every file that is missing or differs.
+func (m *Manifest) CheckFiles(dir string) error {
+	var problems []string
+	for _, file := range m.Files {
+		sum, err := fileSHA256(filepath.Join(dir, file.Filename))
+		if errors.Is(err, os.ErrNotExist) {
+			problems = append(problems, file.Filename+" is missing")
+		} else if err != nil {
+			return err
+		} else if file.SHA256 != "" && sum != file.SHA256 {
+			problems = append(problems, file.Filename+" has changed")
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Msg: "manifest files do not match: " + strings.Join(problems, "; ")}
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's contents
+func fileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encodeManifest returns the manifest's JSON with pretty formatting, as written by WriteManifest
+// and covered by SignManifest
+func encodeManifest(m *Manifest) ([]byte, error) {
+	manifestBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return append(manifestBytes, '\n'), nil
+}
+
+// WriteManifest writes the manifest to a JSON file with pretty formatting
+func WriteManifest(filename string, m *Manifest) error {
+	manifestBytes, err := encodeManifest(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, manifestBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads a manifest written by WriteManifest
+func ReadManifest(filename string) (*Manifest, error) {
+	manifestBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifestBytes, &m); err != nil {
+		return nil, &ValidationError{Msg: fmt.Sprintf("failed to parse manifest %s: %v", filename, err)}
+	}
+	return &m, nil
+}
+
+// ManifestHistoryFilename is the default file of AppendManifestHistory, within the DankDir
+const ManifestHistoryFilename = "manifest_history.jsonl"
+
+// AppendManifestHistory appends the manifest as one line of compact JSON to a
+// newline-delimited JSON history file, creating it if needed, so the file accumulates
+// a chronological record of runs.  Each line is appended with a single write to a file
+// opened for appending, so lines of concurrent runs do not interleave.
+func AppendManifestHistory(filename string, m *Manifest) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest history: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append manifest history: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultNameTemplate reproduces the built-in output file names, e.g. "us_ct_brands.csv"
+const DefaultNameTemplate = "{{.Source}}_{{.State}}_{{.Dataset}}.{{.Ext}}"
+
+// NameContext holds the variables available to an output name template
+type NameContext struct {
+	Source  string // Source region, e.g. "us"
+	State   string // State within the source region, e.g. "ct"
+	Dataset string // Dataset part of the file name, e.g. "brands" or "weekly_sales"
+	Date    string // Extraction date in YYYY-MM-DD format
+	Ext     string // File extension without the leading '.', e.g. "csv"
+}
+
+// NameContextFor splits a built-in file name of the form "<source>_<state>_<dataset>.<ext>",
+// e.g. "us_ct_brands.csv", into a NameContext for the given date.
+func NameContextFor(filename string, date string) NameContext {
+	stem, ext, _ := strings.Cut(filename, ".")
+	nameCtx := NameContext{Dataset: stem, Date: date, Ext: ext}
+	if parts := strings.SplitN(stem, "_", 3); len(parts) == 3 {
+		nameCtx.Source, nameCtx.State, nameCtx.Dataset = parts[0], parts[1], parts[2]
+	}
+	return nameCtx
+}
+
+// RenderName renders an output file name from a text/template, such as DefaultNameTemplate.
+// Returns an error if the template is invalid, or renders an empty name or one with a path separator.
+func RenderName(tmpl string, ctx NameContext) (string, error) {
+	t, err := template.New("name").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse name template: %w", err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, ctx); err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+	name := sb.String()
+	if name == "" {
+		return "", fmt.Errorf("name template rendered an empty name")
+	}
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("name template rendered %q, which contains a path separator", name)
+	}
+	return name, nil
+}
+
+// ValidateNameTemplate checks that a template renders, and that it gives distinct names
+// to different datasets and extensions, so that output files cannot overwrite each other.
+func ValidateNameTemplate(tmpl string) error {
+	seen := make(map[string]bool)
+	for _, filename := range []string{"us_ct_brands.csv", "us_ct_brands.json", "us_ct_tax.csv"} {
+		name, err := RenderName(tmpl, NameContextFor(filename, "2006-01-02"))
+		if err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("name template renders %q for more than one file, it should use {{.Dataset}} and {{.Ext}}", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
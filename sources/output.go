@@ -0,0 +1,23 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnsureOutputDir ensures the output directory exists, creating it and any missing
+// parents if needed, and that files can be created in it.
+// Returns an error naming the directory if it cannot be created or written to.
+func EnsureOutputDir(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", path, err)
+	}
+	probe, err := os.CreateTemp(path, ".dank-extract-*")
+	if err != nil {
+		return fmt.Errorf("output directory %s is not writable: %w", path, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
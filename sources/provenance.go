@@ -0,0 +1,55 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"cmp"
+	"net/http"
+	"sync"
+)
+
+// SocrataTruthLastModifiedHeader is the response header in which Socrata reports when a dataset
+// was last updated, which unlike Last-Modified does not vary with the query
+const SocrataTruthLastModifiedHeader = "X-SODA2-Truth-Last-Modified"
+
+// SourceProvenance records which revision of a dataset a fetch read, for the manifest
+type SourceProvenance struct {
+	URL          string `json:"url"`                     // API endpoint URL, without query parameters
+	Revision     string `json:"revision,omitempty"`      // Revision the fetch was pinned to with SocrataConfig.Revision
+	ETag         string `json:"etag,omitempty"`          // ETag of the fetch's first page
+	LastModified string `json:"last_modified,omitempty"` // When the portal reported the dataset was last updated
+}
+
+// Provenance collects the SourceProvenance of the fetches of a SocrataConfig with it as its
+// Provenance, as observed in the response headers of each fetch's first page.
+// Its methods are safe for concurrent use.
+type Provenance struct {
+	mu       sync.Mutex
+	source   SourceProvenance
+	observed bool
+}
+
+// Source returns the provenance observed, and false if no page has been fetched,
+// e.g. if the records were loaded from the cache
+func (p *Provenance) Source() (SourceProvenance, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.source, p.observed
+}
+
+// observe records the provenance of a fetch of url pinned to revision, from the headers of its
+// first page's response.  Later pages are of the same revision, so are ignored.
+func (p *Provenance) observe(url string, revision string, header http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.observed {
+		return
+	}
+	p.source = SourceProvenance{
+		URL:          url,
+		Revision:     revision,
+		ETag:         header.Get("ETag"),
+		LastModified: cmp.Or(header.Get(SocrataTruthLastModifiedHeader), header.Get("Last-Modified")),
+	}
+	p.observed = true
+}
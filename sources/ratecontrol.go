@@ -0,0 +1,144 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateController coordinates backoff across every Socrata fetch in the process.
+// When one fetch is throttled with HTTP 429, every subsequent request, from any
+// dataset, waits out the backoff, since the limit is usually shared per IP or token.
+// Each waiter adds its own random jitter so that they do not all resume at once.
+// Its methods are safe for concurrent use.
+type RateController struct {
+	BaseDelay time.Duration // Backoff after the first 429; doubled on each consecutive 429
+	MaxDelay  time.Duration // Upper bound on the backoff
+	Jitter    float64       // Fraction of the current backoff added at random to each wait, 0 to 1
+
+	mu      sync.Mutex
+	strikes int           // number of consecutive 429s
+	delay   time.Duration // current backoff
+	until   time.Time     // requests should not be made before this time
+}
+
+// DefaultRateController is the RateController shared by all Socrata fetches.
+// It may be reconfigured before fetching, or set to nil to disable global backoff.
+var DefaultRateController = &RateController{
+	BaseDelay: 2 * time.Second,
+	MaxDelay:  2 * time.Minute,
+	Jitter:    0.5,
+}
+
+// Wait blocks until requests may resume, or the context is done.
+// Returns the context's error if it is done first.
+func (c *RateController) Wait(ctx context.Context) error {
+	c.mu.Lock()
+	wait := time.Until(c.until)
+	if wait > 0 && c.Jitter > 0 {
+		wait += time.Duration(rand.Float64() * c.Jitter * float64(c.delay))
+	}
+	c.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Throttled records an HTTP 429, extending the shared backoff exponentially.
+// A retryAfter longer than the computed backoff, e.g. from a Retry-After header, takes precedence.
+func (c *RateController) Throttled(retryAfter time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.strikes++
+	delay := c.BaseDelay << (c.strikes - 1)
+	if delay <= 0 || (c.MaxDelay > 0 && delay > c.MaxDelay) {
+		delay = c.MaxDelay
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	c.delay = delay
+	if until := time.Now().Add(delay); until.After(c.until) {
+		c.until = until
+	}
+}
+
+// Succeeded records a successful request, resetting the consecutive 429 count.
+func (c *RateController) Succeeded() {
+	c.mu.Lock()
+	c.strikes = 0
+	c.mu.Unlock()
+}
+
+// Limiter paces requests, e.g. a RateLimiter, or a *rate.Limiter of golang.org/x/time/rate
+type Limiter interface {
+	// Wait blocks until a request may be made, or ctx is done, returning ctx's error if it is done first
+	Wait(ctx context.Context) error
+}
+
+// DefaultLimiter, if set, paces the page requests of every Socrata fetch whose SocrataConfig
+// has no Limiter of its own, so one limiter can cap the request rate of a whole run.
+// It is nil by default, for no limit.
+var DefaultLimiter Limiter
+
+// RateLimiter is a Limiter that spaces requests evenly, at most one per interval, without bursts.
+// Its methods are safe for concurrent use.
+type RateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // the next request may not be made before this time
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond requests per second, which must be positive
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next request may be made, claiming its slot, or until the context is done.
+// Returns the context's error if it is done first, in which case the slot goes unused.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := time.Now()
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := time.Until(at); wait > 0 {
+		return sleepContext(ctx, wait)
+	}
+	return ctx.Err()
+}
+
+// retryAfter parses the Retry-After header of a response, in either seconds or HTTP-date form.
+// Returns 0 if it is absent or invalid.
+func retryAfter(resp *http.Response) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RetryBudget bounds the retries of failed Socrata page requests across a whole run, so one
+// persistently failing endpoint cannot spend the run's time retrying while other datasets wait.
+// Each fetch may retry at most PerFetch times, and every fetch together at most Total times;
+// once either is spent, the fetch fails with the error of its last attempt.
+// Its methods are safe for concurrent use.
+type RetryBudget struct {
+	Total    int           // Most retries across every fetch
+	PerFetch int           // Most retries of any one fetch, e.g. of one dataset; 0 for no cap beyond Total
+	Delay    time.Duration // Wait before each retry, on top of any DefaultRateController backoff
+
+	mu   sync.Mutex
+	used int // number of retries taken
+}
+
+// DefaultRetryBudget is the RetryBudget shared by all Socrata fetches.
+// It is nil by default, so failed requests are not retried.
+var DefaultRetryBudget *RetryBudget
+
+// take spends one retry of a fetch that has already retried the given number of times.
+// Returns false, spending nothing, if the fetch or the budget has none left.
+func (b *RetryBudget) take(retried int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used >= b.Total || (b.PerFetch > 0 && retried >= b.PerFetch) {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// Remaining returns the number of retries left in the budget
+func (b *RetryBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.Total-b.used, 0)
+}
+
+// DefaultRetryBaseDelay is the wait before a page's first retry under a SocrataConfig's
+// MaxRetries, if it has no RetryBaseDelay
+const DefaultRetryBaseDelay = time.Second
+
+// MaxRetryDelay bounds the exponential backoff between retries under a SocrataConfig's MaxRetries
+const MaxRetryDelay = 2 * time.Minute
+
+// allowRetry returns true, spending a retry of any budget, if a page that has been retried
+// the given number of times may be retried again, by a fetch that has retried fetchRetries times
+func (cfg SocrataConfig) allowRetry(budget *RetryBudget, pageRetries, fetchRetries int) bool {
+	if cfg.MaxRetries > 0 {
+		return pageRetries < cfg.MaxRetries && (budget == nil || budget.take(fetchRetries))
+	}
+	return budget != nil && budget.take(fetchRetries)
+}
+
+// retryDelay returns the wait before a page's retry'th retry, counting from 1, whose previous
+// attempt failed with err.  A Retry-After of that attempt's response is waited out as it asked.
+// Otherwise, under MaxRetries, the wait is RetryBaseDelay doubled on each retry, up to
+// MaxRetryDelay, with up to half again added at random, so that retries do not all line up;
+// without it, the wait is the budget's Delay.
+func (cfg SocrataConfig) retryDelay(budget *RetryBudget, retry int, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+	if cfg.MaxRetries <= 0 {
+		return budget.Delay
+	}
+	delay := cmp.Or(cfg.RetryBaseDelay, DefaultRetryBaseDelay)
+	for i := 1; i < retry && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, MaxRetryDelay)
+	return delay + time.Duration(rand.Float64()*0.5*float64(delay))
+}
+
+// isRetryable returns true if a failed request may succeed when repeated:
+// a network failure, or an HTTP 429 or 5xx response
+func isRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// sleepContext waits for d, returning ctx's error early if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
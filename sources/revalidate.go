@@ -0,0 +1,90 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// CacheETagSuffix is appended to a cache filename to name its ETag sidecar file, which holds
+// the ETag of the first page of the fetch that wrote the cache, e.g. "us_ct_tax.json.etag"
+const CacheETagSuffix = ".etag"
+
+// errNotModified reports a fetch whose first page was answered 304 Not Modified,
+// so its expired cache still holds the dataset
+var errNotModified = errors.New("dataset not modified since it was cached")
+
+// revalidating returns cfg set up to record the ETag of its fetch's first page, for the cache's
+// ETag sidecar.  Unless maxCacheAge is AlwaysFetch, the ETag of an existing cache, however old,
+// is sent as the first page's If-None-Match, so that a portal can answer that the dataset is
+// unchanged, and the fetch fails with errNotModified.
+func (cfg SocrataConfig) revalidating(maxCacheAge time.Duration) SocrataConfig {
+	cfg.etags = &Provenance{}
+	if maxCacheAge >= 0 && CacheStatus(cfg.CacheName(), AnyCacheAge) == nil {
+		cfg.ifNoneMatch = readCacheETag(cfg.CacheName())
+	}
+	return cfg
+}
+
+// fetchedETag returns the ETag of the first page of cfg's fetch, or "" if it had none
+func (cfg SocrataConfig) fetchedETag() string {
+	if cfg.etags == nil {
+		return ""
+	}
+	source, _ := cfg.etags.Source()
+	return source.ETag
+}
+
+// loadRevalidatedCache returns the records of cfg's cache, whatever its age, once its fetch
+// found the dataset unchanged, and true if they can be used, as loadCache does, recording it
+// in cfg.CacheEvents.  The cache is touched if TouchUnchangedCache is set, as it is as fresh
+// as if it had just been fetched.
+func loadRevalidatedCache[T any](cfg SocrataConfig) ([]T, bool, error) {
+	cached, ok, err := loadCache[T](cfg, AnyCacheAge)
+	if !ok {
+		return nil, false, err
+	}
+	if TouchUnchangedCache {
+		now := time.Now()
+		os.Chtimes(CacheFilePath(cfg.CacheName()), now, now)
+	}
+	if cfg.CacheEvents != nil {
+		cfg.CacheEvents.Record(CacheEvent{Filename: cfg.CacheName(), Outcome: CacheRevalidated})
+	}
+	return cached, true, nil
+}
+
+// readCacheETag returns the ETag recorded in a cache file's ETag sidecar, or "" if it has none
+func readCacheETag(filename string) string {
+	etagBytes, err := os.ReadFile(GetDankCachePathname(filename + CacheETagSuffix))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(etagBytes))
+}
+
+// writeCacheETag records etag in a cache file's ETag sidecar, as that of the cache's records
+func writeCacheETag(filename string, etag string) error {
+	if err := os.WriteFile(GetDankCachePathname(filename+CacheETagSuffix), []byte(etag+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write cache ETag: %w", err)
+	}
+	return nil
+}
+
+// replaceCacheETag records etag in a cache file's ETag sidecar once the cache has been replaced,
+// or removes the sidecar if etag is empty, so that the replaced records' ETag is never taken for
+// the new ones.  It is only called after the replacement succeeds, so a failed write keeps both
+// the previous cache and its ETag.
+func replaceCacheETag(filename string, etag string) error {
+	if etag != "" {
+		return writeCacheETag(filename, etag)
+	}
+	if err := os.Remove(GetDankCachePathname(filename + CacheETagSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cache ETag: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// RunState records which datasets of a run have completed, so that a run interrupted by
+// a crash can be resumed, skipping them.  It is rewritten as each dataset completes.
+type RunState struct {
+	Completed []CompletedDataset `json:"completed"`
+}
+
+// CompletedDataset records a dataset that was fetched, exported, and loaded,
+// with what it added to the run's manifest, so a resumed run's manifest still covers it
+type CompletedDataset struct {
+	Dataset     string           `json:"dataset"`            // Dataset name, e.g. "brands"
+	CompletedAt time.Time        `json:"completed_at"`       // When the dataset completed
+	Files       []ManifestFile   `json:"files,omitempty"`    // Files the dataset exported
+	Datasets    []ManifestData   `json:"datasets,omitempty"` // Fingerprints of the dataset's records
+	Sources     []ManifestSource `json:"sources,omitempty"`  // Provenance of the dataset's fetch
+}
+
+// ReadRunState reads a run state written by WriteRunState.
+// Returns an empty state without an error if the file does not exist, as before a run's first dataset completes.
+func ReadRunState(filename string) (*RunState, error) {
+	stateBytes, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return &RunState{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+	var s RunState
+	if err := json.Unmarshal(stateBytes, &s); err != nil {
+		return nil, &ValidationError{Msg: fmt.Sprintf("failed to parse run state %s: %v", filename, err)}
+	}
+	return &s, nil
+}
+
+// WriteRunState writes the run state to a JSON file with pretty formatting.  It is written to a
+// temporary file that replaces the old one, so a crash while writing leaves the old state intact.
+func WriteRunState(filename string, s *RunState) error {
+	stateBytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // once renamed, there is nothing to remove
+	if _, err := tmp.Write(append(stateBytes, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	return nil
+}
+
+// IsComplete returns whether the named dataset has completed
+func (s *RunState) IsComplete(dataset string) bool {
+	_, ok := s.completed(dataset)
+	return ok
+}
+
+// Complete marks the named dataset as completed, with m, a manifest of only its entries,
+// replacing any previous completion of it
+func (s *RunState) Complete(dataset string, m *Manifest) {
+	done := CompletedDataset{
+		Dataset:     dataset,
+		CompletedAt: time.Now().UTC(),
+		Files:       m.Files,
+		Datasets:    m.Datasets,
+		Sources:     m.Sources,
+	}
+	if i, ok := s.completed(dataset); ok {
+		s.Completed[i] = done
+	} else {
+		s.Completed = append(s.Completed, done)
+	}
+}
+
+// Restore adds the manifest entries of the named completed dataset to m, as if it had been
+// processed by this run.  Returns the files it had exported.
+func (s *RunState) Restore(dataset string, m *Manifest) []ManifestFile {
+	i, ok := s.completed(dataset)
+	if !ok {
+		return nil
+	}
+	done := s.Completed[i]
+	m.Append(&Manifest{Files: done.Files, Datasets: done.Datasets, Sources: done.Sources})
+	return done.Files
+}
+
+// completed returns the index of the named dataset in Completed, and whether it is there
+func (s *RunState) completed(dataset string) (int, bool) {
+	i := slices.IndexFunc(s.Completed, func(done CompletedDataset) bool { return done.Dataset == dataset })
+	return i, i >= 0
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignatureExtension is appended to a manifest's file name to name its signature file,
+// e.g. "us_ct_manifest.json.sig"
+const SignatureExtension = ".sig"
+
+// SignManifest returns the Ed25519 signature of the manifest, as it is written by WriteManifest.
+// Since the manifest holds the SHA-256 of each file, the signature covers the whole extract.
+func SignManifest(m Manifest, key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 private key of %d bytes", len(key))
+	}
+	manifestBytes, err := encodeManifest(&m)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(key, manifestBytes), nil
+}
+
+// VerifyManifest checks an Ed25519 signature of the manifest made by SignManifest.
+// Returns a *ValidationError if the signature does not match the manifest and key.
+func VerifyManifest(m Manifest, sig []byte, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid Ed25519 public key of %d bytes", len(key))
+	}
+	manifestBytes, err := encodeManifest(&m)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, manifestBytes, sig) {
+		return &ValidationError{Msg: "manifest signature is invalid"}
+	}
+	return nil
+}
+
+// WriteSignature writes a signature to a file, base64-encoded
+func WriteSignature(filename string, sig []byte) error {
+	if err := os.WriteFile(filename, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// ReadSignature reads a signature file written by WriteSignature
+func ReadSignature(filename string) ([]byte, error) {
+	sigBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigBytes)))
+	if err != nil {
+		return nil, &ValidationError{Msg: fmt.Sprintf("failed to decode signature %s: %v", filename, err)}
+	}
+	return sig, nil
+}
+
+// LoadSigningKey reads an Ed25519 private key from a PEM file in PKCS #8 form,
+// such as made by "openssl genpkey -algorithm ed25519"
+func LoadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(filename, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", filename, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an Ed25519 key", filename)
+	}
+	return edKey, nil
+}
+
+// LoadVerifyKey reads an Ed25519 public key from a PEM file in PKIX form,
+// such as made by "openssl pkey -pubout"
+func LoadVerifyKey(filename string) (ed25519.PublicKey, error) {
+	der, err := readPEM(filename, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", filename, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", filename)
+	}
+	return edKey, nil
+}
+
+// readPEM returns the contents of the first PEM block of the given type in a file
+func readPEM(filename string, blockType string) ([]byte, error) {
+	pemBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			return nil, fmt.Errorf("no %s PEM block in %s", blockType, filename)
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
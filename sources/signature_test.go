@@ -0,0 +1,150 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testManifest returns a manifest of two files, as a run writes it
+func testManifest() Manifest {
+	return Manifest{
+		GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Files: []ManifestFile{
+			{Dataset: "brands", Filename: "us_ct_brands.csv", Records: 2, Rows: 2, SHA256: "aa11"},
+			{Dataset: "tax", Filename: "us_ct_tax.csv", Records: 5, Rows: 5, SHA256: "bb22"},
+		},
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignManifest(testManifest(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest func(m *Manifest)
+		sig      []byte
+		key      ed25519.PublicKey
+		valid    bool
+	}{
+		{name: "signed manifest verifies", sig: sig, key: pub, valid: true},
+		{name: "modified file checksum", manifest: func(m *Manifest) { m.Files[1].SHA256 = "bb23" }, sig: sig, key: pub},
+		{name: "modified file name", manifest: func(m *Manifest) { m.Files[0].Filename = "other.csv" }, sig: sig, key: pub},
+		{name: "removed file", manifest: func(m *Manifest) { m.Files = m.Files[:1] }, sig: sig, key: pub},
+		{name: "modified generation time", manifest: func(m *Manifest) { m.GeneratedAt = m.GeneratedAt.Add(time.Second) }, sig: sig, key: pub},
+		{name: "wrong key", sig: sig, key: otherPub},
+		{name: "truncated signature", sig: sig[:len(sig)-1], key: pub},
+		{name: "empty signature", sig: nil, key: pub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testManifest()
+			if tt.manifest != nil {
+				tt.manifest(&m)
+			}
+			err := VerifyManifest(m, tt.sig, tt.key)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("VerifyManifest() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("VerifyManifest() = %v, want a *ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestSignManifestInvalidKey(t *testing.T) {
+	if _, err := SignManifest(testManifest(), ed25519.PrivateKey("short")); err == nil {
+		t.Error("SignManifest() with a short key succeeded, want an error")
+	}
+	if err := VerifyManifest(testManifest(), nil, ed25519.PublicKey("short")); err == nil {
+		t.Error("VerifyManifest() with a short key succeeded, want an error")
+	}
+}
+
+func TestSignatureFileRoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign and verify with key files, as --sign-key and "verify --key" read them
+	signingKey, err := LoadSigningKey(writePEM("key.pem", "PRIVATE KEY", privDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyKey, err := LoadVerifyKey(writePEM("key.pub", "PUBLIC KEY", pubDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignManifest(testManifest(), signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigFile := filepath.Join(dir, "manifest.json"+SignatureExtension)
+	if err := WriteSignature(sigFile, sig); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSignature(sigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(read, sig) {
+		t.Errorf("ReadSignature() = %x, want %x", read, sig)
+	}
+	if err := VerifyManifest(testManifest(), read, verifyKey); err != nil {
+		t.Errorf("VerifyManifest() = %v, want nil", err)
+	}
+
+	// A public key is not a signing key
+	if _, err := LoadSigningKey(filepath.Join(dir, "key.pub")); err == nil {
+		t.Error("LoadSigningKey() of a public key succeeded, want an error")
+	}
+	// A signature file that is not base64 is invalid
+	if err := os.WriteFile(sigFile, []byte("not base64!\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var verr *ValidationError
+	if _, err := ReadSignature(sigFile); !errors.As(err, &verr) {
+		t.Errorf("ReadSignature() of a corrupt file = %v, want a *ValidationError", err)
+	}
+}
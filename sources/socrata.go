@@ -3,107 +3,608 @@
 package sources
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // SocrataConfig holds configuration for a Socrata API endpoint
 type SocrataConfig struct {
-	URL           string // API endpoint URL
-	CacheFilename string // Filename for caching results
-	OrderBy       string // Field to order by (required for pagination)
-	BatchSize     int    // Records per request (default 5000, set higher to disable pagination)
+	URL           string       // API endpoint URL
+	CacheFilename string       // Filename for caching results; see CacheName
+	OrderBy       string       // Field to order by (required for pagination)
+	Select        []string     // Fields to request with $select; empty for every field.  See CacheName.
+	BatchSize     int          // Records per request (default 5000), clamped to MaxBatchSize
+	MaxBatchSize  int          // Most records the server returns per request (default DefaultMaxBatchSize)
+	RawCache      bool         // Cache the response bodies' records byte-for-byte, rather than re-encoding the decoded records
+	Stats         *FetchStats  // Optionally collects the stats of each page requested
+	CacheEvents   *CacheEvents // Optionally collects what the fetches did with the cache, e.g. keep it unchanged
+	// BadRecords, if set, collects the records that do not decode, which are skipped rather
+	// than failing the fetch, whether fetched or cached.  Malformed JSON still fails it.
+	BadRecords *BadRecords
+	// Revision, if set, pins the fetch to a revision of the dataset: it is sent as the If-Match
+	// header of each page request, so a portal that supports it fails the fetch, rather than
+	// serve another revision, if the dataset has been republished since.  Records loaded from
+	// the cache are not checked against it.
+	Revision string
+	// Provenance, if set, records the revision each fetch read, or at least when the dataset was last modified
+	Provenance *Provenance
+	// DeltaField is the field of the watermark of incremental fetches, which fetch only the records
+	// with it at or beyond the greatest value cached, e.g. "period_end_date".  Its values must sort
+	// lexically, as ISO 8601 dates do.  Empty for OrderBy; if both are empty, incremental fetches
+	// fetch every record.
+	DeltaField string
+	// Where, if set, is a SoQL clause passed verbatim as the $where of each page request, to fetch
+	// only the matching records, e.g. "fiscal_year = 2024".  It is not checked or escaped here,
+	// so must be valid SoQL.  Its fetches are cached apart from those of other clauses.
+	Where string
+	// MaxRetries, if positive, retries each failed page request, as isRetryable allows, up to this
+	// many times, with exponential backoff from RetryBaseDelay (default DefaultRetryBaseDelay),
+	// or as long as the response's Retry-After asks.  Any DefaultRetryBudget must also allow each retry.
+	MaxRetries     int
+	RetryBaseDelay time.Duration // Wait before a page's first retry, doubled on each after
+	// HTTPClient, if set, makes the page requests, e.g. with a proxy, TLS config, or timeout of its own;
+	// nil for DefaultHTTPClient
+	HTTPClient *http.Client
+	// Limiter, if set, is waited on before each page request, retries included; nil for DefaultLimiter.
+	// Sharing one between fetches caps their total request rate.
+	Limiter Limiter
+
+	ifNoneMatch string      // ETag of the cache, sent with the first page request; see revalidating
+	etags       *Provenance // Observes the ETag of the fetch's first page, for the cache; see revalidating
+}
+
+// ConfigOption sets an optional field of a SocrataConfig, e.g. when passed to a fetch of the CT datasets
+type ConfigOption func(*SocrataConfig)
+
+// WithHTTPClient is a ConfigOption that has the fetch make its page requests with client
+func WithHTTPClient(client *http.Client) ConfigOption {
+	return func(cfg *SocrataConfig) {
+		cfg.HTTPClient = client
+	}
+}
+
+// WithLimiter is a ConfigOption that has the fetch wait on limiter before each page request
+func WithLimiter(limiter Limiter) ConfigOption {
+	return func(cfg *SocrataConfig) {
+		cfg.Limiter = limiter
+	}
+}
+
+// With returns a copy of cfg with the options applied
+func (cfg SocrataConfig) With(opts ...ConfigOption) SocrataConfig {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// DefaultHTTPTimeout bounds each request of DefaultHTTPClient, from sending it through reading its body
+var DefaultHTTPTimeout = 30 * time.Second
+
+// DefaultHTTPClient returns the client of Socrata page requests whose SocrataConfig has no HTTPClient:
+// one with DefaultTransport, as it is when called, and DefaultHTTPTimeout
+func DefaultHTTPClient() *http.Client {
+	return &http.Client{Transport: DefaultTransport, Timeout: DefaultHTTPTimeout}
+}
+
+// CacheName returns the name of the cache file of cfg's fetches: CacheFilename, with a hash of
+// any Select and of any Where clause, e.g. "us_ct_tax_select_1a2b3c4d.json", so that fetches of
+// other fields or records are never served, or overwrite, each other's caches.
+// A CacheFilename without an extension gives a name without one.
+func (cfg SocrataConfig) CacheName() string {
+	if len(cfg.Select) == 0 && cfg.Where == "" {
+		return cfg.CacheFilename
+	}
+	stem, ext, found := strings.Cut(cfg.CacheFilename, ".")
+	if len(cfg.Select) > 0 {
+		stem += "_select_" + shortHash(strings.Join(cfg.Select, ","))
+	}
+	if cfg.Where != "" {
+		stem += "_where_" + shortHash(cfg.Where)
+	}
+	if !found {
+		return stem
+	}
+	return stem + "." + ext
+}
+
+// shortHash returns the first 8 hex digits of the SHA-256 of s, to tell apart the caches of queries
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// DefaultMaxBatchSize is the most records Socrata endpoints commonly return per request.
+// A server returns no more than its cap, however many records are asked for, so a larger
+// batch size would make a full page look like the last one, silently ending pagination early.
+const DefaultMaxBatchSize = 50000
+
+// DefaultPageTimeout bounds each Socrata page request, from sending it through reading its body,
+// so one stuck page fails, and may be retried, rather than hanging the fetch.
+// It is zero by default, for no timeout.
+var DefaultPageTimeout time.Duration
+
+// Transform rewrites freshly fetched records, e.g. to normalize, filter, or derive fields.
+// A nil Transform leaves records as they are.
+type Transform[T any] func(items []T) ([]T, error)
+
+// Apply returns the transformed items, or the items themselves if t is nil
+func (t Transform[T]) Apply(items []T) ([]T, error) {
+	if t == nil {
+		return items, nil
+	}
+	return t(items)
 }
 
 // FetchSocrata fetches data from a Socrata API endpoint with caching and pagination.
 // It handles the common pattern of: check cache, paginate requests, unmarshal, cache.
+// A maxCacheAge of AnyCacheAge uses a cache file of any age, and AlwaysFetch always fetches.
+// A cache too old to use is revalidated with the ETag its fetch was sent, if any: the first page
+// is requested with If-None-Match, and if the portal answers 304 Not Modified, the cache is used.
+// A cache that is there but cannot be decoded is discarded, as a CacheDiscarded event of cfg.CacheEvents,
+// and fetched again, unless FailOnUnreadableCache is set, when its *CacheError is returned.
 func FetchSocrata[T any](cfg SocrataConfig, appToken string, maxCacheAge time.Duration) ([]T, error) {
+	return FetchSocrataContext[T](context.Background(), cfg, appToken, maxCacheAge)
+}
+
+// FetchSocrataContext is FetchSocrata, fetching within ctx.  Canceling ctx, or its deadline
+// passing, aborts the fetch, even mid-pagination; an in-flight request is abandoned,
+// no more pages or retries are requested, and ctx's error is returned.  The cache is
+// not written by an aborted fetch.
+func FetchSocrataContext[T any](ctx context.Context, cfg SocrataConfig, appToken string, maxCacheAge time.Duration) ([]T, error) {
+	return FetchSocrataWithContext[T](ctx, cfg, appToken, maxCacheAge, nil)
+}
+
+// FetchSocrataWith is FetchSocrata, with transform applied to the records once they are
+// fetched and before they are cached.  Records loaded from the cache were transformed
+// when they were fetched, so are returned as they are.  A nil transform does nothing.
+// With cfg.RawCache, the cache holds the records as the API sent them, unless there is
+// a transform, whose records must be re-encoded.
+func FetchSocrataWith[T any](cfg SocrataConfig, appToken string, maxCacheAge time.Duration, transform Transform[T]) ([]T, error) {
+	return FetchSocrataWithContext(context.Background(), cfg, appToken, maxCacheAge, transform)
+}
+
+// FetchSocrataWithContext is FetchSocrataWith, fetching within ctx, as FetchSocrataContext does
+func FetchSocrataWithContext[T any](ctx context.Context, cfg SocrataConfig, appToken string, maxCacheAge time.Duration, transform Transform[T]) ([]T, error) {
 	// Check cache first
-	if cacheBytes, err := CheckCacheFile(cfg.CacheFilename, maxCacheAge); err == nil {
-		var cached []T
-		if err := json.Unmarshal(cacheBytes, &cached); err == nil {
+	if cached, ok, err := loadCache[T](cfg, maxCacheAge); err != nil {
+		return nil, err
+	} else if ok {
+		return cached, nil
+	}
+
+	cfg = cfg.revalidating(maxCacheAge)
+	allItems, err := fetchSocrataFresh(ctx, cfg, appToken, transform)
+	if errors.Is(err, errNotModified) {
+		if cached, ok, err := loadRevalidatedCache[T](cfg); err != nil {
+			return nil, err
+		} else if ok {
 			return cached, nil
 		}
+		cfg.ifNoneMatch = "" // the cache cannot be used after all, so fetch it in full
+		allItems, err = fetchSocrataFresh(ctx, cfg, appToken, transform)
+	}
+	return allItems, err
+}
+
+// fetchSocrataFresh fetches every record, as FetchSocrataWithContext does without a usable cache,
+// and caches them with the ETag of their first page
+func fetchSocrataFresh[T any](ctx context.Context, cfg SocrataConfig, appToken string, transform Transform[T]) ([]T, error) {
+	if cfg.RawCache && transform == nil {
+		return fetchSocrataRawCached[T](ctx, cfg, appToken)
+	}
+
+	allItems, err := fetchSocrataPages[T](ctx, cfg, appToken, "")
+	if err != nil {
+		return nil, err
+	}
+	if allItems, err = transform.Apply(allItems); err != nil {
+		return nil, fmt.Errorf("failed to transform records: %w", err)
+	}
+
+	writeSocrataCache(cfg, allItems, cfg.fetchedETag())
+	return allItems, nil
+}
+
+// IncrementalFetchSocrata refreshes the cache of an append-mostly dataset by fetching
+// only the records at or beyond the watermark, then merging them into the cache.
+// The watermark is of cfg.DeltaField, or cfg.OrderBy, and is read from the dataset's Watermark
+// file, or for caches without one, is the greatest value of the field among the cached records.
+// That is the record's struct field of the same JSON name, or if there is none, its keyFn value.
+// The fetch window includes the watermark itself, so boundary records are re-fetched
+// and deduplicated on keyFn, with the fresh record replacing the cached one.
+// If there is no usable cache, or no field to fetch beyond, it performs a full fetch.
+// Once merged, the cache and the advanced watermark are written.
+func IncrementalFetchSocrata[T any](cfg SocrataConfig, appToken string, keyFn func(T) string) ([]T, error) {
+	return IncrementalFetchSocrataContext(context.Background(), cfg, appToken, keyFn)
+}
+
+// IncrementalFetchSocrataContext is IncrementalFetchSocrata, fetching within ctx, as FetchSocrataContext does.
+// An aborted fetch leaves the cache and watermark as they were.
+func IncrementalFetchSocrataContext[T any](ctx context.Context, cfg SocrataConfig, appToken string, keyFn func(T) string) ([]T, error) {
+	deltaField := cmp.Or(cfg.DeltaField, cfg.OrderBy)
+	if deltaField == "" {
+		return FetchSocrataContext[T](ctx, cfg, appToken, AlwaysFetch)
+	}
+	deltaFn := fieldValueFn[T](deltaField)
+	if deltaFn == nil {
+		deltaFn = keyFn
+	}
+
+	cached, _, err := loadCache[T](cfg, AnyCacheAge)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a cache to merge into, the watermark is moot and everything is fetched
+	watermark := ""
+	if len(cached) > 0 {
+		if w, err := ReadWatermark(cfg.CacheName()); err == nil && w != nil && w.OrderBy == deltaField {
+			watermark = w.Value
+		} else {
+			watermark = maxKey(cached, deltaFn)
+		}
+	}
+
+	where := ""
+	if watermark != "" {
+		where = fmt.Sprintf("%s >= '%s'", deltaField, SQLString(watermark))
+	}
+	fresh, err := fetchSocrataPages[T](ctx, cfg, appToken, where)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeByKey(cached, fresh, keyFn)
+	writeSocrataCache(cfg, merged, "")
+	if err := WriteWatermark(cfg.CacheName(), Watermark{
+		OrderBy:   deltaField,
+		Value:     max(watermark, maxKey(fresh, deltaFn)),
+		FetchedAt: time.Now().UTC(),
+		Fetched:   len(fresh),
+		Records:   len(merged),
+	}); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// maxKey returns the greatest keyFn value of the items, or "" if there are none
+func maxKey[T any](items []T, keyFn func(T) string) string {
+	greatest := ""
+	for _, item := range items {
+		greatest = max(greatest, keyFn(item))
+	}
+	return greatest
+}
+
+// fieldValueFn returns a function of the value of a record's top-level field with the given
+// JSON name, as text, or nil if T has no such field
+func fieldValueFn[T any](jsonName string) func(T) string {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != jsonName || !field.IsExported() {
+			continue
+		}
+		return func(item T) string {
+			v := reflect.ValueOf(item).Field(i).Interface()
+			if text, ok := v.(encoding.TextMarshaler); ok {
+				if b, err := text.MarshalText(); err == nil {
+					return string(b)
+				}
+			}
+			return fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// mergeByKey appends fresh to existing, replacing any existing item with the same key.
+func mergeByKey[T any](existing, fresh []T, keyFn func(T) string) []T {
+	merged := make([]T, 0, len(existing)+len(fresh))
+	index := make(map[string]int, len(existing)+len(fresh))
+	for _, items := range [][]T{existing, fresh} {
+		for _, item := range items {
+			key := keyFn(item)
+			if i, ok := index[key]; ok {
+				merged[i] = item
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// fetchSocrataPages paginates through a Socrata endpoint, returning all records.
+// If where is non-empty, it is passed as the SoQL $where clause, along with any cfg.Where.
+func fetchSocrataPages[T any](ctx context.Context, cfg SocrataConfig, appToken string, where string) ([]T, error) {
+	var allItems []T
+	err := eachSocrataPage(ctx, cfg, appToken, where, func(batch []T) error {
+		allItems = append(allItems, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return allItems, nil
+}
 
+// fetchSocrataRawCached paginates through a Socrata endpoint, returning all records,
+// and caches the records of the response bodies as they were sent, spliced into one JSON array.
+// This skips encoding the records again, and keeps any fields the records do not decode.
+// Like writeSocrataCache, errors writing the cache are ignored.
+func fetchSocrataRawCached[T any](ctx context.Context, cfg SocrataConfig, appToken string) ([]T, error) {
+	var allItems []T
+	cache, _ := newCacheStream(cfg.CacheName(), cfg.CacheEvents) // nil if the cache cannot be written
+	err := eachSocrataPageRaw(ctx, cfg, appToken, "", true, func(batch []T, body []byte) error {
+		if cache != nil {
+			if err := cache.writeRaw(body, len(batch)); err != nil {
+				cache.abort()
+				cache = nil
+			}
+		}
+		allItems = append(allItems, batch...)
+		return nil
+	})
+	if cache != nil {
+		cache.etag = cfg.fetchedETag()
+		if err != nil || cache.commit() != nil {
+			cache.abort()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return allItems, nil
+}
+
+// eachSocrataPage paginates through a Socrata endpoint, calling fn with the records of each page.
+// Each response is decoded as it is read, so its raw body is never held in memory.
+// If where is non-empty, it is passed as the SoQL $where clause, along with any cfg.Where.
+// Returns the first error of a request or of fn, or ctx's error once it is done.
+func eachSocrataPage[T any](ctx context.Context, cfg SocrataConfig, appToken string, where string, fn func(batch []T) error) error {
+	return eachSocrataPageRaw(ctx, cfg, appToken, where, false, func(batch []T, _ []byte) error {
+		return fn(batch)
+	})
+}
+
+// eachSocrataPageRaw is eachSocrataPage, also passing fn each page's response body if keepBody
+// is true, in which case the body is read in full before it is decoded.  Otherwise body is nil.
+func eachSocrataPageRaw[T any](ctx context.Context, cfg SocrataConfig, appToken string, where string, keepBody bool, fn func(batch []T, body []byte) error) error {
 	// Parse the base URL
 	apiURL, err := url.Parse(cfg.URL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL: %w", err)
+		return fmt.Errorf("failed to parse URL: %w", err)
 	}
 
+	// Pages end when one is short, so never ask for more than the server will send
 	batchSize := cfg.BatchSize
 	if batchSize == 0 {
 		batchSize = 5000
 	}
+	maxBatchSize := cfg.MaxBatchSize
+	if maxBatchSize == 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	batchSize = min(batchSize, maxBatchSize)
 
-	client := &http.Client{}
-	var allItems []T
+	// Build the query parameters once; only $offset changes per page
+	query := apiURL.Query()
+	query.Set("$limit", strconv.Itoa(batchSize))
+	if cfg.OrderBy != "" {
+		query.Set("$order", cfg.OrderBy)
+	}
+	if len(cfg.Select) > 0 {
+		query.Set("$select", strings.Join(cfg.Select, ","))
+	}
+	switch {
+	case cfg.Where != "" && where != "":
+		query.Set("$where", "("+cfg.Where+") AND ("+where+")")
+	case cfg.Where != "":
+		query.Set("$where", cfg.Where)
+	case where != "":
+		query.Set("$where", where)
+	}
+	if appToken != "" {
+		query.Set("$$app_token", appToken)
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = DefaultHTTPClient()
+	}
+	budget := DefaultRetryBudget
 	offset := 0
+	retries := 0 // retries of this fetch so far, across its pages
 
-	// Paginate through results
+	// Paginate through results, retrying failed pages while the budget allows
 	for {
-		req, err := http.NewRequest("GET", apiURL.String(), nil)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		query.Set("$offset", strconv.Itoa(offset))
+		apiURL.RawQuery = query.Encode()
+		start := time.Now()
+		page := PageStat{Offset: offset}
+		batch, rawBody, skipped, err := requestSocrataPage[T](ctx, client, cfg, apiURL.String(), keepBody, &page)
+		for err != nil && ctx.Err() == nil && isRetryable(err) && cfg.allowRetry(budget, page.Retries, retries) {
+			retries++
+			page.Retries++
+			if err := sleepContext(ctx, cfg.retryDelay(budget, page.Retries, err)); err != nil {
+				break // the last attempt's error is returned, noting ctx's
+			}
+			batch, rawBody, skipped, err = requestSocrataPage[T](ctx, client, cfg, apiURL.String(), keepBody, &page)
+		}
+		cfg.recordPage(page, start)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+			if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+				err = fmt.Errorf("%w: %w", ctxErr, err)
+			}
+			if page.Retries > 0 {
+				return &RetryError{Attempts: page.Retries + 1, StatusCode: page.StatusCode, Err: err}
+			}
+			return err
 		}
-
-		// Build query parameters
-		q := req.URL.Query()
-		q.Add("$limit", fmt.Sprintf("%d", batchSize))
-		q.Add("$offset", fmt.Sprintf("%d", offset))
-		if cfg.OrderBy != "" {
-			q.Add("$order", cfg.OrderBy)
+		if len(skipped) > 0 {
+			cfg.BadRecords.Add(skipped...)
 		}
-		if appToken != "" {
-			q.Add("$$app_token", appToken)
+		if err := fn(batch, rawBody); err != nil {
+			return err
 		}
-		req.URL.RawQuery = q.Encode()
 
-		// Make the request
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		// Check if we've fetched all records, counting the skipped ones the server sent
+		if len(batch)+len(skipped) < batchSize {
+			break
 		}
+		offset += batchSize
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("HTTP %d %s %s", resp.StatusCode, resp.Status, string(body))
-		}
+	return nil
+}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
+// requestSocrataPage makes one attempt at requesting a page, returning its records, and its
+// response body if keepBody is true.  The rows, bytes, and status of the attempt are set in page.
+// If cfg has BadRecords, records that do not decode are skipped, and returned as BadRecords.
+// The request is pinned to cfg.Revision, if any, and its provenance recorded in cfg.Provenance.
+// It first waits out any backoff and its Limiter.  The attempt fails if it takes longer than
+// DefaultPageTimeout, not counting these waits, or once ctx is done.
+func requestSocrataPage[T any](ctx context.Context, client *http.Client, cfg SocrataConfig, pageURL string, keepBody bool, page *PageStat) ([]T, []byte, []BadRecord, error) {
+	*page = PageStat{Offset: page.Offset, Retries: page.Retries}
 
-		// Unmarshal batch
-		var batch []T
-		if err := json.Unmarshal(body, &batch); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	// Back off if this or any other fetch was recently throttled
+	rc := DefaultRateController
+	if rc != nil {
+		if err := rc.Wait(ctx); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	limiter := cfg.Limiter
+	if limiter == nil {
+		limiter = DefaultLimiter
+	}
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, nil, nil, err
 		}
+	}
 
-		allItems = append(allItems, batch...)
+	if timeout := DefaultPageTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errPageTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if cfg.Revision != "" {
+		req.Header.Set("If-Match", cfg.Revision)
+	}
+	if cfg.ifNoneMatch != "" && page.Offset == 0 {
+		req.Header.Set("If-None-Match", cfg.ifNoneMatch)
+	}
 
-		// Check if we've fetched all records
-		if len(batch) < batchSize {
-			break
+	// Make the request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("HTTP request failed: %w", pageTimeoutError(ctx, err))
+	}
+	defer resp.Body.Close()
+	page.StatusCode = resp.StatusCode
+	body := &countingReader{r: resp.Body}
+
+	if resp.StatusCode == http.StatusNotModified && cfg.ifNoneMatch != "" {
+		if cfg.Provenance != nil {
+			cfg.Provenance.observe(cfg.URL, cfg.Revision, resp.Header)
 		}
-		offset += batchSize
+		return nil, nil, nil, errNotModified
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed && cfg.Revision != "" {
+		return nil, nil, nil, &ValidationError{Msg: fmt.Sprintf("dataset %s has been republished since revision %s", cfg.URL, cfg.Revision)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		if rc != nil && resp.StatusCode == http.StatusTooManyRequests {
+			rc.Throttled(retryAfter(resp))
+		}
+		errBody, _ := io.ReadAll(body)
+		page.Bytes = body.n
+		return nil, nil, nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(errBody), RetryAfter: retryAfter(resp)}
+	}
+	if rc != nil {
+		rc.Succeeded()
+	}
+	if cfg.Provenance != nil {
+		cfg.Provenance.observe(cfg.URL, cfg.Revision, resp.Header)
+	}
+	if cfg.etags != nil {
+		cfg.etags.observe(cfg.URL, cfg.Revision, resp.Header)
 	}
 
-	// Cache the combined result
-	if cacheFile, err := MakeCacheFile(cfg.CacheFilename); err == nil {
-		if cacheBytes, err := json.Marshal(allItems); err == nil {
-			cacheFile.Write(cacheBytes)
+	// Unmarshal batch
+	var batch []T
+	var rawBody []byte
+	var skipped []BadRecord
+	if keepBody {
+		if rawBody, err = io.ReadAll(body); err == nil {
+			batch, skipped, err = decodeRecords[T](bytes.NewReader(rawBody), page.Offset, cfg.BadRecords != nil)
 		}
-		cacheFile.Close()
+	} else {
+		batch, skipped, err = decodeRecords[T](body, page.Offset, cfg.BadRecords != nil)
+	}
+	page.Bytes = body.n
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to unmarshal result: %w", pageTimeoutError(ctx, err))
+	}
+	page.Rows = len(batch)
+	return batch, rawBody, skipped, nil
+}
+
+// errPageTimeout is the cause of a page's context running out after DefaultPageTimeout
+var errPageTimeout = errors.New("page timeout")
+
+// pageTimeoutError returns err, noting DefaultPageTimeout if the page's context ran out.
+// Timed out pages are network errors, so may be retried.  Pages whose context ran out
+// because the fetch's did are left to eachSocrataPageRaw to report.
+func pageTimeoutError(ctx context.Context, err error) error {
+	if context.Cause(ctx) == errPageTimeout {
+		return fmt.Errorf("page timed out after %s: %w", DefaultPageTimeout, context.DeadlineExceeded)
 	}
+	return err
+}
 
-	return allItems, nil
+// recordPage adds a page's stats to cfg.Stats, if it has one, timing the page from start
+func (cfg SocrataConfig) recordPage(page PageStat, start time.Time) {
+	if cfg.Stats != nil {
+		page.Duration = time.Since(start)
+		cfg.Stats.Record(page)
+	}
+}
+
+// writeSocrataCache caches the combined result of cfg's fetch, with the ETag they were fetched with,
+// if any, ignoring any errors.  Like WriteCacheFile, an unchanged cache is not rewritten, which is
+// recorded in cfg.CacheEvents.
+func writeSocrataCache[T any](cfg SocrataConfig, items []T, etag string) {
+	if cacheBytes, err := json.Marshal(items); err == nil {
+		writeCacheFile(cfg.CacheName(), cacheBytes, etag, cfg.CacheEvents)
+	}
 }
@@ -0,0 +1,460 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRecord is a record of the datasets served by newSocrataServer
+type testRecord struct {
+	ID   string `json:"id"`
+	Week string `json:"week"`
+}
+
+// socrataServer serves a dataset of testRecords as a Socrata endpoint would, paging it by
+// $offset and $limit, and recording the query of each request
+type socrataServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	records []testRecord
+	cap     int          // most records sent per page, whatever the $limit; 0 for no cap
+	queries []url.Values // query of each request, in order
+}
+
+// newSocrataServer starts a server of records, closed when the test ends
+func newSocrataServer(t testing.TB, records []testRecord) *socrataServer {
+	s := &socrataServer{records: records}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve responds with the page of records the request asks for.  A $where of the form
+// "week >= 'value'" is applied, as incremental fetches send.
+func (s *socrataServer) serve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	records := s.records
+	limit := s.cap
+	s.mu.Unlock()
+
+	if _, value, ok := strings.Cut(query.Get("$where"), "week >= "); ok {
+		watermark := strings.Trim(value, "'")
+		records = slices.DeleteFunc(slices.Clone(records), func(rec testRecord) bool { return rec.Week < watermark })
+	}
+	offset, _ := strconv.Atoi(query.Get("$offset"))
+	if n, err := strconv.Atoi(query.Get("$limit")); err == nil && (limit == 0 || n < limit) {
+		limit = n
+	}
+	page := []testRecord{}
+	if offset < len(records) {
+		page = records[offset:min(offset+limit, len(records))]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// requests returns the queries of the requests served so far
+func (s *socrataServer) requests() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.queries)
+}
+
+// testRecords returns n records with IDs "0" onwards, and weeks "2024-000" onwards
+func testRecords(n int) []testRecord {
+	records := make([]testRecord, n)
+	for i := range records {
+		records[i] = testRecord{ID: strconv.Itoa(i), Week: "2024-" + leftPad(strconv.Itoa(i), 3)}
+	}
+	return records
+}
+
+// leftPad pads s with zeros to width digits, so weeks sort lexically
+func leftPad(s string, width int) string {
+	return strings.Repeat("0", max(0, width-len(s))) + s
+}
+
+// useTestDankRoot has the test cache into a temporary directory
+func useTestDankRoot(t testing.TB) {
+	root := GetDankRoot()
+	SetDankRoot(t.TempDir())
+	t.Cleanup(func() { SetDankRoot(root) })
+}
+
+func TestMergeByKey(t *testing.T) {
+	key := func(rec testRecord) string { return rec.ID }
+	tests := []struct {
+		name            string
+		existing, fresh []testRecord
+		want            []testRecord
+	}{
+		{
+			name:     "new records are appended",
+			existing: []testRecord{{ID: "1", Week: "a"}, {ID: "2", Week: "b"}},
+			fresh:    []testRecord{{ID: "3", Week: "c"}},
+			want:     []testRecord{{ID: "1", Week: "a"}, {ID: "2", Week: "b"}, {ID: "3", Week: "c"}},
+		},
+		{
+			name:     "overlapping records are replaced in place, not duplicated",
+			existing: []testRecord{{ID: "1", Week: "a"}, {ID: "2", Week: "b"}},
+			fresh:    []testRecord{{ID: "2", Week: "B"}, {ID: "3", Week: "c"}},
+			want:     []testRecord{{ID: "1", Week: "a"}, {ID: "2", Week: "B"}, {ID: "3", Week: "c"}},
+		},
+		{
+			name:  "duplicates within a fetch collapse to the last",
+			fresh: []testRecord{{ID: "1", Week: "a"}, {ID: "1", Week: "b"}},
+			want:  []testRecord{{ID: "1", Week: "b"}},
+		},
+		{
+			name:     "no fresh records keeps the cache",
+			existing: []testRecord{{ID: "1", Week: "a"}},
+			want:     []testRecord{{ID: "1", Week: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeByKey(tt.existing, tt.fresh, key); !slices.Equal(got, tt.want) {
+				t.Errorf("mergeByKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncrementalFetchSocrataMerges(t *testing.T) {
+	useTestDankRoot(t)
+	server := newSocrataServer(t, testRecords(5))
+	cfg := SocrataConfig{URL: server.URL, CacheFilename: "incremental.json", OrderBy: "week", BatchSize: 2}
+	key := func(rec testRecord) string { return rec.ID }
+
+	// Without a cache, everything is fetched
+	got, err := IncrementalFetchSocrata(cfg, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("first fetch got %d records, want 5", len(got))
+	}
+
+	// Two new records are merged, and the boundary record fetched again is not duplicated
+	server.mu.Lock()
+	server.records = testRecords(7)
+	server.queries = nil
+	server.mu.Unlock()
+	got, err = IncrementalFetchSocrata(cfg, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testRecords(7); !slices.Equal(got, want) {
+		t.Errorf("merged records = %v, want %v", got, want)
+	}
+	if where := server.requests()[0].Get("$where"); where != "week >= '2024-004'" {
+		t.Errorf("incremental $where = %q, want the window from the cached watermark", where)
+	}
+
+	// The merged cache is what the next fetch starts from
+	w, err := ReadWatermark(cfg.CacheName())
+	if err != nil || w == nil {
+		t.Fatalf("ReadWatermark() = %v, %v", w, err)
+	}
+	if w.Value != "2024-006" || w.Records != 7 || w.Fetched != 3 {
+		t.Errorf("watermark = %+v, want value 2024-006 of 7 records, 3 fetched", *w)
+	}
+	cached, err := LoadCacheFile[testRecord](cfg.CacheName(), AnyCacheAge, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 7 {
+		t.Errorf("cache holds %d records, want 7", len(cached))
+	}
+}
+
+func TestFetchSocrataClampsBatchSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		cap       int // server's most records per page
+		batchSize int
+		maxBatch  int
+		wantLimit string
+		wantPages int
+	}{
+		{name: "batch clamped to the server's cap", cap: 10, batchSize: 1000, maxBatch: 10, wantLimit: "10", wantPages: 4},
+		{name: "batch under the cap is kept", cap: 10, batchSize: 8, maxBatch: 10, wantLimit: "8", wantPages: 5},
+		{name: "batch clamped to DefaultMaxBatchSize", batchSize: DefaultMaxBatchSize + 1, wantLimit: strconv.Itoa(DefaultMaxBatchSize), wantPages: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			server := newSocrataServer(t, testRecords(35))
+			server.cap = tt.cap
+			cfg := SocrataConfig{URL: server.URL, CacheFilename: "capped.json", OrderBy: "week", BatchSize: tt.batchSize, MaxBatchSize: tt.maxBatch}
+			records, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testRecords(35); !slices.Equal(records, want) {
+				t.Errorf("fetched %d records, want all %d", len(records), len(want))
+			}
+			requests := server.requests()
+			if len(requests) != tt.wantPages {
+				t.Errorf("fetched %d pages, want %d", len(requests), tt.wantPages)
+			}
+			for _, query := range requests {
+				if limit := query.Get("$limit"); limit != tt.wantLimit {
+					t.Errorf("$limit = %s, want %s", limit, tt.wantLimit)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamSocrataMatchesFetch(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawCache bool
+	}{
+		{name: "decoded cache"},
+		{name: "raw cache", rawCache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			server := newSocrataServer(t, testRecords(35))
+			fetchCfg := SocrataConfig{URL: server.URL, CacheFilename: "fetched.json", OrderBy: "week", BatchSize: 10, RawCache: tt.rawCache}
+			fetched, err := FetchSocrata[testRecord](fetchCfg, "", AlwaysFetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			streamCfg := fetchCfg
+			streamCfg.CacheFilename = "streamed.json"
+			batches := make(chan []testRecord)
+			streamErr := make(chan error, 1)
+			go func() { streamErr <- StreamSocrata(streamCfg, "", AlwaysFetch, batches) }()
+			var streamed []testRecord
+			pages := 0
+			for batch := range batches {
+				streamed = append(streamed, batch...)
+				pages++
+			}
+			if err := <-streamErr; err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(streamed, fetched) {
+				t.Errorf("streamed %v, want the fetched %v", streamed, fetched)
+			}
+			if pages != 4 {
+				t.Errorf("streamed %d batches, want one for each of the 4 pages", pages)
+			}
+
+			// Both leave the same cache, which either later loads
+			fetchedCache, err := os.ReadFile(GetDankCachePathname(fetchCfg.CacheFilename + CacheCompressedSuffix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			streamedCache, err := os.ReadFile(GetDankCachePathname(streamCfg.CacheFilename + CacheCompressedSuffix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(streamedCache, fetchedCache) {
+				t.Error("streamed cache differs from the fetched cache")
+			}
+			cached, err := LoadCacheFile[testRecord](streamCfg.CacheFilename, AnyCacheAge, nil)
+			if err != nil || !slices.Equal(cached, fetched) {
+				t.Errorf("streamed cache loads %d records, %v, want the %d fetched", len(cached), err, len(fetched))
+			}
+		})
+	}
+}
+
+func TestFetchSocrataSendsSelect(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{name: "no select fetches every field", want: ""},
+		{name: "one field", fields: []string{"id"}, want: "id"},
+		{name: "fields in config order", fields: []string{"week", "id"}, want: "week,id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDankRoot(t)
+			server := newSocrataServer(t, testRecords(3))
+			cfg := SocrataConfig{URL: server.URL, CacheFilename: "select.json", OrderBy: "week", Select: tt.fields}
+			if _, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch); err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range server.requests() {
+				if got := query.Get("$select"); got != tt.want {
+					t.Errorf("$select = %q, want %q", got, tt.want)
+				}
+				if _, ok := query["$select"]; ok != (tt.want != "") {
+					t.Errorf("$select sent = %v, want %v", ok, tt.want != "")
+				}
+			}
+			if tt.fields != nil && cfg.CacheName() == (SocrataConfig{CacheFilename: cfg.CacheFilename}).CacheName() {
+				t.Errorf("CacheName() = %s, want a cache apart from that of every field", cfg.CacheName())
+			}
+		})
+	}
+}
+
+func BenchmarkFetchSocrata(b *testing.B) {
+	useTestDankRoot(b)
+	server := newSocrataServer(b, testRecords(20000))
+	cfg := SocrataConfig{URL: server.URL, CacheFilename: "bench.json", OrderBy: "week"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		records, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(records) != 20000 {
+			b.Fatalf("fetched %d records, want 20000", len(records))
+		}
+	}
+}
+
+// TestConcurrentFetchAndCache fetches several datasets at once, as --parallel does, while
+// the root is read and set and the caches read, for the race detector: go test -race
+func TestThrottledFetchSlowsNextDataset(t *testing.T) {
+	useTestDankRoot(t)
+	const backoff = 300 * time.Millisecond
+	rc := DefaultRateController
+	DefaultRateController = &RateController{BaseDelay: backoff, MaxDelay: time.Minute}
+	t.Cleanup(func() { DefaultRateController = rc })
+
+	// One dataset's endpoint is throttled, the other's is not
+	var throttledAt time.Time
+	throttled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		throttledAt = time.Now()
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer throttled.Close()
+	other := newSocrataServer(t, testRecords(3))
+	var firstRequestAt time.Time
+	timed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if firstRequestAt.IsZero() {
+			firstRequestAt = time.Now()
+		}
+		other.serve(w, r)
+	}))
+	defer timed.Close()
+
+	// Without a 429, the dataset is fetched straight away
+	start := time.Now()
+	if _, err := FetchSocrata[testRecord](SocrataConfig{URL: timed.URL, CacheFilename: "first.json", OrderBy: "week"}, "", AlwaysFetch); err != nil {
+		t.Fatal(err)
+	}
+	if wait := firstRequestAt.Sub(start); wait >= backoff {
+		t.Errorf("unthrottled fetch waited %v before its first request, want no backoff", wait)
+	}
+
+	_, err := FetchSocrata[testRecord](SocrataConfig{URL: throttled.URL, CacheFilename: "throttled.json", OrderBy: "week"}, "", AlwaysFetch)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("throttled fetch = %v, want its HTTP 429", err)
+	}
+
+	// The next dataset, from another endpoint, waits out the backoff of the 429
+	firstRequestAt = time.Time{}
+	records, err := FetchSocrata[testRecord](SocrataConfig{URL: timed.URL, CacheFilename: "next.json", OrderBy: "week"}, "", AlwaysFetch)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("FetchSocrata() = %d records, %v, want 3", len(records), err)
+	}
+	if wait := firstRequestAt.Sub(throttledAt); wait < backoff {
+		t.Errorf("next dataset's first request came %v after the 429, want at least the %v backoff", wait, backoff)
+	}
+}
+
+func TestSocrataConfigCacheName(t *testing.T) {
+	selectHash := "_select_" + shortHash("week,total")
+	whereHash := "_where_" + shortHash("week > '2025'")
+	tests := []struct {
+		cfg  SocrataConfig
+		want string
+	}{
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax.json"}, want: "us_ct_tax.json"},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax"}, want: "us_ct_tax"},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax.json", Select: []string{"week", "total"}}, want: "us_ct_tax" + selectHash + ".json"},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax.json", Where: "week > '2025'"}, want: "us_ct_tax" + whereHash + ".json"},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax.json.bak", Select: []string{"week", "total"}}, want: "us_ct_tax" + selectHash + ".json.bak"},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax", Select: []string{"week", "total"}}, want: "us_ct_tax" + selectHash},
+		{cfg: SocrataConfig{CacheFilename: "us_ct_tax", Select: []string{"week", "total"}, Where: "week > '2025'"}, want: "us_ct_tax" + selectHash + whereHash},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.CacheName(); got != tt.want {
+			t.Errorf("CacheName() of %s, select %v, where %q = %s, want %s", tt.cfg.CacheFilename, tt.cfg.Select, tt.cfg.Where, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentFetchAndCache(t *testing.T) {
+	useTestDankRoot(t)
+	root := GetDankRoot()
+	server := newSocrataServer(t, testRecords(25))
+
+	const fetchers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, fetchers)
+	for i := 0; i < fetchers; i++ {
+		cfg := SocrataConfig{URL: server.URL, CacheFilename: "concurrent_" + strconv.Itoa(i%4) + ".json", OrderBy: "week", BatchSize: 10}
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for range 3 {
+				records, err := FetchSocrata[testRecord](cfg, "", AlwaysFetch)
+				if err == nil && len(records) != 25 {
+					err = fmt.Errorf("fetched %d records, want 25", len(records))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				SetDankRoot(root)
+				_ = GetDankCachePathname(cfg.CacheName())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				CacheStatus(cfg.CacheName(), AnyCacheAge)
+				LoadCacheFile[testRecord](cfg.CacheName(), AnyCacheAge, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		cached, err := LoadCacheFile[testRecord]("concurrent_"+strconv.Itoa(i)+".json", AnyCacheAge, nil)
+		if err != nil || len(cached) != 25 {
+			t.Errorf("cache %d = %d records, %v; want 25", i, len(cached), err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// PageStat records the request of one page of a Socrata fetch
+type PageStat struct {
+	Offset     int           // $offset of the page
+	Rows       int           // Number of records the page held; 0 if the request failed
+	Bytes      int64         // Size of the last response body as read
+	Duration   time.Duration // Time from the first request to decoding the last response, including retries
+	StatusCode int           // HTTP status of the last response; 0 if there was none
+	Retries    int           // Number of times the page was retried, per DefaultRetryBudget
+}
+
+// FetchStats collects a PageStat for each page requested by the fetches of a SocrataConfig with it
+// as its Stats, in the order they were requested.  Its methods are safe for concurrent use.
+type FetchStats struct {
+	mu    sync.Mutex
+	pages []PageStat
+}
+
+// Record adds a page's stats
+func (s *FetchStats) Record(page PageStat) {
+	s.mu.Lock()
+	s.pages = append(s.pages, page)
+	s.mu.Unlock()
+}
+
+// Pages returns the stats of every page recorded so far
+func (s *FetchStats) Pages() []PageStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := make([]PageStat, len(s.pages))
+	copy(pages, s.pages)
+	return pages
+}
+
+// CacheOutcome is something a fetch did with its cache, other than read or replace it as usual
+type CacheOutcome int
+
+const (
+	CacheKept        CacheOutcome = iota // CacheKept is a cache not rewritten, as it already held the fetched records.
+	CacheDiscarded                       // CacheDiscarded is an unreadable cache, fetched again rather than used.
+	CacheRevalidated                     // CacheRevalidated is a cache too old to use, used as the dataset is unchanged upstream.
+)
+
+// CacheEvent records a CacheOutcome of a fetch's cache
+type CacheEvent struct {
+	Filename string       // Name of the cache file, as given to GetDankCachePathname
+	Outcome  CacheOutcome // What was done with the cache
+	Err      error        // Why, for CacheDiscarded, the *CacheError of reading the cache
+}
+
+// CacheEvents collects a CacheEvent for each CacheOutcome of the fetches of a SocrataConfig with it
+// as its CacheEvents, in the order they happened, so the caller can report them as it likes, e.g.
+// logging them.  Its methods are safe for concurrent use.
+type CacheEvents struct {
+	mu     sync.Mutex
+	events []CacheEvent
+}
+
+// Record adds an event
+func (e *CacheEvents) Record(event CacheEvent) {
+	e.mu.Lock()
+	e.events = append(e.events, event)
+	e.mu.Unlock()
+}
+
+// Events returns every event recorded so far
+func (e *CacheEvents) Events() []CacheEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := make([]CacheEvent, len(e.events))
+	copy(events, e.events)
+	return events
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader, adding to the count
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
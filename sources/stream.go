@@ -0,0 +1,219 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StreamSocrata fetches data from a Socrata API endpoint like FetchSocrata, but sends the
+// records of each page to batches as soon as it is decoded, rather than returning them all at once.
+// Responses are decoded as they are read and the cache file is written as pages arrive,
+// so neither the raw responses nor the encoded cache are held in memory; with cfg.RawCache,
+// each response is held only while its records are cached as they were sent.
+// If the cache is fresh enough, its records are sent as a single batch without fetching.
+// The receiver must drain batches, which is closed on return.  The cache is only replaced
+// once every page has been fetched, and like FetchSocrata, errors writing it are ignored.
+func StreamSocrata[T any](cfg SocrataConfig, appToken string, maxCacheAge time.Duration, batches chan<- []T) error {
+	return StreamSocrataContext(context.Background(), cfg, appToken, maxCacheAge, batches)
+}
+
+// StreamSocrataContext is StreamSocrata, aborting the fetch once ctx is done, even mid-page or
+// while waiting on the receiver; the cache is then left untouched and ctx's error returned.
+func StreamSocrataContext[T any](ctx context.Context, cfg SocrataConfig, appToken string, maxCacheAge time.Duration, batches chan<- []T) error {
+	defer close(batches)
+
+	if cached, ok, err := loadCache[T](cfg, maxCacheAge); err != nil {
+		return err
+	} else if ok {
+		return sendBatch(ctx, batches, cached)
+	}
+
+	// Like FetchSocrata, an expired cache is revalidated, and sent as it is if the dataset is unchanged
+	cfg = cfg.revalidating(maxCacheAge)
+	err := streamSocrataPages(ctx, cfg, appToken, batches)
+	if errors.Is(err, errNotModified) {
+		if cached, ok, err := loadRevalidatedCache[T](cfg); err != nil {
+			return err
+		} else if ok {
+			return sendBatch(ctx, batches, cached)
+		}
+		cfg.ifNoneMatch = "" // the cache cannot be used after all, so fetch it in full
+		err = streamSocrataPages(ctx, cfg, appToken, batches)
+	}
+	return err
+}
+
+// sendBatch sends batch to batches, unless ctx is done first, returning ctx's error
+func sendBatch[T any](ctx context.Context, batches chan<- []T, batch []T) error {
+	select {
+	case batches <- batch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// streamSocrataPages fetches every page, sending its records to batches and writing them to
+// the cache, as StreamSocrataContext does without a usable cache, but without closing batches
+func streamSocrataPages[T any](ctx context.Context, cfg SocrataConfig, appToken string, batches chan<- []T) error {
+	cache, _ := newCacheStream(cfg.CacheName(), cfg.CacheEvents) // nil if the cache cannot be written
+	err := eachSocrataPageRaw(ctx, cfg, appToken, "", cfg.RawCache, func(batch []T, body []byte) error {
+		if cache != nil {
+			var writeErr error
+			if cfg.RawCache {
+				writeErr = cache.writeRaw(body, len(batch))
+			} else {
+				writeErr = writeCacheStream(cache, batch)
+			}
+			if writeErr != nil {
+				cache.abort()
+				cache = nil
+			}
+		}
+		return sendBatch(ctx, batches, batch)
+	})
+	if cache != nil {
+		cache.etag = cfg.fetchedETag()
+		if err != nil || cache.commit() != nil {
+			cache.abort()
+		}
+	}
+	return err
+}
+
+// cacheStream writes a cache file as a compact JSON array, a batch at a time, zstd-compressed
+// like MakeCacheFile.  It writes to a temporary file in the cache directory, which commit renames
+// into place, so a failed fetch leaves any previous cache file untouched.  Like WriteCacheFile,
+// a cache that would be replaced by the same bytes is kept as it is.
+type cacheStream struct {
+	filename string // cache filename, as given to GetDankCachePathname
+	file     *os.File
+	encoder  io.WriteCloser // compresses into file
+	w        *bufio.Writer
+	hash     hash.Hash      // SHA-256 of everything written, before compression
+	size     countingWriter // number of bytes written, before compression
+	count    int            // number of items written so far
+	etag     string         // ETag of the fetch of the items, recorded once committed, if any
+	events   *CacheEvents   // records the cache being kept unchanged, if set
+}
+
+// newCacheStream begins writing the named cache file, recording in events, if set, if it is kept unchanged
+func newCacheStream(filename string, events *CacheEvents) (*cacheStream, error) {
+	cacheDir := filepath.Dir(GetDankCachePathname(filename))
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	file, err := os.CreateTemp(cacheDir, ".stream-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	encoder, err := CodecZstd.newWriter(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	c := &cacheStream{filename: filename, file: file, encoder: encoder, hash: sha256.New(), events: events}
+	c.w = bufio.NewWriter(io.MultiWriter(encoder, c.hash, &c.size))
+	c.w.WriteString("[")
+	return c, nil
+}
+
+// writeCacheStream appends items to the cache's JSON array, encoded as json.Marshal would the whole slice
+func writeCacheStream[T any](c *cacheStream, items []T) error {
+	for _, item := range items {
+		itemBytes, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to encode cache item %d: %w", c.count, err)
+		}
+		if c.count > 0 {
+			c.w.WriteString(",")
+		}
+		if _, err := c.w.Write(itemBytes); err != nil {
+			return fmt.Errorf("failed to write cache file: %w", err)
+		}
+		c.count++
+	}
+	return nil
+}
+
+// writeRaw appends the records of a response body, a JSON array of the given number of records,
+// to the cache's JSON array as they are, without decoding them
+func (c *cacheStream) writeRaw(body []byte, records int) error {
+	inner := bytes.TrimSpace(body)
+	if len(inner) < 2 || inner[0] != '[' || inner[len(inner)-1] != ']' {
+		return fmt.Errorf("failed to write cache file: response body is not a JSON array")
+	}
+	inner = bytes.TrimSpace(inner[1 : len(inner)-1])
+	if records == 0 || len(inner) == 0 {
+		return nil
+	}
+	if c.count > 0 {
+		c.w.WriteString(",")
+	}
+	if _, err := c.w.Write(inner); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	c.count += records
+	return nil
+}
+
+// commit ends the JSON array and moves the cache file into place, with its version sidecar
+func (c *cacheStream) commit() error {
+	c.w.WriteString("]")
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := c.encoder.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if cacheUnchanged(c.filename, [sha256.Size]byte(c.hash.Sum(nil)), c.size.n) {
+		os.Remove(c.file.Name())
+		keepUnchangedCache(c.filename, c.events)
+		if c.etag != "" {
+			return writeCacheETag(c.filename, c.etag)
+		}
+		return nil
+	}
+	if err := os.Rename(c.file.Name(), GetDankCachePathname(c.filename+CacheCompressedSuffix)); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	removeLegacyCache(c.filename)
+	if err := writeCacheVersion(c.filename); err != nil {
+		return err
+	}
+	return replaceCacheETag(c.filename, c.etag)
+}
+
+// abort discards the temporary cache file, which is harmless once committed
+func (c *cacheStream) abort() {
+	c.encoder.Close()
+	c.file.Close()
+	os.Remove(c.file.Name())
+}
+
+// countingWriter counts the bytes written to it, discarding them
+type countingWriter struct {
+	n int64
+}
+
+// Write counts p
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// socrataTimeLayouts are the layouts ParseSocrataTime tries, in order.
+// Fractional seconds are accepted after the seconds of any layout that has them.
+var socrataTimeLayouts = []string{
+	"2006-01-02T15:04:05",      // Floating timestamp, e.g. "2024-01-05T00:00:00.000"
+	time.RFC3339,               // With an offset, e.g. "2024-01-05T00:00:00Z" or "2024-01-05T00:00:00.000-05:00"
+	"2006-01-02T15:04:05Z0700", // With an offset without a colon, e.g. "2024-01-05T00:00:00-0500", as in CSV exports
+	"2006-01-02 15:04:05",      // With a space, e.g. "2024-01-05 00:00:00"
+	"2006-01-02",               // Date only, e.g. "2024-01-05"
+	"01/02/2006",               // US date, e.g. "01/05/2024"
+}
+
+// ParseSocrataTime parses a date or datetime as emitted by Socrata portals, which claim
+// ISO 8601 but vary between floating timestamps, timestamps with offsets, and plain dates.
+// Times without an offset are in UTC.  Surrounding whitespace is ignored.
+// Returns an error listing the layouts tried if none of them match.
+func ParseSocrataTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+	for _, layout := range socrataTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q (tried layouts %s)", s, strings.Join(socrataTimeLayouts, ", "))
+}
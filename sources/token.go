@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// TokenEnvPrefix prefixes the environment variables holding per-source app tokens, e.g. DANK_TOKEN_CT
+const TokenEnvPrefix = "DANK_TOKEN_"
+
+// tokenSourceRegex matches the source name of a "<source>=<token>" value
+var tokenSourceRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// AppTokens holds the app tokens for the data portals of each source, e.g. "ct" for data.ct.gov
+type AppTokens struct {
+	Default  string            // Token for sources without their own, or "" for none
+	BySource map[string]string // Tokens by lower-case source name
+}
+
+// ParseAppTokens parses token values, each either "<source>=<token>" for that source,
+// or a bare token used as the default.  Returns an error if the default or a source is given twice.
+func ParseAppTokens(values []string) (AppTokens, error) {
+	tokens := AppTokens{BySource: make(map[string]string)}
+	for _, value := range values {
+		source, token, found := strings.Cut(value, "=")
+		if !found || !tokenSourceRegex.MatchString(source) {
+			if tokens.Default != "" {
+				return AppTokens{}, fmt.Errorf("default token given more than once")
+			}
+			tokens.Default = value
+			continue
+		}
+		source = strings.ToLower(source)
+		if _, ok := tokens.BySource[source]; ok {
+			return AppTokens{}, fmt.Errorf("token for %q given more than once", source)
+		}
+		tokens.BySource[source] = token
+	}
+	return tokens, nil
+}
+
+// TokenForSource returns the app token for the named source, e.g. "ct".
+// In order of precedence, it is the source's own token, the TokenEnvPrefix environment
+// variable for the source (e.g. DANK_TOKEN_CT), or the default token.
+func (t AppTokens) TokenForSource(source string) string {
+	if token, ok := t.BySource[strings.ToLower(source)]; ok {
+		return token
+	}
+	if token := os.Getenv(TokenEnvPrefix + strings.ToUpper(source)); token != "" {
+		return token
+	}
+	return t.Default
+}
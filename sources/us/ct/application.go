@@ -8,7 +8,10 @@
 package ct
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -20,6 +23,9 @@ const (
 	ApplicationJSONFilename = "us_ct_applications.json"
 	ApplicationCSVFilename  = "us_ct_applications.csv"
 	ApplicationsURL         = "https://data.ct.gov/resource/bqby-dyzr.json"
+
+	// ApplicationDocumentSeparator joins multiple document URLs in CSV and DuckDB exports
+	ApplicationDocumentSeparator = "|"
 )
 
 // ApplicationDocument represents a document attached to an application
@@ -27,16 +33,65 @@ type ApplicationDocument struct {
 	URL string `json:"url"`
 }
 
+// ApplicationDocuments are the documents attached to an application.
+// Socrata emits either a single document object or an array of them.
+type ApplicationDocuments []ApplicationDocument
+
+// UnmarshalJSON accepts a single document object, an array of documents, or null.
+// Documents without a URL are dropped.
+func (d *ApplicationDocuments) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*d = nil
+		return nil
+	}
+
+	var docs []ApplicationDocument
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return err
+		}
+	} else {
+		var doc ApplicationDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+
+	*d = nil
+	for _, doc := range docs {
+		if doc.URL != "" {
+			*d = append(*d, doc)
+		}
+	}
+	return nil
+}
+
+// URLs returns the document URLs joined by ApplicationDocumentSeparator
+func (d ApplicationDocuments) URLs() string {
+	urls := make([]string, len(d))
+	for i, doc := range d {
+		urls[i] = doc.URL
+	}
+	return strings.Join(urls, ApplicationDocumentSeparator)
+}
+
+// String returns the document URLs, as URLs does
+func (d ApplicationDocuments) String() string {
+	return d.URLs()
+}
+
 // Application represents a CT cannabis license application
 type Application struct {
-	ApplicationLicenseNumber    string              `json:"application_license_number"`
-	ApplicationCredentialStatus string              `json:"application_credential_status"`
-	StatusReason                string              `json:"status_reason"`
-	SECReviewStatus             string              `json:"sec_review_status"`
-	InitialApplicationType      string              `json:"initial_application_type"`
-	HowSelected                 string              `json:"how_selected"`
-	Name                        string              `json:"name"`
-	Documents                   ApplicationDocument `json:"documents"`
+	ApplicationLicenseNumber    string               `json:"application_license_number"`
+	ApplicationCredentialStatus string               `json:"application_credential_status"`
+	StatusReason                string               `json:"status_reason"`
+	SECReviewStatus             string               `json:"sec_review_status"`
+	InitialApplicationType      string               `json:"initial_application_type"`
+	HowSelected                 string               `json:"how_selected"`
+	Name                        string               `json:"name"`
+	Documents                   ApplicationDocuments `json:"documents"`
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -45,11 +100,17 @@ type Application struct {
 var ApplicationConfig = sources.SocrataConfig{
 	URL:           ApplicationsURL,
 	CacheFilename: ApplicationJSONFilename,
+	OrderBy:       ":id", // the row ID, so that pages neither skip nor repeat rows
 }
 
 // FetchApplications fetches all CT cannabis application data from the CT API
 func FetchApplications(appToken string, maxCacheAge time.Duration) ([]Application, error) {
-	return sources.FetchSocrata[Application](ApplicationConfig, appToken, maxCacheAge)
+	return FetchApplicationsContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchApplicationsContext is FetchApplications, aborted once ctx is done, with opts applied to its config
+func FetchApplicationsContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]Application, error) {
+	return sources.FetchSocrataContext[Application](ctx, ApplicationConfig.With(opts...), appToken, maxCacheAge)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -62,17 +123,38 @@ func (a Application) CSVHeaders() string {
 
 // CSVValue returns the CSV value for the Application struct
 func (a Application) CSVValue() string {
-	return fmt.Sprintf(`"%s","%s","%s","%s","%s","%s","%s","%s"
-`,
-		CSVString(a.ApplicationLicenseNumber),
-		CSVString(a.ApplicationCredentialStatus),
-		CSVString(a.StatusReason),
-		CSVString(a.SECReviewStatus),
-		CSVString(a.InitialApplicationType),
-		CSVString(a.HowSelected),
-		CSVString(a.Name),
-		CSVString(a.Documents.URL),
-	)
+	var row sources.CSVRow
+	row.String(a.ApplicationLicenseNumber)
+	row.String(a.ApplicationCredentialStatus)
+	row.String(a.StatusReason)
+	row.String(a.SECReviewStatus)
+	row.String(a.InitialApplicationType)
+	row.String(a.HowSelected)
+	row.String(a.Name)
+	row.String(a.Documents.URLs())
+	return row.Line()
+}
+
+// Columns returns the CSV columns of the Application struct, for reading its CSV export back with sources.ReadCSV
+func (a Application) Columns() []sources.Column[Application] {
+	return []sources.Column[Application]{
+		sources.StringColumn("application_license_number", func(a *Application) *string { return &a.ApplicationLicenseNumber }),
+		sources.StringColumn("application_credential_status", func(a *Application) *string { return &a.ApplicationCredentialStatus }),
+		sources.StringColumn("status_reason", func(a *Application) *string { return &a.StatusReason }),
+		sources.StringColumn("sec_review_status", func(a *Application) *string { return &a.SECReviewStatus }),
+		sources.StringColumn("initial_application_type", func(a *Application) *string { return &a.InitialApplicationType }),
+		sources.StringColumn("how_selected", func(a *Application) *string { return &a.HowSelected }),
+		sources.StringColumn("name", func(a *Application) *string { return &a.Name }),
+		{Name: "documents_url", Set: func(a *Application, v string) error {
+			a.Documents = nil
+			for _, url := range strings.Split(v, ApplicationDocumentSeparator) {
+				if url != "" {
+					a.Documents = append(a.Documents, ApplicationDocument{URL: url})
+				}
+			}
+			return nil
+		}, Source: "documents.url"},
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -106,7 +188,7 @@ func DBInsertApplications(conn *sql.DB, applications []Application) error {
 			sources.SQLString(a.InitialApplicationType),
 			sources.SQLString(a.HowSelected),
 			sources.SQLString(a.Name),
-			sources.SQLString(a.Documents.URL)))
+			sources.SQLString(a.Documents.URLs())))
 	}
 
 	if _, err := conn.Exec(sb.String()); err != nil {
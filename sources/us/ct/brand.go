@@ -10,8 +10,13 @@
 package ct
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"slices"
 	"strings"
 	"time"
@@ -21,25 +26,63 @@ import (
 )
 
 const (
-	BrandJSONFilename = "us_ct_brands.json"
-	BrandCSVFilename  = "us_ct_brands.csv"
+	BrandJSONFilename        = "us_ct_brands.json"
+	BrandCSVFilename         = "us_ct_brands.csv"
+	BrandCleanReportFilename = "us_ct_brands_clean_report.csv"
 	// BrandsURL is the URL to fetch the CT cannabis brands data
 	BrandsURL = "https://data.ct.gov/resource/egd5-wb6r.json"
 )
 
+// Image is a linked document of a brand, such as its label image or lab analysis
 type Image struct {
 	URL         string `csv:"url" json:"url"`
 	Description string `csv:"desc" json:"description"`
 }
 
-// Brand represents a raw Cannabis Brand Record from CT
+// UnmarshalJSON accepts a document object, a bare URL string, an array of documents, or null.
+// Of an array, the first document with a URL is used, mirroring ApplicationDocuments.
+func (img *Image) UnmarshalJSON(data []byte) error {
+	type image Image // without this method, to unmarshal objects
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		*img = Image{}
+	case data[0] == '"':
+		*img = Image{}
+		return json.Unmarshal(data, &img.URL)
+	case data[0] == '[':
+		var images []image
+		if err := json.Unmarshal(data, &images); err != nil {
+			return err
+		}
+		*img = Image{}
+		for _, i := range images {
+			if i.URL != "" {
+				*img = Image(i)
+				break
+			}
+		}
+	default:
+		var i image
+		if err := json.Unmarshal(data, &i); err != nil {
+			return err
+		}
+		*img = Image(i)
+	}
+	return nil
+}
+
+// Brand represents a raw Cannabis Brand Record from CT.
+// Its exported columns are ordered by Brand.Columns, not by these fields, so they may be
+// reordered freely; each field must have a column there, placing it in the exports.
 type Brand struct {
 	BrandName                    string       `csv:"BRAND-NAME" json:"brand_name"`
+	CanonicalName                string       `csv:"-" json:"canonical_name,omitempty"` // BrandName normalized, if set with SetCanonicalBrandNames
 	DosageForm                   string       `csv:"DOSAGE-FORM" json:"dosage_form"`
 	BrandingEntity               string       `csv:"BRANDING-ENTITY" json:"branding_entity"`
 	ProductImage                 Image        `csv:"PRODUCT-IMAGE" json:"product_image"`
 	LabelImage                   Image        `csv:"LABEL-IMAGE" json:"label_image"`
-	LabAnalysis                  Image        `csv:"LAB-ANALYSIS" json:"lab_analysis"`
+	LabAnalysis                  Image        `csv:"LAB-ANALYSIS" json:"lab_analysis"` // Certificate of analysis (COA) lab report
 	ApprovalDate                 iso8601.Time `csv:"APPROVAL-DATE" json:"approval_date"`
 	RegistrationNumber           string       `csv:"REGISTRATION-NUMBER" json:"registration_number"`
 	TetrahydrocannabinolThc      Measure      `csv:"TETRAHYDROCANNABINOL-THC" json:"tetrahydrocannabinol_thc"`
@@ -100,6 +143,12 @@ type Brand struct {
 	NationalDrugCode             string       `csv:"National Drug Code" json:"national_drug_code"`
 }
 
+// Key returns the brand's natural key, its RegistrationNumber normalized with NormalizeLicenseNumber,
+// so that variants of a registration number key the same brand
+func (b Brand) Key() string {
+	return NormalizeLicenseNumber(b.RegistrationNumber)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 // BrandConfig is the Socrata configuration for fetching brands
@@ -107,11 +156,47 @@ var BrandConfig = sources.SocrataConfig{
 	URL:           BrandsURL,
 	CacheFilename: BrandJSONFilename,
 	OrderBy:       "registration_number",
+	RawCache:      true, // the largest dataset, so skip re-encoding it for the cache
 }
 
 // FetchBrands fetches all the CT cannabis brands data from the CT API
 func FetchBrands(appToken string, maxCacheAge time.Duration) ([]Brand, error) {
-	return sources.FetchSocrata[Brand](BrandConfig, appToken, maxCacheAge)
+	return FetchBrandsContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchBrandsContext is FetchBrands, aborted once ctx is done, with opts applied to BrandConfig,
+// e.g. sources.WithHTTPClient to fetch through a proxy.  See sources.FetchSocrataContext.
+func FetchBrandsContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]Brand, error) {
+	return sources.FetchSocrataContext[Brand](ctx, BrandConfig.With(opts...), appToken, maxCacheAge)
+}
+
+// FetchBrandsWith fetches all the CT cannabis brands data from the CT API,
+// applying transform to freshly fetched brands before they are cached.
+// For example, CleanBrandsTransform caches only the brands that pass CleanBrands.
+func FetchBrandsWith(appToken string, maxCacheAge time.Duration, transform sources.Transform[Brand]) ([]Brand, error) {
+	return FetchBrandsWithContext(context.Background(), appToken, maxCacheAge, transform)
+}
+
+// FetchBrandsWithContext is FetchBrandsWith, aborted once ctx is done, with opts applied to its config
+func FetchBrandsWithContext(ctx context.Context, appToken string, maxCacheAge time.Duration, transform sources.Transform[Brand], opts ...sources.ConfigOption) ([]Brand, error) {
+	return sources.FetchSocrataWithContext(ctx, BrandConfig.With(opts...), appToken, maxCacheAge, transform)
+}
+
+// CleanBrandsTransform is CleanBrands as a sources.Transform
+func CleanBrandsTransform(bs []Brand) ([]Brand, error) {
+	return CleanBrands(bs), nil
+}
+
+// StreamBrands fetches all the CT cannabis brands data from the CT API,
+// sending each page of brands to batches as it arrives.  See sources.StreamSocrata.
+func StreamBrands(appToken string, maxCacheAge time.Duration, batches chan<- []Brand) error {
+	return StreamBrandsContext(context.Background(), appToken, maxCacheAge, batches)
+}
+
+// StreamBrandsContext is StreamBrands, aborted once ctx is done, with opts applied to its config.
+// See sources.StreamSocrataContext.
+func StreamBrandsContext(ctx context.Context, appToken string, maxCacheAge time.Duration, batches chan<- []Brand, opts ...sources.ConfigOption) error {
+	return sources.StreamSocrataContext(ctx, BrandConfig.With(opts...), appToken, maxCacheAge, batches)
 }
 
 // CleanBrands filters out bad Brand samples using IsBrandErroneous().
@@ -141,36 +226,359 @@ func IsBrandErroneous(b *Brand) bool {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+// NamedMeasure is a Measure field of a record, along with its column name
+type NamedMeasure struct {
+	Column  string   // Column name, matching the JSON and CSV field name
+	Measure *Measure // Pointer to the field within the record
+}
+
+// brandMeasureColumns are the names of the measure columns of a Brand, in column order
+var brandMeasureColumns = []string{
+	"tetrahydrocannabinol_thc", "tetrahydrocannabinol_acid_thca", "cannabidiols_cbd",
+	"cannabidiol_acid_cbda", "a_pinene", "b_myrcene", "b_caryophyllene", "b_pinene", "limonene",
+	"ocimene", "linalool_lin", "humulene_hum", "cbg", "cbg_a", "cannabavarin_cbdv",
+	"cannabichromene_cbc", "cannbinol_cbn", "tetrahydrocannabivarin_thcv", "a_bisabolol",
+	"a_phellandrene", "a_terpinene", "b_eudesmol", "b_terpinene", "fenchone", "pulegol", "borneol",
+	"isopulegol", "carene", "camphene", "camphor", "caryophyllene_oxide", "cedrol", "eucalyptol",
+	"geraniol", "guaiol", "geranyl_acetate", "isoborneol", "menthol", "l_fenchone", "nerol",
+	"sabinene", "terpineol", "terpinolene", "trans_b_farnesene", "valencene", "a_cedrene",
+	"a_farnesene", "b_farnesene", "cis_nerolidol", "fenchol", "trans_nerolidol",
+}
+
+// brandMeasureFields are the struct field indices of every Measure in a Brand, in column order
+var brandMeasureFields = jsonFieldIndices(reflect.TypeOf(Brand{}), brandMeasureColumns)
+
+// jsonFieldIndices returns the indices of the fields of struct type t with the given JSON names, in order.
+// Names that t has no field of are skipped; TestBrandColumnsComplete checks that there are none.
+func jsonFieldIndices(t reflect.Type, names []string) []int {
+	indices := make([]int, 0, len(names))
+	for _, name := range names {
+		if field, ok := jsonFieldIndex(t, name); ok {
+			indices = append(indices, field)
+		}
+	}
+	return indices
+}
+
+// jsonFieldIndex returns the index of the field of struct type t with the given JSON name
+func jsonFieldIndex(t reflect.Type, name string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// brandJSONFields are the JSON fields of a Brand, in the order of the columns read from them
+var brandJSONFields = brandFieldOrder()
+
+// brandJSONField is a field of a Brand, by its struct field index, and its JSON name
+type brandJSONField struct {
+	index     int
+	name      string
+	omitEmpty bool // Left out of the JSON if zero, per its omitempty tag
+}
+
+// brandFieldOrder returns the fields of a Brand in the order of Brand.Columns, each where
+// its first column is.  Any field without a column follows them, in declaration order, so it
+// is still exported; TestBrandColumnsComplete checks that every field has one.
+func brandFieldOrder() []brandJSONField {
+	t := reflect.TypeOf(Brand{})
+	placed := make(map[int]bool)
+	var fields []brandJSONField
+	place := func(index int) {
+		placed[index] = true
+		name, options, _ := strings.Cut(t.Field(index).Tag.Get("json"), ",")
+		fields = append(fields, brandJSONField{index: index, name: name, omitEmpty: options == "omitempty"})
+	}
+	for _, column := range (Brand{}).Columns() {
+		name, _, _ := strings.Cut(cmp.Or(column.Source, column.Name), ".")
+		if index, ok := jsonFieldIndex(t, name); ok && !placed[index] {
+			place(index)
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !placed[i] {
+			place(i)
+		}
+	}
+	return fields
+}
+
+// MarshalJSON encodes the brand with its fields in column order, rather than
+// the order they are declared in, so that the JSON export matches the CSV export.
+// Measures and plain strings are appended directly, rather than each encoded with encoding/json.
+func (b Brand) MarshalJSON() ([]byte, error) {
+	v := reflect.ValueOf(&b).Elem()
+	buf := make([]byte, 0, 2048)
+	buf = append(buf, '{')
+	for _, field := range brandJSONFields {
+		if field.omitEmpty && v.Field(field.index).IsZero() {
+			continue
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(append(append(buf, '"'), field.name...), `":`...)
+		switch value := v.Field(field.index).Addr().Interface().(type) {
+		case *Measure:
+			buf = value.AppendJSON(buf)
+		case *string:
+			buf = appendJSONString(buf, *value)
+		default:
+			var err error
+			if buf, err = appendJSON(buf, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+// appendJSONString appends s to dst as a JSON string.  Strings of printable ASCII other than
+// quotes and backslashes are appended as they are, and others are encoded with appendJSON.
+func appendJSONString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x80 || c == '"' || c == '\\' {
+			dst, _ = appendJSON(dst, s) // a string always encodes
+			return dst
+		}
+	}
+	return append(append(append(dst, '"'), s...), '"')
+}
+
+// appendJSON appends v encoded with encoding/json to dst, without escaping HTML,
+// which is escaped as the caller's encoder chooses
+func appendJSON(dst []byte, v any) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return dst, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Measures returns all the Measure fields of the brand, in column order.
+// The returned pointers refer to the brand itself, so may be used to modify it.
+func (b *Brand) Measures() []NamedMeasure {
+	v := reflect.ValueOf(b).Elem()
+	t := v.Type()
+	measures := make([]NamedMeasure, len(brandMeasureFields))
+	for i, idx := range brandMeasureFields {
+		column, _, _ := strings.Cut(t.Field(idx).Tag.Get("json"), ",")
+		measures[i] = NamedMeasure{
+			Column:  column,
+			Measure: v.Field(idx).Addr().Interface().(*Measure),
+		}
+	}
+	return measures
+}
+
+// THCADecarbFactor is the fraction of THCA's mass that remains as THC once decarboxylated
+const THCADecarbFactor = 0.877
+
+// TotalTHC returns the brand's potential total THC, THC + THCADecarbFactor * THCA.
+// Empty, trace, or zero components contribute nothing.  If neither component has
+// an amount, the result is trace if either is trace, empty if both are empty, or else zero.
+func (b Brand) TotalTHC() Measure {
+	thc, thcTrace, thcEmpty := b.TetrahydrocannabinolThc.Amount()
+	thca, thcaTrace, thcaEmpty := b.TetrahydrocannabinolAcidThca.Amount()
+	if total := thc + THCADecarbFactor*thca; total > 0 {
+		return NewMeasure(total)
+	}
+	switch {
+	case thcTrace || thcaTrace:
+		return NewTraceMeasure()
+	case thcEmpty && thcaEmpty:
+		return NewEmptyMeasure()
+	default:
+		return NewMeasure(0)
+	}
+}
+
+// WithMeasurePrecision returns a copy of the brand whose measures export rounded to the given number of decimals.  See Measure.WithPrecision.
+func (b Brand) WithMeasurePrecision(decimals int) Brand {
+	v := reflect.ValueOf(&b).Elem()
+	for _, idx := range brandMeasureFields {
+		field := v.Field(idx)
+		field.Set(reflect.ValueOf(field.Interface().(Measure).WithPrecision(decimals)))
+	}
+	return b
+}
+
+// BrandMeasureSelector returns a function selecting the named measure of a brand,
+// which is either a measure column such as "cannabidiols_cbd" or "total_thc" for TotalTHC.
+// Returns an error if there is no such measure.
+func BrandMeasureSelector(column string) (func(Brand) Measure, error) {
+	if column == "total_thc" {
+		return Brand.TotalTHC, nil
+	}
+	t := reflect.TypeOf(Brand{})
+	for _, idx := range brandMeasureFields {
+		if name, _, _ := strings.Cut(t.Field(idx).Tag.Get("json"), ","); name == column {
+			return func(b Brand) Measure {
+				return reflect.ValueOf(b).Field(idx).Interface().(Measure)
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown brand measure %q", column)
+}
+
+// SortBrandsByMeasure sorts brands in place by the measure chosen by sel, ordered with Measure.Compare.
+// If desc is true, the order is reversed, so brands without an amount sort last.
+// The sort is stable, so brands with equal measures keep their relative order.
+func SortBrandsByMeasure(brands []Brand, sel func(Brand) Measure, desc bool) {
+	// Select each key once, then sort indices rather than the large Brand structs
+	keys := make([]Measure, len(brands))
+	order := make([]int, len(brands))
+	for i := range brands {
+		keys[i] = sel(brands[i])
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		if desc {
+			return keys[j].Compare(keys[i])
+		}
+		return keys[i].Compare(keys[j])
+	})
+
+	sorted := make([]Brand, len(brands))
+	for i, idx := range order {
+		sorted[i] = brands[idx]
+	}
+	copy(brands, sorted)
+}
+
+// PercentClampMode selects how ClampBrandPercents repairs out-of-range percentages
+type PercentClampMode string
+
+const (
+	PercentClampNone  PercentClampMode = ""      // Leave out-of-range percentages alone
+	PercentClampClamp PercentClampMode = "clamp" // Clamp out-of-range percentages to 100
+	PercentClampDrop  PercentClampMode = "drop"  // Replace out-of-range percentages with an empty measure
+)
+
+// ClampBrandPercents repairs, in place, every brand measure that is not a valid percentage
+// according to IsValidPercent, using the given mode.  Valid measures are untouched.
+// Returns a CleanReport for each repaired measure.
+func ClampBrandPercents(bs []Brand, mode PercentClampMode) []sources.CleanReport {
+	if mode == PercentClampNone {
+		return nil
+	}
+
+	var reports []sources.CleanReport
+	for i := range bs {
+		b := &bs[i]
+		for _, nm := range b.Measures() {
+			if nm.Measure.IsValidPercent() {
+				continue
+			}
+			original := nm.Measure.AsCSV()
+			switch mode {
+			case PercentClampClamp:
+				*nm.Measure = NewMeasure(100)
+			case PercentClampDrop:
+				*nm.Measure = NewEmptyMeasure()
+			}
+			reports = append(reports, sources.CleanReport{
+				Dataset: "brands",
+				Record:  b.RegistrationNumber,
+				Field:   nm.Column,
+				Action:  string(mode),
+				Detail:  fmt.Sprintf("out-of-range percent %s -> %q", original, nm.Measure.AsCSV()),
+			})
+		}
+	}
+	return reports
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// brandColumns are the columns of Brand.Columns, which CSVHeaders and CSVValue write in order
+var brandColumns = (Brand{}).Columns()
+
+// brandCSVHeaders is the CSV header line of a Brand, naming the columns of Brand.Columns
+var brandCSVHeaders = func() string {
+	var sb strings.Builder
+	for i, column := range brandColumns {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(`"` + column.Name + `"`)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}()
+
+// brandApprovalDateLayout is the layout of a Brand's approval_date CSV column
+const brandApprovalDateLayout = "2006-01-02T15:04:05-0700"
+
 // CSVHeaders returns the CSV headers for the Brand struct
 func (b Brand) CSVHeaders() string {
-	return `"brand_name","dosage_form","branding_entity","product_image_url","product_image_desc","label_image_url","label_image_desc","lab_analysis_url","lab_analysis_desc","approval_date","registration_number","tetrahydrocannabinol_thc","tetrahydrocannabinol_acid_thca","cannabidiols_cbd","cannabidiol_acid_cbda","a_pinene","b_myrcene","b_caryophyllene","b_pinene","limonene","ocimene","linalool_lin","humulene_hum","cbg","cbg_a","cannabavarin_cbdv","cannabichromene_cbc","cannbinol_cbn","tetrahydrocannabivarin_thcv","a_bisabolol","a_phellandrene","a_terpinene","b_eudesmol","b_terpinene","fenchone","pulegol","borneol","isopulegol","carene","camphene","camphor","caryophyllene_oxide","cedrol","eucalyptol","geraniol","guaiol","geranyl_acetate","isoborneol","menthol","l_fenchone","nerol","sabinene","terpineol","terpinolene","trans_b_farnesene","valencene","a_cedrene","a_farnesene","b_farnesene","cis_nerolidol","fenchol","trans_nerolidol","market","chemotype","processing_technique","solvents_used","national_drug_code"
-`
+	return brandCSVHeaders
 }
 
-// CSVValue returns the CSV value for the Brand struct
+// CSVValue returns the CSV value for the Brand struct, with each of Brand.Columns in turn
 func (b Brand) CSVValue() string {
-	return fmt.Sprintf(`"%s","%s","%s","%s","%s","%s","%s","%s","%s","%s","%s",%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,"%s","%s","%s","%s","%s"
-`,
-		CSVString(b.BrandName), CSVString(b.DosageForm), CSVString(b.BrandingEntity),
-		CSVString(b.ProductImage.URL), CSVString(b.ProductImage.Description),
-		CSVString(b.LabelImage.URL), CSVString(b.LabelImage.Description),
-		CSVString(b.LabAnalysis.URL), CSVString(b.LabAnalysis.Description),
-		b.ApprovalDate.Format("2006-01-02T15:04:05-0700"), CSVString(b.RegistrationNumber),
-		b.TetrahydrocannabinolThc.AsCSV(), b.TetrahydrocannabinolAcidThca.AsCSV(), b.CannabidiolsCbd.AsCSV(), b.CannabidiolAcidCbda.AsCSV(), b.APinene.AsCSV(),
-		b.BMyrcene.AsCSV(), b.BCaryophyllene.AsCSV(), b.BPinene.AsCSV(), b.Limonene.AsCSV(), b.Ocimene.AsCSV(), b.LinaloolLin.AsCSV(), b.HumuleneHum.AsCSV(),
-		b.Cbg.AsCSV(), b.CbgA.AsCSV(), b.CannabavarinCbdv.AsCSV(), b.CannabichromeneCbc.AsCSV(), b.CannbinolCbn.AsCSV(), b.TetrahydrocannabivarinThcv.AsCSV(),
-		b.ABisabolol.AsCSV(), b.APhellandrene.AsCSV(), b.ATerpinene.AsCSV(), b.BEudesmol.AsCSV(), b.BTerpinene.AsCSV(), b.Fenchone.AsCSV(), b.Pulegol.AsCSV(),
-		b.Borneol.AsCSV(), b.Isopulegol.AsCSV(), b.Carene.AsCSV(), b.Camphene.AsCSV(), b.Camphor.AsCSV(), b.CaryophylleneOxide.AsCSV(), b.Cedrol.AsCSV(),
-		b.Eucalyptol.AsCSV(), b.Geraniol.AsCSV(), b.Guaiol.AsCSV(), b.GeranylAcetate.AsCSV(), b.Isoborneol.AsCSV(), b.Menthol.AsCSV(), b.LFenchone.AsCSV(),
-		b.Nerol.AsCSV(), b.Sabinene.AsCSV(), b.Terpineol.AsCSV(), b.Terpinolene.AsCSV(), b.TransBFarnesene.AsCSV(), b.Valencene.AsCSV(), b.ACedrene.AsCSV(),
-		b.AFarnesene.AsCSV(), b.BFarnesene.AsCSV(), b.CisNerolidol.AsCSV(), b.Fenchol.AsCSV(), b.TransNerolidol.AsCSV(),
-		CSVString(b.Market), CSVString(b.Chemotype), CSVString(b.ProcessingTechnique), CSVString(b.SolventsUsed), CSVString(b.NationalDrugCode),
+	return sources.CSVColumnsValue(&b, brandColumns)
+}
+
+// Columns returns the CSV columns of the Brand struct, for reading its CSV export back with sources.ReadCSV.
+// Their order is the canonical order of the CSV and JSON exports, and each column writes its
+// field in CSVValue.
+func (b Brand) Columns() []sources.Column[Brand] {
+	columns := []sources.Column[Brand]{
+		sources.StringColumn("brand_name", func(b *Brand) *string { return &b.BrandName }),
+		sources.StringColumn("dosage_form", func(b *Brand) *string { return &b.DosageForm }),
+		sources.StringColumn("branding_entity", func(b *Brand) *string { return &b.BrandingEntity }),
+		sources.StringColumn("product_image_url", func(b *Brand) *string { return &b.ProductImage.URL }).WithSource("product_image.url"),
+		sources.StringColumn("product_image_desc", func(b *Brand) *string { return &b.ProductImage.Description }).WithSource("product_image.description"),
+		sources.StringColumn("label_image_url", func(b *Brand) *string { return &b.LabelImage.URL }).WithSource("label_image.url"),
+		sources.StringColumn("label_image_desc", func(b *Brand) *string { return &b.LabelImage.Description }).WithSource("label_image.description"),
+		sources.StringColumn("lab_analysis_url", func(b *Brand) *string { return &b.LabAnalysis.URL }).WithSource("lab_analysis.url"),
+		sources.StringColumn("lab_analysis_desc", func(b *Brand) *string { return &b.LabAnalysis.Description }).WithSource("lab_analysis.description"),
+		{Name: "approval_date", Set: func(b *Brand, v string) error {
+			if v == "" {
+				b.ApprovalDate = iso8601.Time{}
+				return nil
+			}
+			approvalDate, err := sources.ParseSocrataTime(v)
+			if err != nil {
+				return err
+			}
+			b.ApprovalDate = iso8601.Time{Time: approvalDate}
+			return nil
+		}, Format: func(row *sources.CSVRow, b *Brand) {
+			row.Time(b.ApprovalDate.Time, brandApprovalDateLayout)
+		}},
+		sources.StringColumn("registration_number", func(b *Brand) *string { return &b.RegistrationNumber }),
+	}
+
+	// The measure columns are named by their JSON tags
+	t := reflect.TypeOf(Brand{})
+	for _, idx := range brandMeasureFields {
+		name, _, _ := strings.Cut(t.Field(idx).Tag.Get("json"), ",")
+		columns = append(columns, sources.UnmarshalColumn(name, func(b *Brand) sources.CSVField {
+			return reflect.ValueOf(b).Elem().Field(idx).Addr().Interface().(*Measure)
+		}))
+	}
+
+	return append(columns,
+		sources.StringColumn("market", func(b *Brand) *string { return &b.Market }),
+		sources.StringColumn("chemotype", func(b *Brand) *string { return &b.Chemotype }),
+		sources.StringColumn("processing_technique", func(b *Brand) *string { return &b.ProcessingTechnique }),
+		sources.StringColumn("solvents_used", func(b *Brand) *string { return &b.SolventsUsed }),
+		sources.StringColumn("national_drug_code", func(b *Brand) *string { return &b.NationalDrugCode }),
+		// Last, so that the other columns are where they were before it was added
+		sources.StringColumn("canonical_name", func(b *Brand) *string { return &b.CanonicalName }).AsOptional(),
 	)
 }
 
 // CSVString sanitizes a string for use in a CSV file field
 func CSVString(str string) string {
-	return strings.Replace(str, `"`, `'`, -1)
+	return sources.CSVString(str)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -190,10 +598,10 @@ a_phellandrene,a_terpinene,b_eudesmol,b_terpinene,fenchone,pulegol,borneol,isopu
 camphene,camphor,caryophyllene_oxide,cedrol,eucalyptol,geraniol,guaiol,geranyl_acetate,isoborneol,
 menthol,l_fenchone,nerol,sabinene,terpineol,terpinolene,trans_b_farnesene,valencene,a_cedrene,
 a_farnesene,b_farnesene,cis_nerolidol,fenchol,trans_nerolidol,market,chemotype,processing_technique,
-solvents_used,national_drug_code)
+solvents_used,national_drug_code,canonical_name)
 VALUES `
 	sqlFooter := ` ON CONFLICT DO NOTHING;`
-	sqlFormat := `('%s','%s','%s','%s','%s','%s','%s','%s','%s','%s','%s',%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,'%s','%s','%s','%s','%s')`
+	sqlFormat := `('%s','%s','%s','%s','%s','%s','%s','%s','%s','%s','%s',%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,'%s','%s','%s','%s','%s','%s')`
 
 	var sb strings.Builder
 	sb.WriteString(sqlHeader)
@@ -261,7 +669,7 @@ VALUES `
 			b.Fenchol.AsSQL(),
 			b.TransNerolidol.AsSQL(),
 			sources.SQLString(b.Market), sources.SQLString(b.Chemotype), sources.SQLString(b.ProcessingTechnique),
-			sources.SQLString(b.SolventsUsed), sources.SQLString(b.NationalDrugCode)))
+			sources.SQLString(b.SolventsUsed), sources.SQLString(b.NationalDrugCode), sources.SQLString(b.CanonicalName)))
 	}
 	sb.WriteString(sqlFooter)
 
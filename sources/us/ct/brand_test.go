@@ -0,0 +1,275 @@
+// Copyright 2026 Neomantra Corp
+
+package ct
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+	"github.com/relvacode/iso8601"
+)
+
+// TestBrandColumnsComplete checks that Brand.Columns and the Brand struct match, so that
+// every field is placed in the exports, in column order, and every column reads and writes a field
+func TestBrandColumnsComplete(t *testing.T) {
+	brandType := reflect.TypeOf(Brand{})
+	for _, name := range brandMeasureColumns {
+		if index, ok := jsonFieldIndex(brandType, name); !ok {
+			t.Errorf("measure column %s has no Brand field", name)
+		} else if brandType.Field(index).Type != reflect.TypeOf(Measure{}) {
+			t.Errorf("measure column %s is Brand field %s, which is not a Measure", name, brandType.Field(index).Name)
+		}
+	}
+
+	placed := make(map[int]bool)
+	for _, column := range (Brand{}).Columns() {
+		if column.Format == nil {
+			t.Errorf("Brand column %s has no Format, so CSVValue writes it empty", column.Name)
+		}
+		name, _, _ := strings.Cut(cmp.Or(column.Source, column.Name), ".")
+		index, ok := jsonFieldIndex(brandType, name)
+		if !ok {
+			t.Errorf("Brand column %s has no field %q", column.Name, name)
+			continue
+		}
+		placed[index] = true
+	}
+	for i := 0; i < brandType.NumField(); i++ {
+		if !placed[i] {
+			t.Errorf("Brand field %s has no column in Brand.Columns", brandType.Field(i).Name)
+		}
+	}
+}
+
+// filledBrand returns a brand with every field set to a value distinct from the others
+func filledBrand() Brand {
+	var b Brand
+	v := reflect.ValueOf(&b).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		switch field := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			*field = "text " + strconv.Itoa(i)
+		case *Image:
+			*field = Image{URL: "https://example.com/" + strconv.Itoa(i), Description: "image " + strconv.Itoa(i)}
+		case *Measure:
+			*field = NewMeasure(float64(i) + 0.25)
+		case *iso8601.Time:
+			*field = iso8601.Time{Time: time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)}
+		default:
+			panic("filledBrand has no value for Brand field " + v.Type().Field(i).Name)
+		}
+	}
+	return b
+}
+
+// brandCell returns the CSV cell of the brand's field at path, a dotted path of JSON names
+func brandCell(t *testing.T, b Brand, path string) string {
+	t.Helper()
+	v := reflect.ValueOf(b)
+	for name := range strings.SplitSeq(path, ".") {
+		index, ok := jsonFieldIndex(v.Type(), name)
+		if !ok {
+			t.Fatalf("%s has no field %q", v.Type(), name)
+		}
+		v = v.Field(index)
+	}
+	switch field := v.Interface().(type) {
+	case string:
+		return field
+	case Measure:
+		return field.AsCSV()
+	case iso8601.Time:
+		return field.Format(brandApprovalDateLayout)
+	}
+	t.Fatalf("no CSV cell of %s, a %s", path, v.Type())
+	return ""
+}
+
+// TestBrandCSVValueMatchesColumns checks that every cell of a brand's CSVValue is the field
+// that its column of CSVHeaders and Brand.Columns reads, and that the row reads back as the brand
+func TestBrandCSVValueMatchesColumns(t *testing.T) {
+	b := filledBrand()
+	records, err := csv.NewReader(strings.NewReader(b.CSVHeaders() + b.CSVValue())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, row := records[0], records[1]
+	columns := b.Columns()
+	if len(header) != len(columns) || len(row) != len(columns) {
+		t.Fatalf("CSV has %d columns and %d cells, want %d columns", len(header), len(row), len(columns))
+	}
+	for i, column := range columns {
+		if header[i] != column.Name {
+			t.Errorf("CSV column %d = %s, want %s", i, header[i], column.Name)
+		}
+		if want := brandCell(t, b, cmp.Or(column.Source, column.Name)); row[i] != want {
+			t.Errorf("CSV cell %d (%s) = %q, want %q", i, column.Name, row[i], want)
+		}
+	}
+
+	got, err := sources.ReadCSV[Brand](strings.NewReader(b.CSVHeaders() + b.CSVValue()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].ApprovalDate.UTC(), b.ApprovalDate.UTC()) {
+		t.Fatalf("ReadCSV() = %+v, want the brand", got)
+	}
+	got[0].ApprovalDate = b.ApprovalDate
+	if !reflect.DeepEqual(got[0], b) {
+		t.Errorf("ReadCSV() = %+v, want %+v", got[0], b)
+	}
+}
+
+// TestBrandMeasurePrecisionExports checks that a brand's measures are exported rounded to
+// its measure precision by the CSV and JSON writers, while their DuckDB values are not
+func TestBrandMeasurePrecisionExports(t *testing.T) {
+	b := Brand{RegistrationNumber: "BRAND-1", TetrahydrocannabinolThc: NewMeasure(12.3456)}.WithMeasurePrecision(2)
+	dir := t.TempDir()
+
+	csvFile := filepath.Join(dir, BrandCSVFilename)
+	if _, err := sources.WriteCSV(csvFile, []Brand{b}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(file).ReadAll()
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("CSV export has %d rows, want a header and a brand", len(records))
+	}
+	if i := slices.Index(records[0], "tetrahydrocannabinol_thc"); i < 0 || records[1][i] != "12.35" {
+		t.Errorf("CSV export = %q, want tetrahydrocannabinol_thc 12.35", records)
+	}
+
+	jsonFile := filepath.Join(dir, BrandJSONFilename)
+	if _, err := sources.WriteJSON(jsonFile, []Brand{b}); err != nil {
+		t.Fatal(err)
+	}
+	jsonBytes, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(jsonBytes, &objects); err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || string(objects[0]["tetrahydrocannabinol_thc"]) != "12.35" {
+		t.Errorf("JSON export = %s, want tetrahydrocannabinol_thc 12.35", jsonBytes)
+	}
+
+	if got, want := b.TetrahydrocannabinolThc.AsSQL(), "12.345600"; got != want {
+		t.Errorf("AsSQL() = %s, want %s", got, want)
+	}
+	if got, err := b.TetrahydrocannabinolThc.Value(); err != nil || got != 12.3456 {
+		t.Errorf("Value() = %v, %v, want 12.3456", got, err)
+	}
+}
+
+func TestBrandCSVCanonicalNameLast(t *testing.T) {
+	b := Brand{BrandName: "Blue  Dream™", CanonicalName: "Blue Dream", RegistrationNumber: "BRAND-1"}
+	var header []string
+	for _, column := range b.Columns() {
+		header = append(header, column.Name)
+	}
+	if header[0] != "brand_name" || header[len(header)-1] != "canonical_name" {
+		t.Errorf("CSV columns run %s to %s, want brand_name to canonical_name", header[0], header[len(header)-1])
+	}
+
+	// Exports without canonical_name, as written before it was added, still read back with it empty
+	for _, columns := range [][]string{nil, header[:len(header)-1]} {
+		filename := filepath.Join(t.TempDir(), BrandCSVFilename)
+		if _, err := sources.WriteCSVWith(filename, []Brand{b}, sources.CSVOptions{Columns: columns}); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sources.ReadCSV[Brand](file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("ReadCSV() = %v", err)
+		}
+		want := b.CanonicalName
+		if columns != nil {
+			want = ""
+		}
+		if len(got) != 1 || got[0].BrandName != b.BrandName || got[0].CanonicalName != want {
+			t.Errorf("ReadCSV() = %+v, want brand %q with canonical name %q", got, b.BrandName, want)
+		}
+	}
+}
+
+// benchBrands returns n brands with every measure set, as a large brands export would have
+func benchBrands(n int) []Brand {
+	brands := make([]Brand, n)
+	for i := range brands {
+		b := &brands[i]
+		b.BrandName = "Brand " + strconv.Itoa(i)
+		b.DosageForm = "Flower"
+		b.BrandingEntity = "Entity, LLC"
+		b.RegistrationNumber = "BRAND-" + strconv.Itoa(i)
+		for j, nm := range b.Measures() {
+			*nm.Measure = NewMeasure(float64(i%100) + float64(j)/10)
+		}
+	}
+	return brands
+}
+
+func BenchmarkWriteCSVBrands(b *testing.B) {
+	brands := benchBrands(50000)
+	filename := filepath.Join(b.TempDir(), BrandCSVFilename)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sources.WriteCSV(filename, brands); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// declaredBrand is a Brand without its MarshalJSON, so encoding/json encodes its fields in
+// the order they are declared, as the JSON export did before it was ordered by Brand.Columns
+type declaredBrand Brand
+
+// BenchmarkWriteJSONBrands writes 50k brands with Brand.MarshalJSON, and with encoding/json
+// encoding each field, as brands were before MarshalJSON ordered them
+func BenchmarkWriteJSONBrands(b *testing.B) {
+	brands := benchBrands(50000)
+	declared := make([]declaredBrand, len(brands))
+	for i, brand := range brands {
+		declared[i] = declaredBrand(brand)
+	}
+	filename := filepath.Join(b.TempDir(), BrandJSONFilename)
+	b.Run("MarshalJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sources.WriteJSON(filename, brands); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sources.WriteJSON(filename, declared); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Neomantra Corp
+//
+// Normalization of CT brand names, for grouping and joining brands
+
+package ct
+
+import (
+	"strings"
+	"unicode"
+)
+
+// brandNameMarks are the trademark symbols that NormalizeBrandName strips from the end of a name
+var brandNameMarks = []string{"™", "®", "(TM)", "(tm)", "(R)", "(r)"}
+
+// NormalizeBrandName returns the brand name trimmed, with each run of whitespace collapsed to
+// a single space, and any trailing trademark symbols, ™ and ®, stripped, e.g. "  Blue   Dream™ "
+// becomes "Blue Dream".  Its casing is kept; see TitleCaseBrandName.
+func NormalizeBrandName(s string) string {
+	name := strings.Join(strings.Fields(s), " ")
+	for trimmed := false; !trimmed; {
+		trimmed = true
+		for _, mark := range brandNameMarks {
+			if rest, ok := strings.CutSuffix(name, mark); ok {
+				name, trimmed = strings.TrimRightFunc(rest, unicode.IsSpace), false
+			}
+		}
+	}
+	return name
+}
+
+// TitleCaseBrandName returns the brand name title-cased, each word's first letter upper case
+// and its others lower case, if its letters are all of one case, e.g. "BLUE DREAM" or
+// "blue dream" becomes "Blue Dream".  Names of mixed case, e.g. "GG4 by McKinney", are
+// cased deliberately, so are returned as they are.
+func TitleCaseBrandName(s string) string {
+	if strings.ToUpper(s) != s && strings.ToLower(s) != s {
+		return s
+	}
+	words := strings.Split(strings.ToLower(s), " ")
+	for i, word := range words {
+		for j, r := range word {
+			if unicode.IsLetter(r) {
+				words[i] = word[:j] + string(unicode.ToUpper(r)) + word[j+len(string(r)):]
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// BrandNameMode selects how SetCanonicalBrandNames derives canonical brand names
+type BrandNameMode string
+
+const (
+	BrandNameNone  BrandNameMode = ""      // Do not derive canonical names
+	BrandNameKeep  BrandNameMode = "keep"  // Normalize names, keeping their case
+	BrandNameTitle BrandNameMode = "title" // Normalize names and title-case them
+)
+
+// SetCanonicalBrandNames sets, in place, each brand's CanonicalName to its BrandName normalized
+// with NormalizeBrandName, and title-cased with TitleCaseBrandName if mode is BrandNameTitle.
+// BrandName itself is left as it is.
+func SetCanonicalBrandNames(bs []Brand, mode BrandNameMode) {
+	if mode == BrandNameNone {
+		return
+	}
+	for i := range bs {
+		name := NormalizeBrandName(bs[i].BrandName)
+		if mode == BrandNameTitle {
+			name = TitleCaseBrandName(name)
+		}
+		bs[i].CanonicalName = name
+	}
+}
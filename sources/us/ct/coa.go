@@ -0,0 +1,95 @@
+// Copyright 2026 Neomantra Corp
+//
+// CT Cannabis Brand Certificates of Analysis (COAs)
+
+package ct
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+const (
+	BrandCOACSVFilename  = "us_ct_brands_coa.csv"
+	BrandCOAJSONFilename = "us_ct_brands_coa.json"
+	// COADirname is the subdirectory of the output directory that COA documents are downloaded into
+	COADirname = "coa"
+	// defaultCOAExtension is the extension of COA documents whose URL has none, as they are PDFs
+	defaultCOAExtension = ".pdf"
+)
+
+// BrandCOA records the certificate of analysis (COA) lab report downloaded for a brand
+type BrandCOA struct {
+	RegistrationNumber string `json:"registration_number"`
+	URL                string `json:"url"`      // Brand's LabAnalysis URL
+	Filename           string `json:"filename"` // Downloaded file, relative to the output directory, e.g. "coa/<sha256>.pdf"
+	SHA256             string `json:"sha256"`   // Hex SHA-256 of the downloaded file
+}
+
+// FetchBrandCOAs downloads the COA linked by each brand's LabAnalysis into the COADirname
+// subdirectory of outputDir, with each file named by its SHA-256.  Brands without a COA are skipped,
+// and a URL shared by several brands is downloaded once.  Downloads that fail are skipped.
+// Returns a BrandCOA for each brand whose COA was downloaded, and an error for each failed download.
+func FetchBrandCOAs(brands []Brand, outputDir string) ([]BrandCOA, []error, error) {
+	coaDir := filepath.Join(outputDir, COADirname)
+	if err := os.MkdirAll(coaDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create COA directory: %w", err)
+	}
+
+	var coas []BrandCOA
+	downloaded := make(map[string]BrandCOA) // by URL, including failures with an empty Filename
+	var failures []error
+	for _, b := range brands {
+		coaURL := b.LabAnalysis.URL
+		if coaURL == "" {
+			continue
+		}
+		coa, ok := downloaded[coaURL]
+		if !ok {
+			coa = BrandCOA{URL: coaURL}
+			filename, sum, err := sources.DownloadFile(coaURL, coaDir, coaExtension(coaURL))
+			if err != nil {
+				failures = append(failures, fmt.Errorf("failed to fetch COA of brand %s: %w", b.RegistrationNumber, err))
+			} else {
+				coa.Filename, coa.SHA256 = path.Join(COADirname, filename), sum
+			}
+			downloaded[coaURL] = coa
+		}
+		if coa.Filename != "" {
+			coa.RegistrationNumber = b.RegistrationNumber
+			coas = append(coas, coa)
+		}
+	}
+	return coas, failures, nil
+}
+
+// coaExtension returns the extension of a COA URL's path, or defaultCOAExtension if it has none
+func coaExtension(coaURL string) string {
+	if u, err := url.Parse(coaURL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	return defaultCOAExtension
+}
+
+// CSVHeaders returns the CSV headers for the BrandCOA struct
+func (c BrandCOA) CSVHeaders() string {
+	return `"registration_number","url","filename","sha256"
+`
+}
+
+// CSVValue returns the CSV value for the BrandCOA struct
+func (c BrandCOA) CSVValue() string {
+	var row sources.CSVRow
+	row.String(c.RegistrationNumber)
+	row.String(c.URL)
+	row.String(c.Filename)
+	row.String(c.SHA256)
+	return row.Line()
+}
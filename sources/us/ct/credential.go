@@ -8,9 +8,10 @@
 package ct
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,35 +19,77 @@ import (
 )
 
 const (
-	CredentialJSONFilename = "us_ct_credentials.json"
-	CredentialCSVFilename  = "us_ct_credentials.csv"
-	CredentialsURL         = "https://data.ct.gov/resource/tjfe-s2x9.json"
+	CredentialJSONFilename        = "us_ct_credentials.json"
+	CredentialCSVFilename         = "us_ct_credentials.csv"
+	CredentialSummaryJSONFilename = "us_ct_credentials_summary.json"
+	CredentialSummaryCSVFilename  = "us_ct_credentials_summary.csv"
+	CredentialCleanReportFilename = "us_ct_credentials_clean_report.csv"
+	CredentialsURL                = "https://data.ct.gov/resource/tjfe-s2x9.json"
 )
 
 // Credential represents a CT cannabis credential count record
 type Credential struct {
-	CredentialType string `json:"credentialtype"`
-	Status         string `json:"status"`
-	Count          string `json:"count"`
+	CredentialType string          `json:"credentialtype"`
+	Status         string          `json:"status"`
+	Count          sources.FlexInt `json:"count"`
 }
 
-// CountInt returns the count as an integer
+// CountInt returns the count as an integer, or 0 if it is missing or not an integer.
+// Use CountIntChecked to tell those apart from a count of 0.
 func (c Credential) CountInt() int {
-	n, _ := strconv.Atoi(c.Count)
+	n, _ := c.CountIntChecked()
 	return n
 }
 
+// CountIntChecked returns the count as an integer.
+// Returns an error if the count is missing or not an integer.
+func (c Credential) CountIntChecked() (int, error) {
+	if c.Count == "" {
+		return 0, fmt.Errorf("missing count")
+	}
+	n, err := c.Count.Int()
+	if err != nil {
+		return 0, fmt.Errorf("invalid count %q: %w", c.Count, err)
+	}
+	return int(n), nil
+}
+
+// CheckCredentialCounts returns a CleanReport for each credential whose count is missing
+// or not an integer.  Such credentials are left out of SummarizeCredentials,
+// and loaded into DuckDB with a NULL count.
+func CheckCredentialCounts(creds []Credential) []sources.CleanReport {
+	var reports []sources.CleanReport
+	for _, c := range creds {
+		if _, err := c.CountIntChecked(); err != nil {
+			reports = append(reports, sources.CleanReport{
+				Dataset: "credentials",
+				Record:  c.CredentialType + "/" + c.Status,
+				Field:   "count",
+				Action:  "skip",
+				Detail:  err.Error(),
+			})
+		}
+	}
+	return reports
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 // CredentialConfig returns the Socrata configuration for credentials
 var CredentialConfig = sources.SocrataConfig{
 	URL:           CredentialsURL,
 	CacheFilename: CredentialJSONFilename,
+	OrderBy:       ":id", // the row ID, so that pages neither skip nor repeat rows
 }
 
 // FetchCredentials fetches all CT cannabis credential data from the CT API
 func FetchCredentials(appToken string, maxCacheAge time.Duration) ([]Credential, error) {
-	return sources.FetchSocrata[Credential](CredentialConfig, appToken, maxCacheAge)
+	return FetchCredentialsContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchCredentialsContext is FetchCredentials, aborted once ctx is done, with opts applied to its config
+func FetchCredentialsContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]Credential, error) {
+	return sources.FetchSocrataContext[Credential](ctx, CredentialConfig.With(opts...), appToken, maxCacheAge)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -59,8 +102,93 @@ func (c Credential) CSVHeaders() string {
 
 // CSVValue returns the CSV value for the Credential struct
 func (c Credential) CSVValue() string {
-	return fmt.Sprintf(`"%s","%s","%s"
-`, CSVString(c.CredentialType), CSVString(c.Status), c.Count)
+	var row sources.CSVRow
+	row.String(c.CredentialType)
+	row.String(c.Status)
+	row.String(string(c.Count))
+	return row.Line()
+}
+
+// Columns returns the CSV columns of the Credential struct, for reading its CSV export back with sources.ReadCSV
+func (c Credential) Columns() []sources.Column[Credential] {
+	return []sources.Column[Credential]{
+		sources.StringColumn("credential_type", func(c *Credential) *string { return &c.CredentialType }).WithSource("credentialtype"),
+		sources.StringColumn("status", func(c *Credential) *string { return &c.Status }),
+		sources.StringColumn("count", func(c *Credential) *string { return (*string)(&c.Count) }),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// CredentialSummary is the roll-up of all credential records of a single type
+type CredentialSummary struct {
+	CredentialType string         `json:"credential_type"`
+	Total          int            `json:"total"`
+	ByStatus       map[string]int `json:"by_status"`
+}
+
+// SummarizeCredentials pivots credential records into per-type totals with a
+// breakdown by status. Records sharing a type and status are summed.
+// Records whose count is missing or invalid are skipped, as reported by CheckCredentialCounts.
+// The result is sorted by credential type.
+func SummarizeCredentials(creds []Credential) []CredentialSummary {
+	byType := make(map[string]*CredentialSummary)
+	for _, c := range creds {
+		count, err := c.CountIntChecked()
+		if err != nil {
+			continue
+		}
+		summary, ok := byType[c.CredentialType]
+		if !ok {
+			summary = &CredentialSummary{
+				CredentialType: c.CredentialType,
+				ByStatus:       make(map[string]int),
+			}
+			byType[c.CredentialType] = summary
+		}
+		summary.Total += count
+		summary.ByStatus[c.Status] += count
+	}
+
+	summaries := make([]CredentialSummary, 0, len(byType))
+	for _, summary := range byType {
+		summaries = append(summaries, *summary)
+	}
+	slices.SortFunc(summaries, func(a, b CredentialSummary) int {
+		return strings.Compare(a.CredentialType, b.CredentialType)
+	})
+	return summaries
+}
+
+// StatusBreakdown returns the per-status counts as "status:count" pairs joined by "|",
+// sorted by status.
+func (s CredentialSummary) StatusBreakdown() string {
+	statuses := make([]string, 0, len(s.ByStatus))
+	for status := range s.ByStatus {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%s:%d", status, s.ByStatus[status])
+	}
+	return strings.Join(parts, "|")
+}
+
+// CSVHeaders returns the CSV headers for the CredentialSummary struct
+func (s CredentialSummary) CSVHeaders() string {
+	return `"credential_type","total","by_status"
+`
+}
+
+// CSVValue returns the CSV value for the CredentialSummary struct
+func (s CredentialSummary) CSVValue() string {
+	var row sources.CSVRow
+	row.String(s.CredentialType)
+	row.Int(s.Total)
+	row.String(s.StatusBreakdown())
+	return row.Line()
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -83,10 +211,10 @@ func DBInsertCredentials(conn *sql.DB, credentials []Credential) error {
 		if i > 0 {
 			sb.WriteString(",")
 		}
-		sb.WriteString(fmt.Sprintf("('%s','%s',%d)",
+		sb.WriteString(fmt.Sprintf("('%s','%s',%s)",
 			sources.SQLString(c.CredentialType),
 			sources.SQLString(c.Status),
-			c.CountInt()))
+			c.Count.AsSQL()))
 	}
 
 	if _, err := conn.Exec(sb.String()); err != nil {
@@ -0,0 +1,79 @@
+// Copyright 2026 Neomantra Corp
+
+package ct
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestSummarizeCredentials(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds []Credential
+		want  []CredentialSummary
+	}{
+		{
+			name:  "empty",
+			creds: nil,
+			want:  []CredentialSummary{},
+		},
+		{
+			name: "duplicate type and status rows sum",
+			creds: []Credential{
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "3"},
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "4"},
+				{CredentialType: "Retailer", Status: "INACTIVE", Count: "1"},
+			},
+			want: []CredentialSummary{
+				{CredentialType: "Retailer", Total: 8, ByStatus: map[string]int{"ACTIVE": 7, "INACTIVE": 1}},
+			},
+		},
+		{
+			name: "types are sorted and kept apart",
+			creds: []Credential{
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "2"},
+				{CredentialType: "Cultivator", Status: "ACTIVE", Count: "5"},
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "2"},
+				{CredentialType: "Cultivator", Status: "PENDING", Count: "1"},
+			},
+			want: []CredentialSummary{
+				{CredentialType: "Cultivator", Total: 6, ByStatus: map[string]int{"ACTIVE": 5, "PENDING": 1}},
+				{CredentialType: "Retailer", Total: 4, ByStatus: map[string]int{"ACTIVE": 4}},
+			},
+		},
+		{
+			name: "missing and invalid counts are skipped",
+			creds: []Credential{
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "2"},
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: ""},
+				{CredentialType: "Retailer", Status: "ACTIVE", Count: "many"},
+				{CredentialType: "Producer", Status: "ACTIVE", Count: "x"},
+			},
+			want: []CredentialSummary{
+				{CredentialType: "Retailer", Total: 2, ByStatus: map[string]int{"ACTIVE": 2}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SummarizeCredentials(tt.creds)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d summaries %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i].CredentialType != tt.want[i].CredentialType || got[i].Total != tt.want[i].Total ||
+					!maps.Equal(got[i].ByStatus, tt.want[i].ByStatus) {
+					t.Errorf("summary %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCredentialSummaryStatusBreakdown(t *testing.T) {
+	s := CredentialSummary{ByStatus: map[string]int{"PENDING": 1, "ACTIVE": 7}}
+	if got, want := s.StatusBreakdown(), "ACTIVE:7|PENDING:1"; got != want {
+		t.Errorf("StatusBreakdown() = %q, want %q", got, want)
+	}
+}
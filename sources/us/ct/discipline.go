@@ -0,0 +1,156 @@
+// Copyright 2026 Neomantra Corp
+//
+// CT Cannabis Disciplinary Actions Data
+//
+// The Socrata view for this dataset is not pinned by dank-extract;
+// it must be configured with SetDisciplinaryActionsView before fetching.
+
+package ct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+const (
+	DisciplinaryActionJSONFilename = "us_ct_disciplinary_actions.json"
+	DisciplinaryActionCSVFilename  = "us_ct_disciplinary_actions.csv"
+)
+
+// DisciplinaryAction represents an enforcement or disciplinary action taken against a CT cannabis licensee
+type DisciplinaryAction struct {
+	LicenseNumber  string `json:"license_number"`
+	Name           string `json:"name"`
+	CredentialType string `json:"credential_type"`
+	ActionType     string `json:"action_type"`
+	ActionDate     string `json:"action_date"` // ISO 8601 datetime
+	Violation      string `json:"violation"`
+	Penalty        string `json:"penalty"`
+}
+
+// ActionTime returns the date of the action, parsed from ActionDate with sources.ParseSocrataTime
+func (d DisciplinaryAction) ActionTime() (time.Time, error) {
+	actionDate, err := sources.ParseSocrataTime(d.ActionDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid action date %q: %w", d.ActionDate, err)
+	}
+	return actionDate, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// DisciplinaryActionConfig returns the Socrata configuration for disciplinary actions.
+// Its URL is empty until SetDisciplinaryActionsView is called.
+// Records are ordered by the Socrata row identifier, which is stable across pages.
+var DisciplinaryActionConfig = sources.SocrataConfig{
+	CacheFilename: DisciplinaryActionJSONFilename,
+	OrderBy:       ":id",
+}
+
+// socrataViewID matches a Socrata "four-by-four" dataset identifier, e.g. "egd5-wb6r"
+var socrataViewID = regexp.MustCompile(`^[a-z0-9]{4}-[a-z0-9]{4}$`)
+
+// SetDisciplinaryActionsView sets the data.ct.gov four-by-four view ID that disciplinary actions are fetched from.
+// Returns an error if the ID is malformed.
+func SetDisciplinaryActionsView(viewID string) error {
+	if !socrataViewID.MatchString(viewID) {
+		return fmt.Errorf("invalid Socrata view ID %q (expected e.g. 'abcd-1234')", viewID)
+	}
+	DisciplinaryActionConfig.URL = "https://data.ct.gov/resource/" + viewID + ".json"
+	return nil
+}
+
+// FetchDisciplinaryActions fetches all CT cannabis disciplinary action data from the CT API
+func FetchDisciplinaryActions(appToken string, maxCacheAge time.Duration) ([]DisciplinaryAction, error) {
+	return FetchDisciplinaryActionsContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchDisciplinaryActionsContext is FetchDisciplinaryActions, aborted once ctx is done, with opts applied to its config
+func FetchDisciplinaryActionsContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]DisciplinaryAction, error) {
+	if DisciplinaryActionConfig.URL == "" {
+		return nil, fmt.Errorf("no disciplinary actions view configured")
+	}
+	return sources.FetchSocrataContext[DisciplinaryAction](ctx, DisciplinaryActionConfig.With(opts...), appToken, maxCacheAge)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// CSVHeaders returns the CSV headers for the DisciplinaryAction struct
+func (d DisciplinaryAction) CSVHeaders() string {
+	return `"license_number","name","credential_type","action_type","action_date","violation","penalty"
+`
+}
+
+// CSVValue returns the CSV value for the DisciplinaryAction struct
+func (d DisciplinaryAction) CSVValue() string {
+	var row sources.CSVRow
+	row.String(d.LicenseNumber)
+	row.String(d.Name)
+	row.String(d.CredentialType)
+	row.String(d.ActionType)
+	row.String(d.ActionDate)
+	row.String(d.Violation)
+	row.String(d.Penalty)
+	return row.Line()
+}
+
+// Columns returns the CSV columns of the DisciplinaryAction struct, for reading its CSV export back with sources.ReadCSV
+func (d DisciplinaryAction) Columns() []sources.Column[DisciplinaryAction] {
+	return []sources.Column[DisciplinaryAction]{
+		sources.StringColumn("license_number", func(d *DisciplinaryAction) *string { return &d.LicenseNumber }),
+		sources.StringColumn("name", func(d *DisciplinaryAction) *string { return &d.Name }),
+		sources.StringColumn("credential_type", func(d *DisciplinaryAction) *string { return &d.CredentialType }),
+		sources.StringColumn("action_type", func(d *DisciplinaryAction) *string { return &d.ActionType }),
+		sources.StringColumn("action_date", func(d *DisciplinaryAction) *string { return &d.ActionDate }),
+		sources.StringColumn("violation", func(d *DisciplinaryAction) *string { return &d.Violation }),
+		sources.StringColumn("penalty", func(d *DisciplinaryAction) *string { return &d.Penalty }),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// DBInsertDisciplinaryActions inserts disciplinary actions into DuckDB
+func DBInsertDisciplinaryActions(conn *sql.DB, actions []DisciplinaryAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+
+	// Clear existing data and insert fresh
+	if _, err := conn.Exec("DELETE FROM ct_disciplinary_actions"); err != nil {
+		return fmt.Errorf("failed to clear disciplinary actions: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`INSERT INTO ct_disciplinary_actions (
+		license_number, name, credential_type, action_type, action_date, violation, penalty
+	) VALUES `)
+
+	for i, d := range actions {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		actionDate := "NULL"
+		if d.ActionDate != "" {
+			actionDate = "'" + sources.SQLString(d.ActionDate) + "'"
+		}
+		sb.WriteString(fmt.Sprintf("('%s','%s','%s','%s',%s,'%s','%s')",
+			sources.SQLString(d.LicenseNumber),
+			sources.SQLString(d.Name),
+			sources.SQLString(d.CredentialType),
+			sources.SQLString(d.ActionType),
+			actionDate,
+			sources.SQLString(d.Violation),
+			sources.SQLString(d.Penalty)))
+	}
+
+	if _, err := conn.Exec(sb.String()); err != nil {
+		return fmt.Errorf("failed to insert disciplinary actions: %w", err)
+	}
+	return nil
+}
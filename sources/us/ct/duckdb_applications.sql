@@ -0,0 +1,18 @@
+-------------------------------------------------------------------------------
+-- Applications (cannabis license applications)
+-------------------------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS ct_applications (
+    application_license_number TEXT NOT NULL,
+    application_credential_status TEXT,
+    status_reason TEXT,
+    sec_review_status TEXT,
+    initial_application_type TEXT,
+    how_selected TEXT,
+    name TEXT,
+    documents_url TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ct_applications_license ON ct_applications (application_license_number);
+CREATE INDEX IF NOT EXISTS ct_applications_status ON ct_applications (application_credential_status);
+CREATE INDEX IF NOT EXISTS ct_applications_type ON ct_applications (initial_application_type);
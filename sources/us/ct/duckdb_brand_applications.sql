@@ -0,0 +1,18 @@
+-------------------------------------------------------------------------------
+-- Brand Applications (brands linked to the license applications of their branding entities)
+-------------------------------------------------------------------------------
+
+-- Brands joined to the license application of each branding entity, matching the
+-- entity to the applicant by name and giving the license number in its canonical form
+CREATE OR REPLACE VIEW ct_brand_applications AS
+SELECT
+    b.registration_number,
+    b.brand_name,
+    b.canonical_name,
+    b.branding_entity,
+    ct_normalize_license(a.application_license_number) AS license_number,
+    a.name AS application_name,
+    a.application_credential_status
+FROM ct_brands b
+LEFT JOIN ct_applications a
+    ON ct_normalize_name(a.name) = ct_normalize_name(b.branding_entity);
@@ -0,0 +1,11 @@
+-------------------------------------------------------------------------------
+-- Credentials (license credential counts by type and status)
+-------------------------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS ct_credentials (
+    credential_type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    count INTEGER
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ct_credentials_type_status ON ct_credentials (credential_type, status);
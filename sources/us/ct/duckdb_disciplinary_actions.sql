@@ -0,0 +1,16 @@
+-------------------------------------------------------------------------------
+-- Disciplinary Actions (enforcement actions against licensees)
+-------------------------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS ct_disciplinary_actions (
+    license_number TEXT,
+    name TEXT,
+    credential_type TEXT,
+    action_type TEXT,
+    action_date DATETIME,
+    violation TEXT,
+    penalty TEXT
+);
+
+CREATE INDEX IF NOT EXISTS ct_disciplinary_actions_license ON ct_disciplinary_actions (license_number);
+CREATE INDEX IF NOT EXISTS ct_disciplinary_actions_date ON ct_disciplinary_actions (action_date);
@@ -0,0 +1,21 @@
+-------------------------------------------------------------------------------
+-- Licensee Discipline (disciplinary actions joined to license applications)
+-------------------------------------------------------------------------------
+
+-- Disciplinary actions joined to the license application of each licensee,
+-- matching license numbers in their canonical form
+CREATE OR REPLACE VIEW ct_licensee_discipline AS
+SELECT
+    d.license_number,
+    d.name,
+    d.credential_type,
+    d.action_type,
+    d.action_date,
+    d.violation,
+    d.penalty,
+    a.name AS application_name,
+    a.application_credential_status,
+    a.initial_application_type
+FROM ct_disciplinary_actions d
+LEFT JOIN ct_applications a
+    ON ct_normalize_license(a.application_license_number) = ct_normalize_license(d.license_number);
@@ -0,0 +1,17 @@
+-------------------------------------------------------------------------------
+-- Tax (monthly tax revenue data)
+-------------------------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS ct_tax (
+    period_end_date DATETIME NOT NULL,
+    month TEXT,
+    year TEXT,
+    fiscal_year TEXT,
+    plant_material_tax DOUBLE,
+    edible_products_tax DOUBLE,
+    other_cannabis_tax DOUBLE,
+    total_tax DOUBLE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ct_tax_period ON ct_tax (period_end_date);
+CREATE INDEX IF NOT EXISTS ct_tax_fiscal_year ON ct_tax (fiscal_year);
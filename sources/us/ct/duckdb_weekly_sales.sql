@@ -0,0 +1,17 @@
+-------------------------------------------------------------------------------
+-- Weekly Sales (weekly retail sales data)
+-------------------------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS ct_weekly_sales (
+    week_ending DATETIME NOT NULL,
+    adult_use DOUBLE,
+    medical DOUBLE,
+    total DOUBLE,
+    adult_use_products_sold INTEGER,
+    medical_products_sold INTEGER,
+    total_products_sold INTEGER,
+    adult_use_avg_price DOUBLE,
+    medical_avg_price DOUBLE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ct_weekly_sales_week ON ct_weekly_sales (week_ending);
@@ -0,0 +1,37 @@
+// Copyright 2026 Neomantra Corp
+
+package ct
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// licenseSpaceRegex matches whitespace, which is stripped from license numbers
+	licenseSpaceRegex = regexp.MustCompile(`\s+`)
+	// licenseDashRegex matches runs of dash-like separators, which become a single '-'
+	licenseDashRegex = regexp.MustCompile(`[-_‐‑‒–—―]+`)
+	// licensePrefixRegex matches a letter prefix run directly into the digits that follow it
+	licensePrefixRegex = regexp.MustCompile(`^([A-Z]+)([0-9])`)
+	// licenseValidRegex matches the canonical "<PREFIX>-<NUMBER>" form, e.g. "RTL-123"
+	licenseValidRegex = regexp.MustCompile(`^[A-Z]{2,}-[0-9]+$`)
+)
+
+// NormalizeLicenseNumber canonicalizes a license number so that variants of it join exactly:
+// it is uppercased, whitespace is stripped, runs of dashes or underscores become a single '-',
+// leading and trailing dashes are trimmed, and a letter prefix is separated from its number
+// by a dash.  For example, " rtl 123", "RTL--123", and "rtl_123" all become "RTL-123".
+// The ct_normalize_license DuckDB macro in DuckDBMigration does the same in SQL.
+func NormalizeLicenseNumber(s string) string {
+	s = licenseSpaceRegex.ReplaceAllString(strings.ToUpper(s), "")
+	s = strings.Trim(licenseDashRegex.ReplaceAllString(s, "-"), "-")
+	return licensePrefixRegex.ReplaceAllString(s, "$1-$2")
+}
+
+// IsValidLicenseNumber returns true if the license number, once normalized, has the
+// "<PREFIX>-<NUMBER>" shape of CT license numbers, e.g. "RTL-123".
+// It checks only the format, not that such a license was ever issued.
+func IsValidLicenseNumber(s string) bool {
+	return licenseValidRegex.MatchString(NormalizeLicenseNumber(s))
+}
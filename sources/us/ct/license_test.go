@@ -0,0 +1,42 @@
+// Copyright 2026 Neomantra Corp
+
+package ct
+
+import "testing"
+
+func TestNormalizeLicenseNumber(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		valid bool
+	}{
+		{in: "RTL-123", want: "RTL-123", valid: true},
+		{in: " rtl 123", want: "RTL-123", valid: true},
+		{in: "RTL--123", want: "RTL-123", valid: true},
+		{in: "rtl_123", want: "RTL-123", valid: true},
+		{in: "RTL – 123", want: "RTL-123", valid: true},
+		{in: "RTL123", want: "RTL-123", valid: true},
+		{in: "-CULT.0001-", want: "CULT.0001", valid: false},
+		{in: "MFG-0012 ", want: "MFG-0012", valid: true},
+		{in: "R-12", want: "R-12", valid: false},
+		{in: "12345", want: "12345", valid: false},
+		{in: "", want: "", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := NormalizeLicenseNumber(tt.in); got != tt.want {
+				t.Errorf("NormalizeLicenseNumber(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if got := IsValidLicenseNumber(tt.in); got != tt.valid {
+				t.Errorf("IsValidLicenseNumber(%q) = %v, want %v", tt.in, got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestBrandKey(t *testing.T) {
+	a, b := Brand{RegistrationNumber: "brand 0042"}, Brand{RegistrationNumber: "BRAND-0042"}
+	if a.Key() != b.Key() {
+		t.Errorf("Key() = %q and %q, want variants of a registration number to key the same brand", a.Key(), b.Key())
+	}
+}
@@ -4,6 +4,7 @@ package ct
 
 import (
 	"bytes"
+	"cmp"
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
@@ -15,9 +16,9 @@ import (
 ///////////////////////////////////////////////////////////////////////////////
 
 // IsTraceMeasurement returns true if the string is considered a trace measure
-// Examples are: "TRC" "<LOQ" and "<0.1"
+// Examples are: "TRC" (in any case) "<LOQ" and "<0.1"
 func IsTraceMeasurement(str string) bool {
-	if str == "TRC" || strings.Contains(str, "LOQ") ||
+	if strings.EqualFold(str, "TRC") || strings.Contains(str, "LOQ") ||
 		strings.HasPrefix(str, "<") {
 		return true
 	}
@@ -46,8 +47,8 @@ func IsErrorMeasurement(str string) bool {
 		return true
 	}
 
-	// Other specific ones have letters in the beginning
-	if len(str) > 0 && isLetter(str[0]) {
+	// Other specific ones have letters in the beginning, other than trace words like "TRC"
+	if len(str) > 0 && isLetter(str[0]) && !IsTraceMeasurement(str) {
 		return true
 	}
 	if str == "0<0.10" || strings.HasPrefix(str, "terpinolene: 1.22") || strings.HasPrefix(str, "a-Ocimene: 1.08") {
@@ -66,16 +67,115 @@ func isLetter(c byte) bool {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+// Unit is the unit of measurement a Measure was reported with
+type Unit uint8
+
+const (
+	UnitNone    Unit = iota // No unit was reported
+	UnitPercent             // Percent, "%"
+	UnitMgPerG              // Milligrams per gram, "mg/g"
+	UnitMg                  // Milligrams, "mg"
+	UnitG                   // Grams, "g"
+	UnitPPM                 // Parts per million, "ppm"
+)
+
+// measureUnitSuffixes maps recognized unit suffixes to their Unit.
+// Longer suffixes are listed first, so "mg/g" is matched before "mg" and "g".
+var measureUnitSuffixes = []struct {
+	suffix string
+	unit   Unit
+}{
+	{"mg/g", UnitMgPerG},
+	{"ppm", UnitPPM},
+	{"mg", UnitMg},
+	{"%", UnitPercent},
+	{"g", UnitG},
+}
+
+// String returns the unit's suffix, or "" for UnitNone
+func (u Unit) String() string {
+	for _, us := range measureUnitSuffixes {
+		if us.unit == u {
+			return us.suffix
+		}
+	}
+	return ""
+}
+
+// splitMeasureUnit separates a recognized unit suffix from a measurement string,
+// tolerating whitespace between the number and the unit.  A suffix is only split off
+// when it follows a number, so words like "TRC" or "LOQ" are left intact, unless it is
+// in parentheses, as in "TRC (mg/g)".
+// Returns the trimmed remainder and the unit, which is UnitNone if there was no suffix.
+func splitMeasureUnit(str string) (string, Unit) {
+	str = strings.TrimSpace(str)
+	if rest, paren, ok := strings.Cut(str, "("); ok && strings.HasSuffix(paren, ")") {
+		for _, us := range measureUnitSuffixes {
+			if strings.EqualFold(strings.TrimSpace(paren[:len(paren)-1]), us.suffix) {
+				return strings.TrimSpace(rest), us.unit
+			}
+		}
+	}
+	for _, us := range measureUnitSuffixes {
+		n := len(str) - len(us.suffix)
+		if n <= 0 || !strings.EqualFold(str[n:], us.suffix) {
+			continue
+		}
+		rest := strings.TrimSpace(str[:n])
+		if last := rest[len(rest)-1]; ('0' <= last && last <= '9') || last == '.' {
+			return rest, us.unit
+		}
+	}
+	return str, UnitNone
+}
+
+// splitMeasureRange splits a range measurement string, e.g. "18.2% - 21.5", into its bounds,
+// each trimmed of whitespace and any unit, and the unit of the range.  The bounds are separated
+// by a hyphen or en dash after the first character, so a leading minus sign is not a separator;
+// each such dash is tried in turn, so either bound may be negative, as in "-1--0.5".
+// Returns false if the string is not two numbers so separated, or their units differ.
+func splitMeasureRange(str string) (lo string, hi string, unit Unit, ok bool) {
+	str = strings.ReplaceAll(str, "–", "-")
+	for i := 1; i < len(str); i++ {
+		if str[i] != '-' {
+			continue
+		}
+		lo, loUnit := splitMeasureUnit(str[:i])
+		hi, hiUnit := splitMeasureUnit(str[i+1:])
+		if lo == "" || hi == "" || (loUnit != hiUnit && loUnit != UnitNone && hiUnit != UnitNone) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(lo, 64); err != nil {
+			continue
+		}
+		if _, err := strconv.ParseFloat(hi, 64); err != nil {
+			continue
+		}
+		return lo, hi, cmp.Or(loUnit, hiUnit), true
+	}
+	return "", "", UnitNone, false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 var (
 	measureEmptySentinel = 0.0          // Sentinel value for Empty, which is nil-value
 	measureZeroSentinel  = math.NaN()   // Sentinel value for Zero, must use IsNan because NaN != NaN
 	measureTraceSentinel = math.Inf(-1) // Sentinel value for Trace
 )
 
-func measureSentinelize(amount float64) float64 {
+// DefaultMeasurePrecision is the number of decimals measures are exported with, unless set with WithPrecision
+const DefaultMeasurePrecision = 6
+
+// MaxMeasurePrecision is the largest number of decimals WithPrecision accepts
+const MaxMeasurePrecision = 15
+
+// measureSentinelize converts an amount to its stored form: zero becomes the zero sentinel,
+// and unless signed, negative amounts become the trace sentinel.
+func measureSentinelize(amount float64, signed bool) float64 {
 	if amount == 0 {
 		return measureZeroSentinel
-	} else if amount < 0 {
+	} else if amount < 0 && !signed {
 		return measureTraceSentinel
 	}
 	return amount
@@ -83,16 +183,62 @@ func measureSentinelize(amount float64) float64 {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-// Measure tracks a measurement, with special flags for no-measurement and trace measurement
+// Measure tracks a measurement, with special flags for no-measurement and trace measurement.
+//
+// By default a Measure is a concentration, which cannot be negative, so negative amounts
+// are read as trace measurements.  A signed Measure, created with NewSignedMeasure, is for
+// domains such as deltas where negative amounts are legitimate, and holds them as-is.
+//
+// A range Measure, created with NewRangeMeasure, is a measurement reported as lying between
+// two amounts.  Its amount is the lower bound, which is what it sorts and exports by, except
+// in JSON, where it is written as {"lo":<amount>,"hi":<amount>}.
 type Measure struct {
 	amount float64 // amount is the amount of the measure, or sentinel values
+	hi     float64 // hi is the upper bound of a range measure, or sentinel zero, or 0 if it is not a range
+	unit   Unit    // unit is the unit the measure was reported in; it is not serialized
+	signed bool    // signed measures keep negative amounts rather than treating them as trace; it is not serialized
+	prec   uint8   // prec is 1 + the decimals to export with, or 0 for DefaultMeasurePrecision; it is not serialized
 }
 
 // NewMeasure creates a new measure with the given amount.
 // Any amount < 0, will be treated as a trace measurement.
 // To create an "empty" Measure object, use nil-initialization Measure{} or NewEmptyMeasure
 func NewMeasure(amount float64) Measure {
-	return Measure{amount: measureSentinelize(amount)}
+	return Measure{amount: measureSentinelize(amount, false)}
+}
+
+// NewSignedMeasure creates a new signed measure with the given amount, which may be negative.
+// Subsequent FromString and Unmarshal calls on it also keep negative amounts, so a signed
+// Measure field may be decoded into by initializing it with NewSignedMeasure(0) first.
+// Only trace strings such as "<LOQ" produce a trace measurement.
+func NewSignedMeasure(amount float64) Measure {
+	return Measure{amount: measureSentinelize(amount, true), signed: true}
+}
+
+// NewRangeMeasure creates a new measure of the range from lo to hi.
+// Unless lo and hi are amounts with lo < hi, it creates NewMeasure(lo) instead.
+func NewRangeMeasure(lo float64, hi float64) Measure {
+	return newRangeMeasure(lo, hi, false)
+}
+
+// NewSignedRangeMeasure creates a new signed measure of the range from lo to hi,
+// either of which may be negative, e.g. a delta of -1 to -0.5.
+// Unless lo < hi, it creates NewSignedMeasure(lo) instead.
+func NewSignedRangeMeasure(lo float64, hi float64) Measure {
+	return newRangeMeasure(lo, hi, true)
+}
+
+// newRangeMeasure creates a measure of the range from lo to hi, negative bounds allowed if signed.
+// An upper bound of 0 is held as the zero sentinel, as a hi of 0 means the measure is not a range.
+func newRangeMeasure(lo float64, hi float64, signed bool) Measure {
+	m := Measure{amount: measureSentinelize(lo, signed), signed: signed}
+	if (signed || lo >= 0) && hi > lo {
+		m.hi = hi
+		if hi == 0 {
+			m.hi = measureZeroSentinel
+		}
+	}
+	return m
 }
 
 // NewEmptyMeasure creates a new "empty" measure.
@@ -106,6 +252,56 @@ func NewTraceMeasure() Measure {
 	return Measure{amount: measureTraceSentinel}
 }
 
+// Unit returns the unit the measure was reported in, or UnitNone if unknown.
+// The unit is only known for measures parsed from strings; it is not serialized.
+func (m Measure) Unit() Unit {
+	return m.unit
+}
+
+// WithUnit returns a copy of the measure with the given unit
+func (m Measure) WithUnit(unit Unit) Measure {
+	m.unit = unit
+	return m
+}
+
+// Precision returns the number of decimals the measure's amount is exported with
+func (m Measure) Precision() int {
+	if m.prec == 0 {
+		return DefaultMeasurePrecision
+	}
+	return int(m.prec) - 1
+}
+
+// WithPrecision returns a copy of the measure that exports its amount rounded to the given
+// number of decimals, clamped to [0, MaxMeasurePrecision], in CSV, JSON, and text.
+// The stored amount is unchanged, so SQL values and Amount keep full precision.
+func (m Measure) WithPrecision(decimals int) Measure {
+	m.prec = uint8(min(max(decimals, 0), MaxMeasurePrecision)) + 1
+	return m
+}
+
+// IsSigned returns true if the measure keeps negative amounts, as created by NewSignedMeasure
+func (m Measure) IsSigned() bool {
+	return m.signed
+}
+
+// IsRange returns true if the measure is a range, as created by NewRangeMeasure
+func (m Measure) IsRange() bool {
+	return m.hi != 0
+}
+
+// Range returns the lower and upper bounds of a range measure, and whether it is one
+func (m Measure) Range() (lo float64, hi float64, ok bool) {
+	if !m.IsRange() {
+		return 0, 0, false
+	}
+	lo, _, _ = m.Amount()
+	if math.IsNaN(m.hi) {
+		return lo, 0, true
+	}
+	return lo, m.hi, true
+}
+
 // IsEmpty returns true if the measure is empty (no measurement)
 func (m Measure) IsEmpty() bool {
 	return m.amount == measureEmptySentinel
@@ -134,6 +330,16 @@ func (m Measure) Amount() (result float64, trace bool, empty bool) {
 	}
 }
 
+// Ptr returns a pointer to the measure's amount, or nil if it is empty or trace,
+// matching the NULLs of Value for consumers that treat a nil *float64 as null
+func (m Measure) Ptr() *float64 {
+	if m.IsTrace() || m.IsEmpty() {
+		return nil
+	}
+	amount, _, _ := m.Amount()
+	return &amount
+}
+
 // IsValidPercent returns true if the measure is a valid percentage (0-100)
 func (m Measure) IsValidPercent() bool {
 	if m.IsZero() || m.IsEmpty() || m.IsTrace() {
@@ -142,20 +348,91 @@ func (m Measure) IsValidPercent() bool {
 	return m.amount >= 0 && m.amount <= 100
 }
 
+// sortRank returns the measure's rank among the sentinels: empty < trace < zero < scalar amounts
+func (m Measure) sortRank() int {
+	switch {
+	case m.IsEmpty():
+		return 0
+	case m.IsTrace():
+		return 1
+	case m.IsZero():
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Compare returns -1, 0, or +1 depending on whether m sorts before, with, or after other.
+// Sentinels sort below all scalar amounts, ordered empty < trace < zero < scalars,
+// including the negative amounts of signed measures, since comparing the NaN and -Inf sentinel values directly does not order them sanely.
+// The unit is not considered.
+func (m Measure) Compare(other Measure) int {
+	mr, or := m.sortRank(), other.sortRank()
+	switch {
+	case mr < or:
+		return -1
+	case mr > or:
+		return 1
+	case mr < 3:
+		return 0
+	case m.amount < other.amount:
+		return -1
+	case m.amount > other.amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Less returns true if m sorts before other, according to Compare
+func (m Measure) Less(other Measure) bool {
+	return m.Compare(other) < 0
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
-// FromString modifies the given measure based on the passed string
+// FromString modifies the given measure based on the passed string.
+// A recognized unit suffix ("%", "mg/g", "mg", "g", "ppm"), optionally separated
+// from the number by whitespace or in parentheses, is stripped and recorded as the measure's Unit.
+// The string is then read as the first of these that it is:
+//
+//  1. empty, per IsEmptyMeasurement, e.g. "" or "-"
+//  2. erroneous, per IsErrorMeasurement, e.g. "1.1.", which is read as empty;
+//     a range is erroneous if either of its bounds is
+//  3. trace, per IsTraceMeasurement, e.g. "TRC (mg/g)" or "< 0.1 mg/g"
+//  4. a range of two numbers, e.g. "18.2% - 21.5%", as with NewRangeMeasure,
+//     or NewSignedRangeMeasure if the measure is signed, e.g. "-1--0.5"
+//  5. a number, e.g. "12.5 mg/g", with any leading ">" stripped
+//
+// Returns an error if the string is none of these.
 func (m *Measure) FromString(str string) error {
+	str, unit := splitMeasureUnit(str)
+	m.unit = UnitNone
+	m.hi = 0
+
 	if IsEmptyMeasurement(str) {
 		m.amount = measureEmptySentinel
 		return nil
 	}
-	if IsErrorMeasurement(str) {
+	lo, hi, rangeUnit, isRange := splitMeasureRange(str)
+	if isRange && unit != UnitNone && rangeUnit != UnitNone && unit != rangeUnit {
+		isRange = false // the bounds' units differ, so it is not a number
+	}
+	if (isRange && (IsErrorMeasurement(lo) || IsErrorMeasurement(hi))) || (!isRange && IsErrorMeasurement(str)) {
 		m.amount = measureEmptySentinel
 		return nil
 	}
 	if IsTraceMeasurement(str) {
 		m.amount = measureTraceSentinel
+		m.unit = unit
+		return nil
+	}
+	if isRange {
+		loVal, _ := strconv.ParseFloat(lo, 64)
+		hiVal, _ := strconv.ParseFloat(hi, 64)
+		r := newRangeMeasure(loVal, hiVal, m.signed)
+		m.amount, m.hi = r.amount, r.hi
+		m.unit = cmp.Or(unit, rangeUnit)
 		return nil
 	}
 
@@ -163,15 +440,14 @@ func (m *Measure) FromString(str string) error {
 	str = strings.TrimPrefix(str, ",")
 	// Strip leading >
 	str = strings.TrimPrefix(str, ">")
-	// Remove percentages
-	str = strings.TrimSuffix(str, "%")
 
 	val, err := strconv.ParseFloat(str, 64)
 	if err != nil {
 		return err
 	}
 
-	m.amount = measureSentinelize(val)
+	m.amount = measureSentinelize(val, m.signed)
+	m.unit = unit
 	return nil
 }
 
@@ -183,10 +459,10 @@ func (m Measure) AsSQL() string {
 	if m.IsZero() {
 		return "0"
 	}
-	return fmt.Sprintf("%f", m.amount)
+	return strconv.FormatFloat(m.amount, 'f', 6, 64)
 }
 
-// AsCSV converts the measure to "" or "<amount>".
+// AsCSV converts the measure to "" or "<amount>", with the measure's Precision.
 func (m Measure) AsCSV() string {
 	if m.IsEmpty() || m.IsTrace() {
 		return ""
@@ -194,27 +470,68 @@ func (m Measure) AsCSV() string {
 	if m.IsZero() {
 		return "0"
 	}
-	return fmt.Sprintf("%f", m.amount)
+	return strconv.FormatFloat(m.amount, 'f', m.Precision(), 64)
+}
+
+// AppendCSV appends the measure as AsCSV formats it to dst, returning the extended buffer
+func (m Measure) AppendCSV(dst []byte) []byte {
+	if m.IsEmpty() || m.IsTrace() {
+		return dst
+	}
+	if m.IsZero() {
+		return append(dst, '0')
+	}
+	return strconv.AppendFloat(dst, m.amount, 'f', m.Precision(), 64)
+}
+
+// String returns the measure in a readable form that FromString parses back:
+// "-" if empty, "<LOQ" if trace, "0" if zero, or else the amount with its unit, e.g. "12.5%".
+func (m Measure) String() string {
+	switch {
+	case m.IsEmpty():
+		return "-"
+	case m.IsTrace():
+		return "<LOQ"
+	case m.IsZero():
+		return "0"
+	default:
+		return strconv.FormatFloat(m.amount, 'f', -1, 64) + m.unit.String()
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Marshalling
 
-// MarshalJSON converts the measure to JSON
-func (m *Measure) MarshalJSON() ([]byte, error) {
+// MarshalJSON converts the measure to JSON, with the measure's Precision.
+// A range is written as an object of its bounds, {"lo":<amount>,"hi":<amount>}.
+// It has a value receiver so that encoding/json uses it, rather than MarshalText,
+// even when the Measure is not addressable.
+func (m Measure) MarshalJSON() ([]byte, error) {
+	return m.AppendJSON(nil), nil
+}
+
+// AppendJSON appends the measure as MarshalJSON encodes it to dst, returning the extended buffer
+func (m Measure) AppendJSON(dst []byte) []byte {
+	if lo, hi, ok := m.Range(); ok {
+		dst = strconv.AppendFloat(append(dst, `{"lo":`...), lo, 'f', m.Precision(), 64)
+		dst = strconv.AppendFloat(append(dst, `,"hi":`...), hi, 'f', m.Precision(), 64)
+		return append(dst, '}')
+	}
 	if m.IsEmpty() {
-		return []byte("null"), nil
+		return append(dst, "null"...)
 	} else if m.IsZero() {
-		return []byte("0"), nil
+		return append(dst, '0')
 	} else if m.IsTrace() {
-		return []byte(`"<0.01"`), nil
+		return append(dst, `"<0.01"`...)
 	} else {
-		return []byte(fmt.Sprintf("%f", m.amount)), nil
+		return strconv.AppendFloat(dst, m.amount, 'f', m.Precision(), 64)
 	}
 }
 
-// UnmarshalJSON converts the measure from JSON
+// UnmarshalJSON converts the measure from JSON: null, a string that FromString parses,
+// a number, or a range object as written by MarshalJSON.
 func (m *Measure) UnmarshalJSON(b []byte) error {
+	m.hi = 0
 	if bytes.Equal(b, []byte("null")) {
 		m.amount = measureEmptySentinel
 		return nil
@@ -228,7 +545,23 @@ func (m *Measure) UnmarshalJSON(b []byte) error {
 
 	var val float64
 	if err := json.Unmarshal(b, &val); err == nil {
-		m.amount = measureSentinelize(val)
+		m.amount = measureSentinelize(val, m.signed)
+		return nil
+	}
+
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+		var bounds struct {
+			Lo *float64 `json:"lo"`
+			Hi *float64 `json:"hi"`
+		}
+		if err := json.Unmarshal(b, &bounds); err != nil {
+			return fmt.Errorf("failed to unmarshal measure range: %w", err)
+		}
+		if bounds.Lo == nil || bounds.Hi == nil {
+			return fmt.Errorf("failed to unmarshal measure range: needs both lo and hi")
+		}
+		r := newRangeMeasure(*bounds.Lo, *bounds.Hi, m.signed)
+		m.amount, m.hi = r.amount, r.hi
 		return nil
 	}
 	return fmt.Errorf("failed to unmarshal measure: %w", err)
@@ -249,6 +582,7 @@ func (m Measure) Value() (driver.Value, error) {
 func (m *Measure) UnmarshalCSV(value string) error {
 	if value == "" {
 		m.amount = measureEmptySentinel
+		m.hi = 0
 		return nil
 	}
 	return m.FromString(value)
@@ -258,3 +592,17 @@ func (m *Measure) UnmarshalCSV(value string) error {
 func (m Measure) MarshalCSV() (string, error) {
 	return m.AsCSV(), nil
 }
+
+// MarshalText implements encoding.TextMarshaler, matching MarshalCSV except that
+// a trace measure is "<0.01" rather than "", so that it survives a round-trip.
+func (m Measure) MarshalText() ([]byte, error) {
+	if m.IsTrace() {
+		return []byte("<0.01"), nil
+	}
+	return []byte(m.AsCSV()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting anything FromString does
+func (m *Measure) UnmarshalText(text []byte) error {
+	return m.UnmarshalCSV(string(text))
+}
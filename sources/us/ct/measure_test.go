@@ -0,0 +1,121 @@
+// Copyright 2026 Neomantra Corp
+
+package ct
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// benchMeasureStrings are measures as CT brand records report them
+var benchMeasureStrings = []string{"12.5", "0.35%", "<LOQ", "", "TRC (mg/g)", "18.2% - 21.5%", "1.1.", "< 0.1 mg/g", "210 mg/g", "0"}
+
+func BenchmarkMeasureFromString(b *testing.B) {
+	b.ReportAllocs()
+	var m Measure
+	for i := 0; i < b.N; i++ {
+		m.FromString(benchMeasureStrings[i%len(benchMeasureStrings)])
+	}
+}
+
+func BenchmarkMeasureAsCSV(b *testing.B) {
+	b.ReportAllocs()
+	m := NewMeasure(21.456)
+	for i := 0; i < b.N; i++ {
+		_ = m.AsCSV()
+	}
+}
+
+func TestMeasureFromString(t *testing.T) {
+	tests := []struct {
+		in        string
+		signed    bool
+		empty     bool
+		trace     bool
+		amount    float64
+		hi        float64 // upper bound, if a range
+		isRange   bool
+		unit      Unit
+		wantError bool
+	}{
+		{in: "", empty: true},
+		{in: "-", empty: true},
+		{in: "1.1.", empty: true},
+		{in: "12.5", amount: 12.5},
+		{in: "0.35%", amount: 0.35, unit: UnitPercent},
+		{in: "210 mg/g", amount: 210, unit: UnitMgPerG},
+		{in: ">5", amount: 5},
+		{in: "0", amount: 0},
+		{in: "<LOQ", trace: true},
+		{in: "TRC (mg/g)", trace: true, unit: UnitMgPerG},
+		{in: "< 0.1 mg/g", trace: true, unit: UnitMgPerG},
+		{in: "-2", trace: true},
+		{in: "-2", signed: true, amount: -2},
+		{in: "18.2% - 21.5%", amount: 18.2, hi: 21.5, isRange: true, unit: UnitPercent},
+		{in: "18.2 – 21.5", amount: 18.2, hi: 21.5, isRange: true},
+		{in: "1.0 - 2.0%", amount: 1, hi: 2, isRange: true, unit: UnitPercent},
+		{in: "1.0% - 2.0 mg/g", wantError: true},
+		{in: "21.5 - 18.2", wantError: true},
+		{in: "-0.5-1.2", trace: true},
+		{in: "-0.5-1.2", signed: true, amount: -0.5, hi: 1.2, isRange: true},
+		{in: "-1--0.5", signed: true, amount: -1, hi: -0.5, isRange: true},
+		{in: "-1 - 0", signed: true, amount: -1, hi: 0, isRange: true},
+		{in: "1e-3-2e-3", amount: 0.001, hi: 0.002, isRange: true},
+		{in: "abc", empty: true},
+	}
+	for _, tt := range tests {
+		name := tt.in
+		if tt.signed {
+			name += " signed"
+		}
+		t.Run(name, func(t *testing.T) {
+			m := NewMeasure(0)
+			if tt.signed {
+				m = NewSignedMeasure(0)
+			}
+			err := m.FromString(tt.in)
+			if tt.wantError {
+				if err == nil && m.IsRange() {
+					t.Errorf("FromString(%q) = range %v, want an error or not a range", tt.in, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromString(%q) = %v", tt.in, err)
+			}
+			amount, trace, empty := m.Amount()
+			if empty != tt.empty || trace != tt.trace || amount != tt.amount {
+				t.Errorf("FromString(%q) Amount() = %v, %v, %v; want %v, %v, %v", tt.in, amount, trace, empty, tt.amount, tt.trace, tt.empty)
+			}
+			lo, hi, isRange := m.Range()
+			if isRange != tt.isRange || (isRange && (lo != tt.amount || hi != tt.hi)) {
+				t.Errorf("FromString(%q) Range() = %v, %v, %v; want %v, %v, %v", tt.in, lo, hi, isRange, tt.amount, tt.hi, tt.isRange)
+			}
+			if m.Unit() != tt.unit {
+				t.Errorf("FromString(%q) Unit() = %v, want %v", tt.in, m.Unit(), tt.unit)
+			}
+		})
+	}
+}
+
+func TestSignedRangeMeasureJSON(t *testing.T) {
+	m := NewSignedRangeMeasure(-1, -0.5)
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"lo":-1.000000,"hi":-0.500000}`; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+
+	got := NewSignedMeasure(0)
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if lo, hi, ok := got.Range(); !ok || lo != -1 || hi != -0.5 {
+		t.Errorf("Unmarshal(%s) Range() = %v, %v, %v; want -1, -0.5, true", b, lo, hi, ok)
+	}
+	if r := NewRangeMeasure(-1, -0.5); r.IsRange() {
+		t.Errorf("NewRangeMeasure(-1, -0.5) = range %v, want an unsigned measure's trace", r)
+	}
+}
@@ -2,7 +2,105 @@
 
 package ct
 
-import _ "embed"
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+)
 
+// DuckDBMigration creates the objects shared by every CT table, such as macros
+//
 //go:embed duckdb_up.sql
 var DuckDBMigration string
+
+var (
+	//go:embed duckdb_brands.sql
+	brandsDDL string
+	//go:embed duckdb_credentials.sql
+	credentialsDDL string
+	//go:embed duckdb_applications.sql
+	applicationsDDL string
+	//go:embed duckdb_weekly_sales.sql
+	weeklySalesDDL string
+	//go:embed duckdb_tax.sql
+	taxDDL string
+	//go:embed duckdb_disciplinary_actions.sql
+	disciplinaryActionsDDL string
+	//go:embed duckdb_licensee_discipline.sql
+	licenseeDisciplineDDL string
+	//go:embed duckdb_brand_applications.sql
+	brandApplicationsDDL string
+)
+
+// DuckDBTable describes a table created by DuckDBMigrationFor
+type DuckDBTable struct {
+	Name string   // Table name
+	Key  []string // Natural key columns, as enforced by the table's unique index; nil if it has none
+	// KeepRows is true if loads add to the table's existing rows, skipping rows whose key it already has,
+	// rather than clearing and reloading it
+	KeepRows bool
+	DDL      string // Statements creating the table and its indexes
+}
+
+// DuckDBTables are all the tables created by DuckDBMigrationFor
+var DuckDBTables = []DuckDBTable{
+	{Name: "ct_brands", Key: []string{"registration_number"}, KeepRows: true, DDL: brandsDDL},
+	{Name: "ct_credentials", Key: []string{"credential_type", "status"}, DDL: credentialsDDL},
+	{Name: "ct_applications", Key: []string{"application_license_number"}, DDL: applicationsDDL},
+	{Name: "ct_weekly_sales", Key: []string{"week_ending"}, DDL: weeklySalesDDL},
+	{Name: "ct_tax", Key: []string{"period_end_date"}, DDL: taxDDL},
+	{Name: "ct_disciplinary_actions", DDL: disciplinaryActionsDDL},
+}
+
+// DuckDBView describes a view created by DuckDBMigrationFor
+type DuckDBView struct {
+	Name   string   // View name
+	Tables []string // Tables the view selects from, all of which must be created for it to be
+	DDL    string   // Statement creating the view
+}
+
+// DuckDBViews are all the views created by DuckDBMigrationFor
+var DuckDBViews = []DuckDBView{
+	{Name: "ct_licensee_discipline", Tables: []string{"ct_disciplinary_actions", "ct_applications"}, DDL: licenseeDisciplineDDL},
+	{Name: "ct_brand_applications", Tables: []string{"ct_brands", "ct_applications"}, DDL: brandApplicationsDDL},
+}
+
+// DuckDBMigrationFor returns the statements creating the named DuckDBTables, after DuckDBMigration,
+// along with the DuckDBViews over only those tables.  Nil tables creates every table and view.
+// Returns an error if a table is not one of the DuckDBTables.
+func DuckDBMigrationFor(tables []string) (string, error) {
+	for _, name := range tables {
+		if !slices.ContainsFunc(DuckDBTables, func(t DuckDBTable) bool { return t.Name == name }) {
+			return "", fmt.Errorf("unknown table %q, expected one of: %s", name, strings.Join(DuckDBTableNames(), ", "))
+		}
+	}
+	created := func(name string) bool {
+		return tables == nil || slices.Contains(tables, name)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(DuckDBMigration)
+	for _, table := range DuckDBTables {
+		if created(table.Name) {
+			sb.WriteString("\n")
+			sb.WriteString(table.DDL)
+		}
+	}
+	for _, view := range DuckDBViews {
+		if !slices.ContainsFunc(view.Tables, func(name string) bool { return !created(name) }) {
+			sb.WriteString("\n")
+			sb.WriteString(view.DDL)
+		}
+	}
+	return sb.String(), nil
+}
+
+// DuckDBTableNames returns the names of the DuckDBTables, in order
+func DuckDBTableNames() []string {
+	names := make([]string, len(DuckDBTables))
+	for i, table := range DuckDBTables {
+		names[i] = table.Name
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Neomantra Corp
+//
+// Statistical outlier detection for CT brand measures
+
+package ct
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// MeasureStats summarizes the amounts of one measure across brands.
+// Only measures with an amount, including zero, are counted; empty and trace measures are not.
+type MeasureStats struct {
+	Count  int     // Number of measures with an amount
+	Mean   float64 // Mean amount
+	StdDev float64 // Population standard deviation of the amounts
+	Min    float64 // Smallest amount
+	Max    float64 // Largest amount
+}
+
+// SummarizeMeasures returns the statistics of the measure chosen by sel across the brands
+func SummarizeMeasures(brands []Brand, sel func(Brand) Measure) MeasureStats {
+	var stats MeasureStats
+	var sum float64
+	for _, b := range brands {
+		amount, trace, empty := sel(b).Amount()
+		if trace || empty {
+			continue
+		}
+		if stats.Count == 0 {
+			stats.Min, stats.Max = amount, amount
+		}
+		stats.Min = min(stats.Min, amount)
+		stats.Max = max(stats.Max, amount)
+		sum += amount
+		stats.Count++
+	}
+	if stats.Count == 0 {
+		return stats
+	}
+	stats.Mean = sum / float64(stats.Count)
+
+	var squares float64
+	for _, b := range brands {
+		if amount, trace, empty := sel(b).Amount(); !trace && !empty {
+			squares += (amount - stats.Mean) * (amount - stats.Mean)
+		}
+	}
+	stats.StdDev = math.Sqrt(squares / float64(stats.Count))
+	return stats
+}
+
+// ZScore returns how many standard deviations amount is from the mean, or 0 if the amounts do not vary
+func (s MeasureStats) ZScore(amount float64) float64 {
+	if s.StdDev == 0 {
+		return 0
+	}
+	return (amount - s.Mean) / s.StdDev
+}
+
+// FlagOutliers returns the indices of the brands whose measure chosen by sel is more than sigma
+// standard deviations from the mean of that measure, per SummarizeMeasures.
+// Empty and trace measures are never outliers.  Because the outliers themselves contribute to
+// the statistics, a lone outlier among n measures is at most (n-1)/sqrt(n) deviations away.
+func FlagOutliers(brands []Brand, sel func(Brand) Measure, sigma float64) []int {
+	stats := SummarizeMeasures(brands, sel)
+	var indices []int
+	for i, b := range brands {
+		amount, trace, empty := sel(b).Amount()
+		if trace || empty {
+			continue
+		}
+		if math.Abs(stats.ZScore(amount)) > sigma {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// OutlierMode selects what FlagBrandOutliers does with outlying brands
+type OutlierMode string
+
+const (
+	OutlierNone   OutlierMode = ""       // Do not look for outliers
+	OutlierReport OutlierMode = "report" // Report outliers, keeping the brands
+	OutlierDrop   OutlierMode = "drop"   // Report outliers and remove the brands
+)
+
+// FlagBrandOutliers looks for outliers in every brand measure column with FlagOutliers.
+// Returns the brands, less those with any outlier if mode is OutlierDrop, and a CleanReport
+// for each outlying measure, ordered by column.
+func FlagBrandOutliers(brands []Brand, sigma float64, mode OutlierMode) ([]Brand, []sources.CleanReport) {
+	if mode == OutlierNone {
+		return brands, nil
+	}
+
+	var reports []sources.CleanReport
+	outlying := make(map[int]bool)
+	for _, nm := range (&Brand{}).Measures() {
+		sel, err := BrandMeasureSelector(nm.Column)
+		if err != nil {
+			continue
+		}
+		stats := SummarizeMeasures(brands, sel)
+		for _, i := range FlagOutliers(brands, sel, sigma) {
+			outlying[i] = true
+			amount, _, _ := sel(brands[i]).Amount()
+			reports = append(reports, sources.CleanReport{
+				Dataset: "brands",
+				Record:  brands[i].RegistrationNumber,
+				Field:   nm.Column,
+				Action:  string(mode),
+				Detail: fmt.Sprintf("outlier %s is %.1f standard deviations from the mean %s of %d measures",
+					sel(brands[i]).AsCSV(), stats.ZScore(amount), NewMeasure(stats.Mean).AsCSV(), stats.Count),
+			})
+		}
+	}
+
+	if mode != OutlierDrop || len(outlying) == 0 {
+		return brands, reports
+	}
+	kept := make([]Brand, 0, len(brands)-len(outlying))
+	for i, b := range brands {
+		if !outlying[i] {
+			kept = append(kept, b)
+		}
+	}
+	return kept, reports
+}
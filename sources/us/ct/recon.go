@@ -0,0 +1,137 @@
+// Copyright 2026 Neomantra Corp
+//
+// CT Cannabis Sales and Tax Reconciliation
+
+package ct
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+const (
+	ReconJSONFilename = "us_ct_sales_tax_reconciliation.json"
+	ReconCSVFilename  = "us_ct_sales_tax_reconciliation.csv"
+)
+
+// Effective tax rates outside [ReconMinPlausibleRate, ReconMaxPlausibleRate] are flagged as implausible
+var (
+	ReconMinPlausibleRate = 0.005
+	ReconMaxPlausibleRate = 0.30
+)
+
+// Reconciliation flags, explaining why a ReconRow may not be trustworthy
+const (
+	ReconFlagNoSales      = "no_sales"      // Tax was collected for a month with no sales data
+	ReconFlagNoTax        = "no_tax"        // Sales were reported for a month with no tax data
+	ReconFlagPartialSales = "partial_sales" // Sales weeks cover only part of the month
+	ReconFlagImplausible  = "implausible"   // The effective tax rate is outside the plausible range
+)
+
+// ReconRow reconciles one month of sales against the tax collected for it
+type ReconRow struct {
+	Month         time.Time `json:"month"`          // First day of the month
+	SalesTotal    float64   `json:"sales_total"`    // Total sales, from weeks prorated by day into the month
+	SalesDays     int       `json:"sales_days"`     // Days of the month covered by sales weeks
+	TaxTotal      float64   `json:"tax_total"`      // Total tax for the month
+	EffectiveRate float64   `json:"effective_rate"` // TaxTotal / SalesTotal, or 0 if either is missing
+	Flag          string    `json:"flag"`           // Empty, or one of the ReconFlag values
+}
+
+// ReconcileSalesTax rolls weekly sales up into months and joins them with monthly tax by period,
+// computing the implied effective tax rate.  Each week is taken to be the seven days ending on
+// its WeekEnding, and its total is prorated by day into the months those days fall in.
+// Months present in only one dataset are included and flagged.  Rows are sorted by month.
+// Returns an error if a record's period or amount cannot be parsed.
+func ReconcileSalesTax(sales []WeeklySales, taxes []Tax) ([]ReconRow, error) {
+	byMonth := make(map[time.Time]*ReconRow)
+	rowFor := func(month time.Time) *ReconRow {
+		row, ok := byMonth[month]
+		if !ok {
+			row = &ReconRow{Month: month}
+			byMonth[month] = row
+		}
+		return row
+	}
+
+	hasSales := make(map[time.Time]bool)
+	for _, s := range sales {
+		weekEnding, err := s.WeekEndingTime()
+		if err != nil {
+			return nil, err
+		}
+		total, err := s.Total.Float()
+		if err != nil {
+			return nil, fmt.Errorf("invalid sales total %q for week ending %s: %w", s.Total, s.WeekEnding, err)
+		}
+		end := time.Date(weekEnding.Year(), weekEnding.Month(), weekEnding.Day(), 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 7; i++ {
+			day := end.AddDate(0, 0, -i)
+			row := rowFor(time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC))
+			row.SalesTotal += total / 7
+			row.SalesDays++
+			hasSales[row.Month] = true
+		}
+	}
+
+	hasTax := make(map[time.Time]bool)
+	for _, t := range taxes {
+		month, err := t.PeriodMonth()
+		if err != nil {
+			return nil, err
+		}
+		total, err := t.TotalTax.Float()
+		if err != nil {
+			return nil, fmt.Errorf("invalid total tax %q for %s: %w", t.TotalTax, month.Format("2006-01"), err)
+		}
+		rowFor(month).TaxTotal += total
+		hasTax[month] = true
+	}
+
+	rows := make([]ReconRow, 0, len(byMonth))
+	for _, row := range byMonth {
+		daysInMonth := row.Month.AddDate(0, 1, -1).Day()
+		switch {
+		case !hasSales[row.Month]:
+			row.Flag = ReconFlagNoSales
+		case !hasTax[row.Month]:
+			row.Flag = ReconFlagNoTax
+		case row.SalesDays < daysInMonth:
+			row.Flag = ReconFlagPartialSales
+		}
+		if hasSales[row.Month] && hasTax[row.Month] && row.SalesTotal > 0 {
+			row.EffectiveRate = row.TaxTotal / row.SalesTotal
+			if row.Flag == "" && (row.EffectiveRate < ReconMinPlausibleRate || row.EffectiveRate > ReconMaxPlausibleRate) {
+				row.Flag = ReconFlagImplausible
+			}
+		}
+		rows = append(rows, *row)
+	}
+	slices.SortFunc(rows, func(a, b ReconRow) int {
+		return a.Month.Compare(b.Month)
+	})
+	return rows, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// CSVHeaders returns the CSV headers for the ReconRow struct
+func (r ReconRow) CSVHeaders() string {
+	return `"month","sales_total","sales_days","tax_total","effective_rate","flag"
+`
+}
+
+// CSVValue returns the CSV value for the ReconRow struct
+func (r ReconRow) CSVValue() string {
+	var row sources.CSVRow
+	row.Time(r.Month, "2006-01")
+	row.Float(r.SalesTotal, 2)
+	row.Int(r.SalesDays)
+	row.Float(r.TaxTotal, 2)
+	row.Float(r.EffectiveRate, 6)
+	row.String(r.Flag)
+	return row.Line()
+}
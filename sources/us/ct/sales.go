@@ -8,8 +8,12 @@
 package ct
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -17,22 +21,197 @@ import (
 )
 
 const (
-	WeeklySalesJSONFilename = "us_ct_weekly_sales.json"
-	WeeklySalesCSVFilename  = "us_ct_weekly_sales.csv"
-	WeeklySalesURL          = "https://data.ct.gov/resource/ucaf-96h6.json"
+	WeeklySalesJSONFilename        = "us_ct_weekly_sales.json"
+	WeeklySalesCSVFilename         = "us_ct_weekly_sales.csv"
+	WeeklySalesURL                 = "https://data.ct.gov/resource/ucaf-96h6.json"
+	WeeklySalesCleanReportFilename = "us_ct_weekly_sales_clean_report.csv"
 )
 
+// SalesTotalTolerance is the largest discrepancy, in dollars, that TotalReconciles treats as a match:
+// half a cent, so totals that only differ from the sum of their parts by float rounding match
+const SalesTotalTolerance = 0.005
+
 // WeeklySales represents a CT cannabis weekly retail sales record
 type WeeklySales struct {
-	WeekEnding                   string `json:"unnamed_column"` // ISO 8601 datetime
-	AdultUse                     string `json:"adult_use"`
-	Medical                      string `json:"medical"`
-	Total                        string `json:"total"`
-	AdultUseProductsSold         string `json:"adult_use_products_sold"`
-	MedicalProductsSold          string `json:"medical_products_sold"`
-	TotalProductsSold            string `json:"total_products_sold"`
-	AdultUseCannabisAveragePrice string `json:"adult_use_cannabis_average_product_price"`
-	MedicalMarijuanaAveragePrice string `json:"medical_marijuana_average_product_price"`
+	WeekEnding                   string            `json:"unnamed_column"` // ISO 8601 datetime
+	AdultUse                     sources.FlexFloat `json:"adult_use"`
+	Medical                      sources.FlexFloat `json:"medical"`
+	Total                        sources.FlexFloat `json:"total"`
+	AdultUseProductsSold         sources.FlexInt   `json:"adult_use_products_sold"`
+	MedicalProductsSold          sources.FlexInt   `json:"medical_products_sold"`
+	TotalProductsSold            sources.FlexInt   `json:"total_products_sold"`
+	AdultUseCannabisAveragePrice sources.FlexFloat `json:"adult_use_cannabis_average_product_price"`
+	MedicalMarijuanaAveragePrice sources.FlexFloat `json:"medical_marijuana_average_product_price"`
+}
+
+// WeekEndingAliases are the JSON keys accepted for WeekEnding, in order of preference.
+// The portal has published the week's date as an unnamed column, and may yet name it.
+var WeekEndingAliases = []string{"unnamed_column", "week_ending", "date"}
+
+// UnmarshalJSON unmarshals a record, taking WeekEnding from the first of WeekEndingAliases
+// that is present and not empty.  Records are always marshaled with "unnamed_column".
+func (s *WeeklySales) UnmarshalJSON(data []byte) error {
+	type weeklySales WeeklySales // without this method, to unmarshal the other fields
+	var sales weeklySales
+	if err := json.Unmarshal(data, &sales); err != nil {
+		return err
+	}
+	var aliases struct {
+		WeekEnding string `json:"week_ending"`
+		Date       string `json:"date"`
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return err
+	}
+	sales.WeekEnding = cmp.Or(sales.WeekEnding, aliases.WeekEnding, aliases.Date)
+	*s = WeeklySales(sales)
+	return nil
+}
+
+// WeekEndingTime returns the last day of the sales week, parsed from WeekEnding with sources.ParseSocrataTime
+func (s WeeklySales) WeekEndingTime() (time.Time, error) {
+	weekEnding, err := sources.ParseSocrataTime(s.WeekEnding)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid week ending %q: %w", s.WeekEnding, err)
+	}
+	return weekEnding, nil
+}
+
+// TotalReconciles returns whether Total matches AdultUse plus Medical, within SalesTotalTolerance,
+// and the discrepancy, Total minus the sum.  Records missing any of the three, or with one that
+// is not a number, cannot be checked, so reconcile with no discrepancy.
+func (s WeeklySales) TotalReconciles() (bool, float64) {
+	if s.AdultUse == "" || s.Medical == "" || s.Total == "" {
+		return true, 0
+	}
+	adultUse, err1 := s.AdultUse.Float()
+	medical, err2 := s.Medical.Float()
+	total, err3 := s.Total.Float()
+	if err1 != nil || err2 != nil || err3 != nil {
+		return true, 0
+	}
+	discrepancy := total - (adultUse + medical)
+	return math.Abs(discrepancy) <= SalesTotalTolerance, discrepancy
+}
+
+// CheckSalesTotals returns a CleanReport for each week whose Total differs from AdultUse plus
+// Medical, per TotalReconciles, by more than threshold dollars, or SalesTotalTolerance if greater.  The records are left as they are.
+func CheckSalesTotals(sales []WeeklySales, threshold float64) []sources.CleanReport {
+	var reports []sources.CleanReport
+	for _, s := range sales {
+		if ok, discrepancy := s.TotalReconciles(); !ok && math.Abs(discrepancy) > threshold {
+			reports = append(reports, sources.CleanReport{
+				Dataset: "sales",
+				Record:  s.WeekEnding,
+				Field:   "total",
+				Action:  "report",
+				Detail: fmt.Sprintf("total %s differs from adult use %s plus medical %s by %.2f",
+					s.Total, s.AdultUse, s.Medical, discrepancy),
+			})
+		}
+	}
+	return reports
+}
+
+// salesSegment is a market segment of a week's sales, for checking its average price
+type salesSegment struct {
+	name     string // "adult_use" or "medical"
+	field    string // CSV column of its average price
+	revenue  sources.FlexFloat
+	products sources.FlexInt
+	average  sources.FlexFloat
+}
+
+// segments returns the adult use and medical segments of the week's sales
+func (s WeeklySales) segments() []salesSegment {
+	return []salesSegment{
+		{"adult_use", "adult_use_avg_price", s.AdultUse, s.AdultUseProductsSold, s.AdultUseCannabisAveragePrice},
+		{"medical", "medical_avg_price", s.Medical, s.MedicalProductsSold, s.MedicalMarijuanaAveragePrice},
+	}
+}
+
+// impliedAverage returns the segment's revenue over its products sold, and its reported average price.
+// Returns false if any of the three is missing or not a number, or if no products were sold or the
+// average price is zero, as then the prices cannot be compared.
+func (seg salesSegment) impliedAverage() (float64, float64, bool) {
+	if seg.revenue == "" || seg.products == "" || seg.average == "" {
+		return 0, 0, false
+	}
+	revenue, err1 := seg.revenue.Float()
+	products, err2 := seg.products.Int()
+	average, err3 := seg.average.Float()
+	if err1 != nil || err2 != nil || err3 != nil || products == 0 || average == 0 {
+		return 0, 0, false
+	}
+	return revenue / float64(products), average, true
+}
+
+// PriceConsistency returns, for each segment, "adult_use" and "medical", the relative discrepancy
+// between its implied average price, revenue over products sold, and its reported average price:
+// (implied - reported) / reported.  Segments missing any of the three, with one that is not a
+// number, or with no products sold or a zero average price, cannot be checked, so are omitted.
+func (s WeeklySales) PriceConsistency() map[string]float64 {
+	discrepancies := make(map[string]float64)
+	for _, seg := range s.segments() {
+		if implied, average, ok := seg.impliedAverage(); ok {
+			discrepancies[seg.name] = (implied - average) / average
+		}
+	}
+	return discrepancies
+}
+
+// ProductsReconcile returns whether TotalProductsSold is AdultUseProductsSold plus MedicalProductsSold,
+// and the discrepancy, the total minus the sum.  Records missing any of the three, or with one that
+// is not a number, cannot be checked, so reconcile with no discrepancy.
+func (s WeeklySales) ProductsReconcile() (bool, int64) {
+	if s.AdultUseProductsSold == "" || s.MedicalProductsSold == "" || s.TotalProductsSold == "" {
+		return true, 0
+	}
+	adultUse, err1 := s.AdultUseProductsSold.Int()
+	medical, err2 := s.MedicalProductsSold.Int()
+	total, err3 := s.TotalProductsSold.Int()
+	if err1 != nil || err2 != nil || err3 != nil {
+		return true, 0
+	}
+	discrepancy := total - (adultUse + medical)
+	return discrepancy == 0, discrepancy
+}
+
+// CheckSalesPrices returns a CleanReport for each week whose TotalProductsSold differs from the sum
+// of its segments', per ProductsReconcile, and for each segment whose implied average price differs
+// from its reported one, per PriceConsistency, by more than threshold, a fraction of the reported
+// price, e.g. 0.05 for 5%.  The records are left as they are.
+func CheckSalesPrices(sales []WeeklySales, threshold float64) []sources.CleanReport {
+	var reports []sources.CleanReport
+	for _, s := range sales {
+		if ok, discrepancy := s.ProductsReconcile(); !ok {
+			reports = append(reports, sources.CleanReport{
+				Dataset: "sales",
+				Record:  s.WeekEnding,
+				Field:   "total_products_sold",
+				Action:  "report",
+				Detail: fmt.Sprintf("total products sold %s differs from adult use %s plus medical %s by %d",
+					s.TotalProductsSold, s.AdultUseProductsSold, s.MedicalProductsSold, discrepancy),
+			})
+		}
+		discrepancies := s.PriceConsistency()
+		for _, seg := range s.segments() {
+			discrepancy, ok := discrepancies[seg.name]
+			if !ok || math.Abs(discrepancy) <= threshold {
+				continue
+			}
+			implied, _, _ := seg.impliedAverage()
+			reports = append(reports, sources.CleanReport{
+				Dataset: "sales",
+				Record:  s.WeekEnding,
+				Field:   seg.field,
+				Action:  "report",
+				Detail: fmt.Sprintf("average price %s differs from the implied %.2f, revenue %s over %s products sold, by %.1f%%",
+					seg.average, implied, seg.revenue, seg.products, discrepancy*100),
+			})
+		}
+	}
+	return reports
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -46,7 +225,25 @@ var WeeklySalesConfig = sources.SocrataConfig{
 
 // FetchWeeklySales fetches all CT cannabis weekly sales data from the CT API
 func FetchWeeklySales(appToken string, maxCacheAge time.Duration) ([]WeeklySales, error) {
-	return sources.FetchSocrata[WeeklySales](WeeklySalesConfig, appToken, maxCacheAge)
+	return FetchWeeklySalesContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchWeeklySalesContext is FetchWeeklySales, aborted once ctx is done, with opts applied to its config
+func FetchWeeklySalesContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]WeeklySales, error) {
+	return sources.FetchSocrataContext[WeeklySales](ctx, WeeklySalesConfig.With(opts...), appToken, maxCacheAge)
+}
+
+// FetchWeeklySalesIncremental refreshes the cached CT cannabis weekly sales data,
+// fetching only records at or after the latest cached week.
+func FetchWeeklySalesIncremental(appToken string) ([]WeeklySales, error) {
+	return FetchWeeklySalesIncrementalContext(context.Background(), appToken)
+}
+
+// FetchWeeklySalesIncrementalContext is FetchWeeklySalesIncremental, aborted once ctx is done, with opts applied to its config
+func FetchWeeklySalesIncrementalContext(ctx context.Context, appToken string, opts ...sources.ConfigOption) ([]WeeklySales, error) {
+	return sources.IncrementalFetchSocrataContext(ctx, WeeklySalesConfig.With(opts...), appToken, func(s WeeklySales) string {
+		return s.WeekEnding
+	})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -59,30 +256,36 @@ func (s WeeklySales) CSVHeaders() string {
 
 // CSVValue returns the CSV value for the WeeklySales struct
 func (s WeeklySales) CSVValue() string {
-	return fmt.Sprintf(`"%s",%s,%s,%s,%s,%s,%s,%s,%s
-`,
-		s.WeekEnding,
-		s.AdultUse,
-		s.Medical,
-		s.Total,
-		s.AdultUseProductsSold,
-		s.MedicalProductsSold,
-		s.TotalProductsSold,
-		s.AdultUseCannabisAveragePrice,
-		s.MedicalMarijuanaAveragePrice,
-	)
+	var row sources.CSVRow
+	row.String(s.WeekEnding)
+	row.Raw(string(s.AdultUse))
+	row.Raw(string(s.Medical))
+	row.Raw(string(s.Total))
+	row.Raw(string(s.AdultUseProductsSold))
+	row.Raw(string(s.MedicalProductsSold))
+	row.Raw(string(s.TotalProductsSold))
+	row.Raw(string(s.AdultUseCannabisAveragePrice))
+	row.Raw(string(s.MedicalMarijuanaAveragePrice))
+	return row.Line()
 }
 
-///////////////////////////////////////////////////////////////////////////////
-
-// sqlNum converts a string number to SQL value, returning NULL for empty
-func sqlNum(s string) string {
-	if s == "" {
-		return "NULL"
+// Columns returns the CSV columns of the WeeklySales struct, for reading its CSV export back with sources.ReadCSV
+func (s WeeklySales) Columns() []sources.Column[WeeklySales] {
+	return []sources.Column[WeeklySales]{
+		sources.StringColumn("week_ending", func(s *WeeklySales) *string { return &s.WeekEnding }).WithSource("unnamed_column"),
+		sources.StringColumn("adult_use", func(s *WeeklySales) *string { return (*string)(&s.AdultUse) }),
+		sources.StringColumn("medical", func(s *WeeklySales) *string { return (*string)(&s.Medical) }),
+		sources.StringColumn("total", func(s *WeeklySales) *string { return (*string)(&s.Total) }),
+		sources.StringColumn("adult_use_products_sold", func(s *WeeklySales) *string { return (*string)(&s.AdultUseProductsSold) }),
+		sources.StringColumn("medical_products_sold", func(s *WeeklySales) *string { return (*string)(&s.MedicalProductsSold) }),
+		sources.StringColumn("total_products_sold", func(s *WeeklySales) *string { return (*string)(&s.TotalProductsSold) }),
+		sources.StringColumn("adult_use_avg_price", func(s *WeeklySales) *string { return (*string)(&s.AdultUseCannabisAveragePrice) }).WithSource("adult_use_cannabis_average_product_price"),
+		sources.StringColumn("medical_avg_price", func(s *WeeklySales) *string { return (*string)(&s.MedicalMarijuanaAveragePrice) }).WithSource("medical_marijuana_average_product_price"),
 	}
-	return s
 }
 
+///////////////////////////////////////////////////////////////////////////////
+
 // DBInsertWeeklySales inserts weekly sales into DuckDB
 func DBInsertWeeklySales(conn *sql.DB, sales []WeeklySales) error {
 	if len(sales) == 0 {
@@ -93,9 +296,22 @@ func DBInsertWeeklySales(conn *sql.DB, sales []WeeklySales) error {
 	if _, err := conn.Exec("DELETE FROM ct_weekly_sales"); err != nil {
 		return fmt.Errorf("failed to clear weekly sales: %w", err)
 	}
+	return insertWeeklySales(conn, sales, "INSERT")
+}
+
+// DBUpsertWeeklySales inserts weekly sales into DuckDB, replacing any existing sales of the same week
+// and keeping the others, for incremental loads
+func DBUpsertWeeklySales(conn *sql.DB, sales []WeeklySales) error {
+	if len(sales) == 0 {
+		return nil
+	}
+	return insertWeeklySales(conn, sales, "INSERT OR REPLACE")
+}
 
+// insertWeeklySales inserts weekly sales into DuckDB with the given insert statement, e.g. "INSERT OR REPLACE"
+func insertWeeklySales(conn *sql.DB, sales []WeeklySales, insert string) error {
 	var sb strings.Builder
-	sb.WriteString(`INSERT INTO ct_weekly_sales (
+	sb.WriteString(insert + ` INTO ct_weekly_sales (
 		week_ending, adult_use, medical, total,
 		adult_use_products_sold, medical_products_sold, total_products_sold,
 		adult_use_avg_price, medical_avg_price
@@ -107,14 +323,14 @@ func DBInsertWeeklySales(conn *sql.DB, sales []WeeklySales) error {
 		}
 		sb.WriteString(fmt.Sprintf("('%s',%s,%s,%s,%s,%s,%s,%s,%s)",
 			sources.SQLString(s.WeekEnding),
-			sqlNum(s.AdultUse),
-			sqlNum(s.Medical),
-			sqlNum(s.Total),
-			sqlNum(s.AdultUseProductsSold),
-			sqlNum(s.MedicalProductsSold),
-			sqlNum(s.TotalProductsSold),
-			sqlNum(s.AdultUseCannabisAveragePrice),
-			sqlNum(s.MedicalMarijuanaAveragePrice)))
+			s.AdultUse.AsSQL(),
+			s.Medical.AsSQL(),
+			s.Total.AsSQL(),
+			s.AdultUseProductsSold.AsSQL(),
+			s.MedicalProductsSold.AsSQL(),
+			s.TotalProductsSold.AsSQL(),
+			s.AdultUseCannabisAveragePrice.AsSQL(),
+			s.MedicalMarijuanaAveragePrice.AsSQL()))
 	}
 
 	if _, err := conn.Exec(sb.String()); err != nil {
@@ -8,8 +8,10 @@
 package ct
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,14 +26,78 @@ const (
 
 // Tax represents a CT cannabis monthly tax record
 type Tax struct {
-	PeriodEndDate     string `json:"period_end_date"` // ISO 8601 datetime
-	Month             string `json:"month"`
-	Year              string `json:"year"`
-	FiscalYear        string `json:"fiscal_year"`
-	PlantMaterialTax  string `json:"plant_material_tax"`
-	EdibleProductsTax string `json:"edible_products_tax"`
-	OtherCannabisTax  string `json:"other_cannabis_tax"`
-	TotalTax          string `json:"total_tax"`
+	PeriodEndDate     string            `json:"period_end_date"` // ISO 8601 datetime
+	Month             string            `json:"month"`
+	Year              string            `json:"year"`
+	FiscalYear        string            `json:"fiscal_year"`
+	PlantMaterialTax  sources.FlexFloat `json:"plant_material_tax"`
+	EdibleProductsTax sources.FlexFloat `json:"edible_products_tax"`
+	OtherCannabisTax  sources.FlexFloat `json:"other_cannabis_tax"`
+	TotalTax          sources.FlexFloat `json:"total_tax"`
+}
+
+// PeriodTime returns the end of the tax period.
+// It parses PeriodEndDate with sources.ParseSocrataTime, or if that is blank, falls back to
+// the last day of the month given by Year and Month.
+// Returns an error if neither is usable.
+func (t Tax) PeriodTime() (time.Time, error) {
+	if t.PeriodEndDate != "" {
+		period, err := sources.ParseSocrataTime(t.PeriodEndDate)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid period_end_date %q: %w", t.PeriodEndDate, err)
+		}
+		return period, nil
+	}
+
+	month, err := t.yearMonth()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return month.AddDate(0, 1, -1), nil
+}
+
+// PeriodMonth returns the first day of the tax period's month, for monthly bucketing.
+func (t Tax) PeriodMonth() (time.Time, error) {
+	period, err := t.PeriodTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// yearMonth returns the first day of the month given by Year and Month.
+// Month may be a month name ("January", "Jan") or number ("1", "01").
+func (t Tax) yearMonth() (time.Time, error) {
+	if t.Year == "" || t.Month == "" {
+		return time.Time{}, fmt.Errorf("tax record has no period_end_date, year, or month")
+	}
+	year, err := strconv.Atoi(strings.TrimSpace(t.Year))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid year %q: %w", t.Year, err)
+	}
+	month, err := parseMonth(t.Month)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseMonth parses a month name, abbreviation, or number
+func parseMonth(str string) (time.Month, error) {
+	str = strings.TrimSpace(str)
+	if n, err := strconv.Atoi(str); err == nil {
+		if n < 1 || n > 12 {
+			return 0, fmt.Errorf("invalid month %q", str)
+		}
+		return time.Month(n), nil
+	}
+	for m := time.January; m <= time.December; m++ {
+		name := m.String()
+		if len(str) >= 3 && len(str) <= len(name) && strings.EqualFold(str, name[:len(str)]) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid month %q", str)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -45,7 +111,25 @@ var TaxConfig = sources.SocrataConfig{
 
 // FetchTax fetches all CT cannabis tax data from the CT API
 func FetchTax(appToken string, maxCacheAge time.Duration) ([]Tax, error) {
-	return sources.FetchSocrata[Tax](TaxConfig, appToken, maxCacheAge)
+	return FetchTaxContext(context.Background(), appToken, maxCacheAge)
+}
+
+// FetchTaxContext is FetchTax, aborted once ctx is done, with opts applied to its config
+func FetchTaxContext(ctx context.Context, appToken string, maxCacheAge time.Duration, opts ...sources.ConfigOption) ([]Tax, error) {
+	return sources.FetchSocrataContext[Tax](ctx, TaxConfig.With(opts...), appToken, maxCacheAge)
+}
+
+// FetchTaxIncremental refreshes the cached CT cannabis tax data,
+// fetching only records at or after the latest cached period.
+func FetchTaxIncremental(appToken string) ([]Tax, error) {
+	return FetchTaxIncrementalContext(context.Background(), appToken)
+}
+
+// FetchTaxIncrementalContext is FetchTaxIncremental, aborted once ctx is done, with opts applied to its config
+func FetchTaxIncrementalContext(ctx context.Context, appToken string, opts ...sources.ConfigOption) ([]Tax, error) {
+	return sources.IncrementalFetchSocrataContext(ctx, TaxConfig.With(opts...), appToken, func(t Tax) string {
+		return t.PeriodEndDate
+	})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -58,17 +142,30 @@ func (t Tax) CSVHeaders() string {
 
 // CSVValue returns the CSV value for the Tax struct
 func (t Tax) CSVValue() string {
-	return fmt.Sprintf(`"%s","%s","%s","%s",%s,%s,%s,%s
-`,
-		t.PeriodEndDate,
-		t.Month,
-		t.Year,
-		t.FiscalYear,
-		t.PlantMaterialTax,
-		t.EdibleProductsTax,
-		t.OtherCannabisTax,
-		t.TotalTax,
-	)
+	var row sources.CSVRow
+	row.String(t.PeriodEndDate)
+	row.String(t.Month)
+	row.String(t.Year)
+	row.String(t.FiscalYear)
+	row.Raw(string(t.PlantMaterialTax))
+	row.Raw(string(t.EdibleProductsTax))
+	row.Raw(string(t.OtherCannabisTax))
+	row.Raw(string(t.TotalTax))
+	return row.Line()
+}
+
+// Columns returns the CSV columns of the Tax struct, for reading its CSV export back with sources.ReadCSV
+func (t Tax) Columns() []sources.Column[Tax] {
+	return []sources.Column[Tax]{
+		sources.StringColumn("period_end_date", func(t *Tax) *string { return &t.PeriodEndDate }),
+		sources.StringColumn("month", func(t *Tax) *string { return &t.Month }),
+		sources.StringColumn("year", func(t *Tax) *string { return &t.Year }),
+		sources.StringColumn("fiscal_year", func(t *Tax) *string { return &t.FiscalYear }),
+		sources.StringColumn("plant_material_tax", func(t *Tax) *string { return (*string)(&t.PlantMaterialTax) }),
+		sources.StringColumn("edible_products_tax", func(t *Tax) *string { return (*string)(&t.EdibleProductsTax) }),
+		sources.StringColumn("other_cannabis_tax", func(t *Tax) *string { return (*string)(&t.OtherCannabisTax) }),
+		sources.StringColumn("total_tax", func(t *Tax) *string { return (*string)(&t.TotalTax) }),
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -83,9 +180,22 @@ func DBInsertTax(conn *sql.DB, taxes []Tax) error {
 	if _, err := conn.Exec("DELETE FROM ct_tax"); err != nil {
 		return fmt.Errorf("failed to clear tax: %w", err)
 	}
+	return insertTax(conn, taxes, "INSERT")
+}
 
+// DBUpsertTax inserts tax records into DuckDB, replacing any existing record of the same period
+// and keeping the others, for incremental loads
+func DBUpsertTax(conn *sql.DB, taxes []Tax) error {
+	if len(taxes) == 0 {
+		return nil
+	}
+	return insertTax(conn, taxes, "INSERT OR REPLACE")
+}
+
+// insertTax inserts tax records into DuckDB with the given insert statement, e.g. "INSERT OR REPLACE"
+func insertTax(conn *sql.DB, taxes []Tax, insert string) error {
 	var sb strings.Builder
-	sb.WriteString(`INSERT INTO ct_tax (
+	sb.WriteString(insert + ` INTO ct_tax (
 		period_end_date, month, year, fiscal_year,
 		plant_material_tax, edible_products_tax, other_cannabis_tax, total_tax
 	) VALUES `)
@@ -99,10 +209,10 @@ func DBInsertTax(conn *sql.DB, taxes []Tax) error {
 			sources.SQLString(t.Month),
 			sources.SQLString(t.Year),
 			sources.SQLString(t.FiscalYear),
-			sqlNumOrNull(t.PlantMaterialTax),
-			sqlNumOrNull(t.EdibleProductsTax),
-			sqlNumOrNull(t.OtherCannabisTax),
-			sqlNumOrNull(t.TotalTax)))
+			t.PlantMaterialTax.AsSQL(),
+			t.EdibleProductsTax.AsSQL(),
+			t.OtherCannabisTax.AsSQL(),
+			t.TotalTax.AsSQL()))
 	}
 
 	if _, err := conn.Exec(sb.String()); err != nil {
@@ -110,11 +220,3 @@ func DBInsertTax(conn *sql.DB, taxes []Tax) error {
 	}
 	return nil
 }
-
-// sqlNumOrNull converts a string number to SQL value, returning NULL for empty
-func sqlNumOrNull(s string) string {
-	if s == "" {
-		return "NULL"
-	}
-	return s
-}
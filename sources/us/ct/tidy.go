@@ -0,0 +1,55 @@
+// Copyright 2026 Neomantra Corp
+//
+// Long (tidy) format of CT brand measures
+
+package ct
+
+import "github.com/AgentDank/dank-extract/sources"
+
+// BrandTidyCSVFilename is the CSV export of TidyBrands
+const BrandTidyCSVFilename = "us_ct_brands_tidy.csv"
+
+// TidyMeasureRow is one measure of one brand, as a row of the long format of brand measures
+type TidyMeasureRow struct {
+	RegistrationNumber string  `json:"registration_number"` // Registration number of the brand
+	Measure            string  `json:"measure"`             // Column name of the measure, e.g. "cannabidiols_cbd"
+	Value              Measure `json:"value"`
+	IsTrace            bool    `json:"is_trace"` // True if the measure was below the limit of quantification
+	IsEmpty            bool    `json:"is_empty"` // True if the measure was not reported
+}
+
+// TidyBrands unpivots the measure columns of the brands into long format, with a row for every
+// measure of every brand, in the order of the brands and then of Brand.Measures.
+// Empty and trace measures are kept, flagged by IsEmpty and IsTrace, so each brand has the same rows.
+func TidyBrands(brands []Brand) []TidyMeasureRow {
+	rows := make([]TidyMeasureRow, 0, len(brands)*len(brandMeasureFields))
+	for _, b := range brands {
+		for _, nm := range b.Measures() {
+			rows = append(rows, TidyMeasureRow{
+				RegistrationNumber: b.RegistrationNumber,
+				Measure:            nm.Column,
+				Value:              *nm.Measure,
+				IsTrace:            nm.Measure.IsTrace(),
+				IsEmpty:            nm.Measure.IsEmpty(),
+			})
+		}
+	}
+	return rows
+}
+
+// CSVHeaders returns the CSV headers for the TidyMeasureRow struct
+func (r TidyMeasureRow) CSVHeaders() string {
+	return `"registration_number","measure","value","is_trace","is_empty"
+`
+}
+
+// CSVValue returns the CSV value for the TidyMeasureRow struct
+func (r TidyMeasureRow) CSVValue() string {
+	var row sources.CSVRow
+	row.String(r.RegistrationNumber)
+	row.String(r.Measure)
+	row.Append(r.Value.AppendCSV)
+	row.Bool(r.IsTrace)
+	row.Bool(r.IsEmpty)
+	return row.Line()
+}
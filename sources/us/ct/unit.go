@@ -0,0 +1,135 @@
+// Copyright 2026 Neomantra Corp
+//
+// Inference and normalization of the units of CT brand measures
+
+package ct
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/AgentDank/dank-extract/sources"
+)
+
+// unitsPerPercent is how many of each concentration unit make one percent.
+// Mass units, mg and g, are amounts rather than concentrations, so cannot be converted.
+var unitsPerPercent = map[Unit]float64{
+	UnitPercent: 1,
+	UnitMgPerG:  10,
+	UnitPPM:     10000,
+}
+
+// maxPercentAmount is the largest amount that can be a percentage.  Most unitless amounts
+// of a column being at most this suggests it is in percent, and otherwise in mg/g.
+const maxPercentAmount = 100
+
+// ConvertUnit returns the measure converted to the given concentration unit, %, mg/g, or ppm,
+// from the unit it was reported in, and true.  Empty and trace measures only take the unit.
+// Returns the measure unchanged and false if either unit is not a concentration unit.
+func (m Measure) ConvertUnit(to Unit) (Measure, bool) {
+	from, fromOK := unitsPerPercent[m.unit]
+	per, toOK := unitsPerPercent[to]
+	if !fromOK || !toOK {
+		return m, false
+	}
+	if _, trace, empty := m.Amount(); !trace && !empty && !m.IsZero() {
+		m.amount = m.amount / from * per
+		m.hi = m.hi / from * per
+	}
+	m.unit = to
+	return m, true
+}
+
+// InferColumnUnit returns the concentration unit that a column of measures is most likely in.
+// Each measure with an amount and a concentration unit votes for its unit.  Measures without
+// a unit vote together: for percent if most of them are at most 100, as percentages must be,
+// and otherwise for mg/g.  The unit with the most votes wins, percent if tied.
+// Returns UnitNone if no measure has an amount.
+func InferColumnUnit(ms []Measure) Unit {
+	votes := make(map[Unit]int)
+	var unitless []float64
+	for _, m := range ms {
+		amount, trace, empty := m.Amount()
+		if trace || empty {
+			continue
+		}
+		switch _, ok := unitsPerPercent[m.unit]; {
+		case ok:
+			votes[m.unit]++
+		case m.unit == UnitNone:
+			unitless = append(unitless, amount)
+		}
+	}
+	if len(unitless) > 0 {
+		slices.Sort(unitless)
+		if unitless[(len(unitless)-1)/2] <= maxPercentAmount {
+			votes[UnitPercent] += len(unitless)
+		} else {
+			votes[UnitMgPerG] += len(unitless)
+		}
+	}
+
+	inferred := UnitNone
+	for _, unit := range []Unit{UnitPercent, UnitMgPerG, UnitPPM} {
+		if votes[unit] > votes[inferred] {
+			inferred = unit
+		}
+	}
+	return inferred
+}
+
+// NormalizeBrandUnits converts, in place, every brand measure to the unit that InferColumnUnit
+// infers for its column.  Measures reported in another concentration unit are converted, as are
+// unitless amounts over 100 in a percent column, which are taken to be in mg/g.  Other unitless
+// measures are taken to be in the column's unit already, and mass units cannot be converted.
+// Returns a CleanReport for each converted measure.
+func NormalizeBrandUnits(bs []Brand) []sources.CleanReport {
+	measures := make([][]NamedMeasure, len(bs))
+	for i := range bs {
+		measures[i] = bs[i].Measures()
+	}
+
+	var reports []sources.CleanReport
+	column := make([]Measure, len(bs))
+	for c, nm := range (&Brand{}).Measures() {
+		for i := range bs {
+			column[i] = *measures[i][c].Measure
+		}
+		unit := InferColumnUnit(column)
+		if unit == UnitNone {
+			continue
+		}
+
+		for i := range bs {
+			m := measures[i][c].Measure
+			amount, trace, empty := m.Amount()
+			if trace || empty {
+				continue
+			}
+			from := m.Unit()
+			if from == UnitNone {
+				if unit != UnitPercent || amount <= maxPercentAmount {
+					continue
+				}
+				from = UnitMgPerG
+			}
+			if from == unit {
+				continue
+			}
+			converted, ok := m.WithUnit(from).ConvertUnit(unit)
+			if !ok {
+				continue
+			}
+			original := m.AsCSV() + " " + from.String()
+			*m = converted
+			reports = append(reports, sources.CleanReport{
+				Dataset: "brands",
+				Record:  bs[i].RegistrationNumber,
+				Field:   nm.Column,
+				Action:  "convert",
+				Detail:  fmt.Sprintf("converted %s to %s %s, the unit inferred for the column", original, m.AsCSV(), unit),
+			})
+		}
+	}
+	return reports
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2026 Neomantra Corp
+
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// WatermarkSuffix replaces the ".json" of a cache filename to name its watermark file,
+// e.g. "us_ct_tax_last_run.json"
+const WatermarkSuffix = "_last_run.json"
+
+// Watermark records how far the incremental fetches of a dataset have progressed.
+// It is kept in the cache directory alongside the dataset's cache file.
+type Watermark struct {
+	OrderBy   string    `json:"order_by"`   // Field the watermark is of, the dataset's SocrataConfig.DeltaField or OrderBy
+	Value     string    `json:"value"`      // Greatest value of the field fetched so far
+	FetchedAt time.Time `json:"fetched_at"` // When the last successful fetch completed
+	Fetched   int       `json:"fetched"`    // Records the last fetch returned
+	Records   int       `json:"records"`    // Records in the cache once merged
+}
+
+// watermarkFilename returns the name of the watermark file of a cache file
+func watermarkFilename(cacheFilename string) string {
+	return strings.TrimSuffix(cacheFilename, ".json") + WatermarkSuffix
+}
+
+// ReadWatermark reads the watermark of a cached dataset.
+// Returns nil without an error if it has none, as before its first incremental fetch.
+func ReadWatermark(cacheFilename string) (*Watermark, error) {
+	watermarkBytes, err := os.ReadFile(GetDankCachePathname(watermarkFilename(cacheFilename)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, &CacheError{Kind: CacheUnreadable, Reason: "watermark read error", Err: err}
+	}
+	var w Watermark
+	if err := json.Unmarshal(watermarkBytes, &w); err != nil {
+		return nil, &CacheError{Kind: CacheUnreadable, Reason: "watermark parse error", Err: err}
+	}
+	return &w, nil
+}
+
+// WriteWatermark writes the watermark of a cached dataset, replacing any previous one
+func WriteWatermark(cacheFilename string, w Watermark) error {
+	watermarkBytes, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watermark: %w", err)
+	}
+	if err := os.WriteFile(GetDankCachePathname(watermarkFilename(cacheFilename)), append(watermarkBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write watermark: %w", err)
+	}
+	return nil
+}